@@ -12,6 +12,7 @@ import (
 	corehttp "github.com/ipfs/go-ipfs/core/corehttp"
 	"github.com/ipfs/go-ipfs/core/corerouting"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	util "github.com/ipfs/go-ipfs/util"
 )
@@ -20,6 +21,10 @@ const (
 	initOptionKwd             = "init"
 	routingOptionKwd          = "routing"
 	routingOptionSupernodeKwd = "supernode"
+	routingOptionDHTClientKwd = "dhtclient"
+	routingOptionDHTKwd       = "dht"
+	routingOptionParallelKwd  = "parallel"
+	routingOptionTieredKwd    = "tiered"
 	mountKwd                  = "mount"
 	writableKwd               = "writable"
 	ipfsMountKwd              = "mount-ipfs"
@@ -50,6 +55,34 @@ The API address can be changed the same way:
 
 Make sure to restart the daemon after changing addresses.
 
+The routing system is chosen by 'ipfs config Routing.Type', or the
+--routing option. The "parallel" and "tiered" types combine the routers
+listed in Routing.Routers: "parallel" asks all of them at once, "tiered"
+asks them in order. For example, to ask a supernode before the DHT, use
+'ipfs config edit' to set:
+
+   "Routing": {
+     "Type": "tiered",
+     "Routers": [{"Type": "supernode"}, {"Type": "dht"}]
+   }
+
+Nodes that can only reach the network over HTTP can hand their queries to
+a daemon whose gateway serves them ('ipfs config --bool
+Gateway.DelegatedRouting true'), with a "delegated" router:
+
+   "Routing": {
+     "Type": "tiered",
+     "Routers": [{"Type": "delegated", "Endpoint": "http://10.0.0.1:8080"}]
+   }
+
+To keep the node in a private network, connecting only to the peers that
+hold the same secret key, set a random 32 byte key in base64:
+
+   ipfs config Swarm.PrivateNetworkKey $(head -c 32 /dev/urandom | base64)
+
+and use the same key on all of its peers. Replace the Bootstrap list with
+peers of the private network too.
+
 By default, the gateway is only accessible locally. To expose it to other computers
 in the network, use 0.0.0.0 as the ip address:
 
@@ -62,7 +95,7 @@ the port as you would other services or database (firewall, authenticated proxy,
 
 	Options: []cmds.Option{
 		cmds.BoolOption(initOptionKwd, "Initialize IPFS with default settings if not already initialized"),
-		cmds.StringOption(routingOptionKwd, "Overrides the routing option (dht, supernode)"),
+		cmds.StringOption(routingOptionKwd, "Overrides the routing option (dht, dhtclient, supernode, parallel, tiered)"),
 		cmds.BoolOption(mountKwd, "Mounts IPFS to the filesystem"),
 		cmds.BoolOption(writableKwd, "Enable writing objects (with POST, PUT and DELETE)"),
 		cmds.StringOption(ipfsMountKwd, "Path to the mountpoint for IPFS (if using --mount)"),
@@ -130,12 +163,19 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 	nb := core.NewNodeBuilder().Online()
 	nb.SetRepo(repo)
 
-	routingOption, _, err := req.Option(routingOptionKwd).String()
+	routingOption, found, err := req.Option(routingOptionKwd).String()
 	if err != nil {
 		res.SetError(err, cmds.ErrNormal)
 		return
 	}
-	if routingOption == routingOptionSupernodeKwd {
+	if !found {
+		routingOption = cfg.Routing.Type
+	}
+	switch routingOption {
+	case "", routingOptionDHTKwd:
+	case routingOptionDHTClientKwd:
+		nb.SetRouting(core.DHTClientOption)
+	case routingOptionSupernodeKwd:
 		servers, err := repo.Config().SupernodeRouting.ServerIPFSAddrs()
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -150,6 +190,19 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 			})
 		}
 		nb.SetRouting(corerouting.SupernodeClient(infos...))
+	case routingOptionParallelKwd, routingOptionTieredKwd:
+		rc := config.Router{Type: routingOption, Routers: cfg.Routing.Routers}
+		ro, err := corerouting.Composed(rc, cfg.SupernodeRouting.Servers)
+		if err != nil {
+			res.SetError(fmt.Errorf("bad config setting Routing.Routers: %s", err), cmds.ErrClient)
+			repo.Close() // because ownership hasn't been transferred to the node
+			return
+		}
+		nb.SetRouting(ro)
+	default:
+		res.SetError(fmt.Errorf("unrecognized routing option: %s", routingOption), cmds.ErrClient)
+		repo.Close() // because ownership hasn't been transferred to the node
+		return
 	}
 
 	node, err := nb.Build(ctx.Context)
@@ -174,6 +227,10 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 		return node, nil
 	}
 
+	if cfg.Swarm.PrivateNetworkKey != "" {
+		fmt.Printf("Swarm is limited to the private network set in Swarm.PrivateNetworkKey\n")
+	}
+
 	// verify api address is valid multiaddr
 	apiMaddr, err := ma.NewMultiaddr(cfg.Addresses.API)
 	if err != nil {
@@ -249,6 +306,9 @@ func daemonFunc(req cmds.Request, res cmds.Response) {
 			if rootRedirect != nil {
 				opts = append(opts, rootRedirect)
 			}
+			if cfg.Gateway.DelegatedRouting {
+				opts = append(opts, corehttp.DelegatedRoutingOption())
+			}
 			if writable {
 				fmt.Printf("Gateway (writable) server listening on %s\n", gatewayMaddr)
 			} else {
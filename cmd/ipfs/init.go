@@ -148,7 +148,11 @@ func addDefaultAssets(out io.Writer, repoRoot string) error {
 		}
 	}
 
-	dir := dirb.GetNode()
+	dir, err := dirb.GetNode()
+	if err != nil {
+		return err
+	}
+
 	dkey, err := nd.DAG.Add(dir)
 	if err != nil {
 		return err
@@ -148,7 +148,10 @@ func main() {
 	}
 
 	// everything went better than expected :)
-	io.Copy(os.Stdout, output)
+	if _, err := io.Copy(os.Stdout, output); err != nil {
+		printErr(err)
+		os.Exit(1)
+	}
 }
 
 func (i *cmdInvocation) Run(ctx context.Context) (output io.Reader, err error) {
@@ -7,15 +7,20 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path"
+	"strings"
 	"time"
 
 	aws "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/crowdmob/goamz/aws"
 	s3 "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/crowdmob/goamz/s3"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/fzzy/radix/redis"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	levelds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/leveldb"
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	core "github.com/ipfs/go-ipfs/core"
 	corerouting "github.com/ipfs/go-ipfs/core/corerouting"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	fsrepo "github.com/ipfs/go-ipfs/repo/fsrepo"
 	redisds "github.com/ipfs/go-ipfs/thirdparty/redis-datastore"
@@ -27,9 +32,12 @@ var (
 	ttl             = flag.Duration("ttl", 12*time.Hour, "period after which routing keys expire")
 	redisHost       = flag.String("redis-host", "localhost:6379", "redis tcp host address:port")
 	redisPassword   = flag.String("redis-pass", "", "redis password if required")
-	datastoreOption = flag.String("datastore", "redis", "routing datastore (also available: aws)")
+	datastoreOption = flag.String("datastore", "redis", "routing datastore (also available: aws, leveldb)")
 	s3bucket        = flag.String("aws-bucket", "", "S3 bucket for aws routing datastore")
 	s3region        = flag.String("aws-region", aws.USWest2.Name, "S3 region")
+	leveldbPath     = flag.String("leveldb-path", "", "directory of the leveldb routing datastore (default: routing in the repo)")
+	servers         = flag.String("servers", "", "comma-separated addresses of the supernode servers to replicate records with")
+	replicas        = flag.Int("replicas", 2, "number of servers holding each record, when replicating")
 	nBitsForKeypair = flag.Int("b", 1024, "number of bits for keypair (if repo is uninitialized)")
 )
 
@@ -89,13 +97,29 @@ func run() error {
 			return err
 		}
 		ds = s3
+	case "leveldb":
+		dir := *leveldbPath
+		if dir == "" {
+			dir = path.Join(repoPath, "routing")
+		}
+		ldb, err := levelds.NewDatastore(dir, nil)
+		if err != nil {
+			return fmt.Errorf("could not open leveldb datastore: %s", err)
+		}
+		defer ldb.Close()
+		ds = ldb
 	default:
 		return errors.New("unsupported datastore type")
 	}
+
+	peers, err := serverInfos(*servers)
+	if err != nil {
+		return err
+	}
 	node, err := core.NewIPFSNode(ctx,
 		core.OnlineWithOptions(
 			repo,
-			corerouting.SupernodeServer(ds),
+			corerouting.SupernodeReplicatedServer(ds, peers, *replicas),
 			core.DefaultHostOption),
 	)
 	if err != nil {
@@ -109,6 +133,26 @@ func run() error {
 	return nil
 }
 
+// serverInfos returns the peer infos of the comma-separated server
+// addresses in list.
+func serverInfos(list string) ([]peer.PeerInfo, error) {
+	if list == "" {
+		return nil, nil
+	}
+	addrs, err := (&config.SupernodeClientConfig{Servers: strings.Split(list, ",")}).ServerIPFSAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("bad server address: %s", err)
+	}
+	var infos []peer.PeerInfo
+	for _, addr := range addrs {
+		infos = append(infos, peer.PeerInfo{
+			ID:    addr.ID(),
+			Addrs: []ma.Multiaddr{addr.Transport()},
+		})
+	}
+	return infos, nil
+}
+
 func makeS3Datastore() (*s3datastore.S3Datastore, error) {
 
 	// FIXME get ENV through flags?
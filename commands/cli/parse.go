@@ -190,13 +190,19 @@ func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursi
 			numRequired--
 		}
 
+		if len(inputs) == 0 && (stdin == nil || !argDef.SupportsStdin) {
+			// stdin was piped in, but no argument left takes it. the check
+			// below reports any missing arguments.
+			break
+		}
+
 		var err error
 		if argDef.Type == cmds.ArgString {
-			if stdin == nil {
+			if stdin == nil || !argDef.SupportsStdin {
 				// add string values
 				stringArgs, inputs = appendString(stringArgs, inputs)
 
-			} else if argDef.SupportsStdin {
+			} else {
 				// if we have a stdin, read it in and use the data as a string value
 				stringArgs, stdin, err = appendStdinAsString(stringArgs, stdin)
 				if err != nil {
@@ -205,14 +211,14 @@ func parseArgs(inputs []string, stdin *os.File, argDefs []cmds.Argument, recursi
 			}
 
 		} else if argDef.Type == cmds.ArgFile {
-			if stdin == nil {
+			if stdin == nil || !argDef.SupportsStdin {
 				// treat stringArg values as file paths
 				fileArgs, inputs, err = appendFile(fileArgs, inputs, argDef, recursive)
 				if err != nil {
 					return nil, nil, err
 				}
 
-			} else if argDef.SupportsStdin {
+			} else {
 				// if we have a stdin, create a file from it
 				fileArgs, stdin = appendStdinAsFile(fileArgs, stdin)
 			}
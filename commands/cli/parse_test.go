@@ -2,6 +2,7 @@ package cli
 
 import (
 	//"fmt"
+	"os"
 	"testing"
 
 	"github.com/ipfs/go-ipfs/commands"
@@ -81,6 +82,12 @@ func TestArgumentParsing(t *testing.T) {
 					commands.StringArg("b", true, false, "another arg"),
 				},
 			},
+			"stdinarg": &commands.Command{
+				Arguments: []commands.Argument{
+					commands.StringArg("a", true, false, "some arg"),
+					commands.StringArg("b", true, false, "another arg").EnableStdin(),
+				},
+			},
 		},
 	}
 
@@ -153,4 +160,25 @@ func TestArgumentParsing(t *testing.T) {
 	if err == nil {
 		t.Error("Should have failed (provided too many args, only takes 1)")
 	}
+
+	pipeStdin := func(data string) *os.File {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+		w.Close()
+		return r
+	}
+
+	req, _, _, err := Parse([]string{"stdinarg", "value1"}, pipeStdin("value2"), rootCmd)
+	if err != nil {
+		t.Error("Should have passed", err)
+	} else if args := req.Arguments(); len(args) != 2 || args[0] != "value1" || args[1] != "value2" {
+		t.Errorf("Returned args were different than expected: %v", args)
+	}
+	_, _, _, err = Parse([]string{"stdinarg"}, pipeStdin("value2"), rootCmd)
+	if err == nil {
+		t.Error("Should have failed (only provided stdin, 2 args required)")
+	}
 }
@@ -193,7 +193,13 @@ func getResponse(httpRes *http.Response, req cmds.Request) (cmds.Response, error
 					err = dec.Decode(&v)
 				}
 				if err != nil && err != io.EOF {
-					fmt.Println(err.Error())
+					// the output was cut short, pass on the error so that
+					// marshalers can fail instead of waiting for more
+					select {
+					case outChan <- err:
+					case <-ctx.Done():
+					}
+					close(outChan)
 					return
 				}
 
@@ -169,10 +169,22 @@ func copyChunks(contentType string, w http.ResponseWriter, out io.Reader) error
 	if !ok {
 		return errors.New("Could not create hijacker")
 	}
-	conn, writer, err := hijacker.Hijack()
-	if err != nil {
+
+	// wait for the first chunk before answering, so that an output failing
+	// right away is reported as an error rather than cut off
+	buf := make([]byte, 32*1024)
+	n, err := out.Read(buf)
+	if err != nil && err != io.EOF {
+		w.Header().Set(contentTypeHeader, "text/plain")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
 		return err
 	}
+
+	conn, writer, herr := hijacker.Hijack()
+	if herr != nil {
+		return herr
+	}
 	defer conn.Close()
 
 	writer.WriteString("HTTP/1.1 200 OK\r\n")
@@ -182,11 +194,7 @@ func copyChunks(contentType string, w http.ResponseWriter, out io.Reader) error
 	writer.WriteString(transferEncodingHeader + ": chunked\r\n")
 	writer.WriteString(channelHeader + ": 1\r\n\r\n")
 
-	buf := make([]byte, 32*1024)
-
 	for {
-		n, err := out.Read(buf)
-
 		if n > 0 {
 			length := fmt.Sprintf("%x\r\n", n)
 			writer.WriteString(length)
@@ -206,6 +214,8 @@ func copyChunks(contentType string, w http.ResponseWriter, out io.Reader) error
 		if err == io.EOF {
 			break
 		}
+
+		n, err = out.Read(buf)
 	}
 
 	writer.WriteString("0\r\n\r\n")
@@ -16,7 +16,7 @@ import (
 	importer "github.com/ipfs/go-ipfs/importer"
 	"github.com/ipfs/go-ipfs/importer/chunk"
 	dag "github.com/ipfs/go-ipfs/merkledag"
-	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -271,7 +271,7 @@ func addFile(n *core.IpfsNode, file files.File, out chan interface{}, progress b
 func addDir(n *core.IpfsNode, dir files.File, out chan interface{}, progress bool) (*dag.Node, error) {
 	log.Infof("adding directory: %s", dir.FileName())
 
-	tree := &dag.Node{Data: ft.FolderPBData()}
+	tree := uio.NewDirectory(n.DAG)
 
 	for {
 		file, err := dir.NextFile()
@@ -289,23 +289,28 @@ func addDir(n *core.IpfsNode, dir files.File, out chan interface{}, progress boo
 
 		_, name := path.Split(file.FileName())
 
-		err = tree.AddNodeLink(name, node)
+		err = tree.SetChild(n.Context(), name, node)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	err := outputDagnode(out, dir.FileName(), tree)
+	treenode, err := tree.GetNode()
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = n.DAG.Add(tree)
+	err = outputDagnode(out, dir.FileName(), treenode)
 	if err != nil {
 		return nil, err
 	}
 
-	return tree, nil
+	_, err = n.DAG.Add(treenode)
+	if err != nil {
+		return nil, err
+	}
+
+	return treenode, nil
 }
 
 // outputDagnode sends dagnode info over the output channel
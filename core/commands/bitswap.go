@@ -5,6 +5,7 @@ import (
 	"fmt"
 	cmds "github.com/ipfs/go-ipfs/commands"
 	bitswap "github.com/ipfs/go-ipfs/exchange/bitswap"
+	rp "github.com/ipfs/go-ipfs/exchange/reprovide"
 	u "github.com/ipfs/go-ipfs/util"
 	"io"
 )
@@ -15,8 +16,9 @@ var BitswapCmd = &cmds.Command{
 		ShortDescription: ``,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"wantlist": showWantlistCmd,
-		"stat":     bitswapStatCmd,
+		"wantlist":  showWantlistCmd,
+		"stat":      bitswapStatCmd,
+		"reprovide": reprovideCmd,
 	},
 }
 
@@ -94,3 +96,93 @@ var bitswapStatCmd = &cmds.Command{
 		},
 	},
 }
+
+// ReprovideProgress is how far an 'ipfs bitswap reprovide' run has got. An
+// error stopping the run is sent in place of the next one.
+type ReprovideProgress struct {
+	Provided int
+	Failed   int
+}
+
+var reprovideCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Announce the provided blocks to the network now",
+		ShortDescription: `
+Runs the reprovider once, without waiting for its next run. The blocks
+announced are chosen by the Reprovider.Strategy config setting. The number
+of blocks announced is printed as it grows.
+`,
+	},
+	Type: ReprovideProgress{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		nd, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if !nd.OnlineMode() || nd.Reprovider == nil {
+			res.SetError(errNotOnline, cmds.ErrClient)
+			return
+		}
+
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		ctx := req.Context().Context
+		send := func(v interface{}) {
+			select {
+			case outChan <- v:
+			case <-ctx.Done():
+			}
+		}
+
+		progress := make(chan rp.Progress)
+		go func() {
+			defer close(progress)
+			err := nd.Reprovider.ReprovideProgress(ctx, progress)
+			if err != nil {
+				// the marshalers return it, failing the command
+				send(err)
+			}
+		}()
+
+		go func() {
+			defer close(outChan)
+			for p := range progress {
+				send(&ReprovideProgress{
+					Provided: p.Provided,
+					Failed:   p.Failed,
+				})
+			}
+		}()
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			outChan, ok := res.Output().(<-chan interface{})
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
+			marshal := func(v interface{}) (io.Reader, error) {
+				if err, ok := v.(error); ok {
+					return nil, err
+				}
+				obj, ok := v.(*ReprovideProgress)
+				if !ok {
+					return nil, u.ErrCast()
+				}
+
+				buf := new(bytes.Buffer)
+				fmt.Fprintf(buf, "provided %d blocks, %d failed\n", obj.Provided, obj.Failed)
+				return buf, nil
+			}
+
+			return &cmds.ChannelMarshaler{
+				Channel:   outChan,
+				Marshaler: marshal,
+			}, nil
+		},
+		cmds.JSON: streamJSONMarshaler,
+	},
+}
@@ -8,9 +8,9 @@ import (
 	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
 	notif "github.com/ipfs/go-ipfs/notifications"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
-	ipdht "github.com/ipfs/go-ipfs/routing/dht"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -50,7 +50,7 @@ var queryDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
+		dht, ok := core.FindDHT(n.Routing)
 		if !ok {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
@@ -101,29 +101,11 @@ var queryDhtCmd = &cmds.Command{
 				verbose, _, _ := res.Request().Option("v").Bool()
 
 				buf := new(bytes.Buffer)
-				if verbose {
-					fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
-				}
-				switch obj.Type {
-				case notif.FinalPeer:
-					fmt.Fprintf(buf, "%s\n", obj.ID)
-				case notif.PeerResponse:
-					if verbose {
-						fmt.Fprintf(buf, "* %s says use ", obj.ID)
-						for _, p := range obj.Responses {
-							fmt.Fprintf(buf, "%s ", p.ID)
-						}
-						fmt.Fprintln(buf)
-					}
-				case notif.SendingQuery:
-					if verbose {
-						fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-					}
-				case notif.QueryError:
-					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
-				default:
-					fmt.Fprintf(buf, "unrecognized event type: %d\n", obj.Type)
-				}
+				printEvent(obj, buf, verbose, pfuncMap{
+					notif.FinalPeer: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
+						fmt.Fprintf(out, "%s\n", obj.ID)
+					},
+				})
 				return buf, nil
 			}
 
@@ -157,7 +139,7 @@ FindProviders will return a list of peers who are able to provide the value requ
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
+		dht, ok := core.FindDHT(n.Routing)
 		if !ok {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
@@ -206,42 +188,20 @@ FindProviders will return a list of peers who are able to provide the value requ
 				}
 
 				buf := new(bytes.Buffer)
-				if verbose {
-					fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
-				}
-				switch obj.Type {
-				case notif.FinalPeer:
-					if verbose {
-						fmt.Fprintf(buf, "* closest peer %s\n", obj.ID)
-					}
-				case notif.Provider:
-					prov := obj.Responses[0]
-					if verbose {
-						fmt.Fprintf(buf, "provider: ")
-					}
-					fmt.Fprintf(buf, "%s\n", prov.ID.Pretty())
-					if verbose {
-						for _, a := range prov.Addrs {
-							fmt.Fprintf(buf, "\t%s\n", a)
+				printEvent(obj, buf, verbose, pfuncMap{
+					notif.Provider: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
+						prov := obj.Responses[0]
+						if verbose {
+							fmt.Fprintf(out, "provider: ")
 						}
-					}
-				case notif.PeerResponse:
-					if verbose {
-						fmt.Fprintf(buf, "* %s says use ", obj.ID)
-						for _, p := range obj.Responses {
-							fmt.Fprintf(buf, "%s ", p.ID)
+						fmt.Fprintf(out, "%s\n", prov.ID.Pretty())
+						if verbose {
+							for _, a := range prov.Addrs {
+								fmt.Fprintf(out, "\t%s\n", a)
+							}
 						}
-						fmt.Fprintln(buf)
-					}
-				case notif.SendingQuery:
-					if verbose {
-						fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-					}
-				case notif.QueryError:
-					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
-				default:
-					fmt.Fprintf(buf, "unrecognized event type: %d\n", obj.Type)
-				}
+					},
+				})
 				return buf, nil
 			}
 
@@ -270,7 +230,7 @@ var findPeerDhtCmd = &cmds.Command{
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
+		dht, ok := core.FindDHT(n.Routing)
 		if !ok {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
@@ -326,27 +286,15 @@ var findPeerDhtCmd = &cmds.Command{
 				}
 
 				buf := new(bytes.Buffer)
-				fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
-				switch obj.Type {
-				case notif.FinalPeer:
-					pi := obj.Responses[0]
-					fmt.Fprintf(buf, "%s\n", pi.ID)
-					for _, a := range pi.Addrs {
-						fmt.Fprintf(buf, "\t%s\n", a)
-					}
-				case notif.PeerResponse:
-					fmt.Fprintf(buf, "* %s says use ", obj.ID)
-					for _, p := range obj.Responses {
-						fmt.Fprintf(buf, "%s ", p.ID)
-					}
-					fmt.Fprintln(buf)
-				case notif.SendingQuery:
-					fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-				case notif.QueryError:
-					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
-				default:
-					fmt.Fprintf(buf, "unrecognized event type: %d\n", obj.Type)
-				}
+				printEvent(obj, buf, true, pfuncMap{
+					notif.FinalPeer: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
+						pi := obj.Responses[0]
+						fmt.Fprintf(out, "%s\n", pi.ID)
+						for _, a := range pi.Addrs {
+							fmt.Fprintf(out, "\t%s\n", a)
+						}
+					},
+				})
 				return buf, nil
 			}
 
@@ -380,7 +328,7 @@ GetValue will return the value stored in the dht at the given key.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
+		dht, ok := core.FindDHT(n.Routing)
 		if !ok {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
@@ -431,29 +379,11 @@ GetValue will return the value stored in the dht at the given key.
 				}
 
 				buf := new(bytes.Buffer)
-				if verbose {
-					fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
-				}
-				switch obj.Type {
-				case notif.PeerResponse:
-					if verbose {
-						fmt.Fprintf(buf, "* %s says use ", obj.ID)
-						for _, p := range obj.Responses {
-							fmt.Fprintf(buf, "%s ", p.ID)
-						}
-						fmt.Fprintln(buf)
-					}
-				case notif.SendingQuery:
-					if verbose {
-						fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-					}
-				case notif.Value:
-					fmt.Fprintf(buf, "got value: '%s'\n", obj.Extra)
-				case notif.QueryError:
-					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
-				default:
-					fmt.Fprintf(buf, "unrecognized event type: %d\n", obj.Type)
-				}
+				printEvent(obj, buf, verbose, pfuncMap{
+					notif.Value: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
+						fmt.Fprintf(out, "got value: '%s'\n", obj.Extra)
+					},
+				})
 				return buf, nil
 			}
 
@@ -488,7 +418,7 @@ PutValue will store the given key value pair in the dht.
 			return
 		}
 
-		dht, ok := n.Routing.(*ipdht.IpfsDHT)
+		dht, ok := core.FindDHT(n.Routing)
 		if !ok {
 			res.SetError(ErrNotDHT, cmds.ErrNormal)
 			return
@@ -537,33 +467,11 @@ PutValue will store the given key value pair in the dht.
 				}
 
 				buf := new(bytes.Buffer)
-				if verbose {
-					fmt.Fprintf(buf, "%s: ", time.Now().Format("15:04:05.000"))
-				}
-				switch obj.Type {
-				case notif.FinalPeer:
-					if verbose {
-						fmt.Fprintf(buf, "* closest peer %s\n", obj.ID)
-					}
-				case notif.PeerResponse:
-					if verbose {
-						fmt.Fprintf(buf, "* %s says use ", obj.ID)
-						for _, p := range obj.Responses {
-							fmt.Fprintf(buf, "%s ", p.ID)
-						}
-						fmt.Fprintln(buf)
-					}
-				case notif.SendingQuery:
-					if verbose {
-						fmt.Fprintf(buf, "* querying %s\n", obj.ID)
-					}
-				case notif.QueryError:
-					fmt.Fprintf(buf, "error: %s\n", obj.Extra)
-				case notif.Value:
-					fmt.Fprintf(buf, "storing value at %s\n", obj.ID)
-				default:
-					fmt.Fprintf(buf, "unrecognized event type: %d\n", obj.Type)
-				}
+				printEvent(obj, buf, verbose, pfuncMap{
+					notif.Value: func(obj *notif.QueryEvent, out io.Writer, verbose bool) {
+						fmt.Fprintf(out, "storing value at %s\n", obj.ID)
+					},
+				})
 				return buf, nil
 			}
 
@@ -575,3 +483,60 @@ PutValue will store the given key value pair in the dht.
 	},
 	Type: notif.QueryEvent{},
 }
+
+// pfuncMap holds the ways a command prints the events it treats apart from
+// the others.
+type pfuncMap map[notif.QueryEventType]func(obj *notif.QueryEvent, out io.Writer, verbose bool)
+
+// printEvent writes a query event to out, the way all dht commands do
+// unless override says otherwise. The steps of the query, and the errors
+// of single peers, are only written when verbose is set.
+func printEvent(obj *notif.QueryEvent, out io.Writer, verbose bool, override pfuncMap) {
+	if verbose {
+		t := obj.Time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		fmt.Fprintf(out, "%s: ", t.Format("15:04:05.000"))
+	}
+
+	if pf, ok := override[obj.Type]; ok {
+		pf(obj, out, verbose)
+		return
+	}
+
+	switch obj.Type {
+	case notif.SendingQuery:
+		if verbose {
+			fmt.Fprintf(out, "* querying %s\n", obj.ID)
+		}
+	case notif.DialingPeer:
+		if verbose {
+			fmt.Fprintf(out, "* dialing %s\n", obj.ID)
+		}
+	case notif.PeerResponse:
+		if verbose {
+			fmt.Fprintf(out, "* %s says use ", obj.ID)
+			for _, p := range obj.Responses {
+				fmt.Fprintf(out, "%s ", p.ID)
+			}
+			fmt.Fprintf(out, "(%s)\n", obj.Duration)
+		}
+	case notif.FoundValue:
+		if verbose {
+			fmt.Fprintf(out, "* %s has a value (%s)\n", obj.ID, obj.Duration)
+		}
+	case notif.FinalPeer:
+		if verbose {
+			fmt.Fprintf(out, "* closest peer %s\n", obj.ID)
+		}
+	case notif.QueryError:
+		if obj.ID == "" {
+			fmt.Fprintf(out, "error: %s\n", obj.Extra)
+		} else if verbose {
+			fmt.Fprintf(out, "* error from %s: %s (%s)\n", obj.ID, obj.Extra, obj.Duration)
+		}
+	default:
+		fmt.Fprintf(out, "unrecognized event type: %d\n", obj.Type)
+	}
+}
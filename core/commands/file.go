@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	path "github.com/ipfs/go-ipfs/path"
+)
+
+var FileCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Interact with unixfs files",
+		Synopsis: `
+ipfs file write <path> <data> - Write data into a file at an offset
+`,
+		ShortDescription: `
+'ipfs file' is a set of commands for working with files stored in ipfs
+as unixfs objects.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"write": fileWriteCmd,
+	},
+}
+
+type FileWriteOutput struct {
+	Hash string
+}
+
+var fileWriteCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Write data into a file at an offset",
+		ShortDescription: `
+'ipfs file write' writes the data read from <data> into the file named by
+<path>, starting at --offset, and outputs the hash of the new root of
+<path>. The existing file is not re-added: only the blocks that change are
+written.
+`,
+		LongDescription: `
+'ipfs file write' writes the data read from <data> into the file named by
+<path>, starting at --offset, and outputs the hash of the new root of
+<path>. The existing file is not re-added: only the blocks that change are
+written.
+
+Writing past the end of the file extends it, and any gap between the end
+of the file and --offset is filled with zeros. With --truncate, the file
+ends after the written data.
+
+Objects are never changed in place: the file and every directory above it
+in <path> are copied, and the root is replaced. Nothing is pinned.
+
+EXAMPLE:
+
+	$ ipfs file write /ipfs/QmRoot/log.txt --offset=1024 new-lines.txt
+	QmNewRoot
+	$ ipfs cat /ipfs/QmNewRoot/log.txt
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "The path of the file to write to"),
+		cmds.FileArg("data", true, false, "The data to write").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("offset", "o", "Byte offset to start writing at (default: 0)"),
+		cmds.BoolOption("truncate", "t", "Cut the file off after the written data"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		offset, _, err := req.Option("offset").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if offset < 0 {
+			res.SetError(errors.New("offset must not be negative"), cmds.ErrClient)
+			return
+		}
+
+		truncate, _, err := req.Option("truncate").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		file, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer file.Close()
+
+		fpath := path.Path(req.Arguments()[0])
+		root, err := coreunix.WriteAt(req.Context().Context, n, fpath, file, int64(offset), truncate)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		k, err := root.Key()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&FileWriteOutput{Hash: k.B58String()})
+	},
+	Type: FileWriteOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*FileWriteOutput)
+			return strings.NewReader(out.Hash + "\n"), nil
+		},
+	},
+}
@@ -0,0 +1,670 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	gopath "path"
+	"sort"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs/commands"
+	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
+	nsfs "github.com/ipfs/go-ipfs/ipnsfs"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+)
+
+var FilesCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manipulate the mutable filesystem of this node",
+		Synopsis: `
+ipfs files ls [<path>]            - List directory contents
+ipfs files mkdir <path>           - Make a directory
+ipfs files cp <src> <dst>         - Copy files into the filesystem
+ipfs files mv <src> <dst>         - Move files
+ipfs files rm <path>              - Remove files
+ipfs files read <path>            - Output the contents of a file
+ipfs files write <path> <data>    - Write data into a file
+ipfs files stat <path>            - Display file status
+ipfs files flush                  - Publish the filesystem root now
+`,
+		ShortDescription: `
+'ipfs files' works on the mutable filesystem published under this node's
+peer ID (/ipns/<peer-id>), the same one mounted at /ipns/local by
+'ipfs mount'. It needs no FUSE support, but must be run in online mode.
+
+Paths start at the root of the filesystem, '/'. Every change is applied
+to the root straight away and published shortly after; use
+'ipfs files flush' to publish it at once.
+`,
+	},
+
+	Subcommands: map[string]*cmds.Command{
+		"ls":    filesLsCmd,
+		"mkdir": filesMkdirCmd,
+		"cp":    filesCpCmd,
+		"mv":    filesMvCmd,
+		"rm":    filesRmCmd,
+		"read":  filesReadCmd,
+		"write": filesWriteCmd,
+		"stat":  filesStatCmd,
+		"flush": filesFlushCmd,
+	},
+}
+
+type FilesEntry struct {
+	Name string
+	Type string
+	Size uint64
+	Hash string
+}
+
+type FilesLsOutput struct {
+	Entries []FilesEntry
+}
+
+var filesLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List directory contents",
+		ShortDescription: `
+'ipfs files ls' lists the entries of the directory at <path> by name, or
+<path> itself if it is a file. With -l, the type, size and hash of every entry
+are printed as well.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", false, false, "The path to list (default: /)"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("l", "Use a long listing format"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		p := "/"
+		if len(req.Arguments()) > 0 {
+			p = req.Arguments()[0]
+		}
+
+		fsn, err := nsfs.Lookup(root, p)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var entries []FilesEntry
+		switch fsn := fsn.(type) {
+		case *nsfs.Directory:
+			names, err := fsn.List()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				child, err := fsn.Child(name)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+
+				e, err := filesEntry(name, child)
+				if err != nil {
+					res.SetError(err, cmds.ErrNormal)
+					return
+				}
+				entries = append(entries, *e)
+			}
+		default:
+			e, err := filesEntry(gopath.Base(p), fsn)
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			entries = append(entries, *e)
+		}
+
+		res.SetOutput(&FilesLsOutput{Entries: entries})
+	},
+	Type: FilesLsOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*FilesLsOutput)
+			long, _, _ := res.Request().Option("l").Bool()
+
+			var buf bytes.Buffer
+			for _, e := range out.Entries {
+				if long {
+					fmt.Fprintf(&buf, "%s\t%s\t%d\t%s\n", e.Name, e.Type, e.Size, e.Hash)
+				} else {
+					fmt.Fprintln(&buf, e.Name)
+				}
+			}
+			return &buf, nil
+		},
+	},
+}
+
+var filesMkdirCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Make a directory",
+		ShortDescription: `
+'ipfs files mkdir' creates a directory at <path>. With -p, missing parent
+directories are created as well, and an existing directory is no error.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "The path of the directory to make"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("parents", "p", "Make parent directories as needed"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		parents, _, _ := req.Option("parents").Bool()
+		err = nsfs.Mkdir(root, req.Arguments()[0], parents)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+var filesCpCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Copy files into the filesystem",
+		ShortDescription: `
+'ipfs files cp' copies <src> to <dst>. <src> may be a path in the
+filesystem, or an /ipfs/ path to bring in content from outside of it.
+Nothing is copied but a link: the content is shared.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("src", true, false, "The source to copy"),
+		cmds.StringArg("dst", true, false, "The destination path"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		src, dst := req.Arguments()[0], req.Arguments()[1]
+		nd, err := filesSourceNode(n, root, src)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if _, err := nsfs.DirLookup(root, dst); err == nil {
+			dst = gopath.Join(dst, gopath.Base(src))
+		}
+
+		err = nsfs.PutNode(root, dst, nd)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+var filesMvCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Move files",
+		ShortDescription: `
+'ipfs files mv' moves <src> to <dst>. If <dst> is an existing directory,
+<src> is moved into it.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("src", true, false, "The path to move"),
+		cmds.StringArg("dst", true, false, "The destination path"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		err = nsfs.Mv(root, req.Arguments()[0], req.Arguments()[1])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+var filesRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove files",
+		ShortDescription: `
+'ipfs files rm' removes the entry at <path>. Directories are only removed
+with -r.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, true, "The paths to remove"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("recursive", "r", "Remove directories and their contents"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		recursive, _, _ := req.Option("recursive").Bool()
+		for _, p := range req.Arguments() {
+			err = nsfs.Remove(root, p, recursive)
+			if err == nsfs.ErrIsDirectory {
+				err = fmt.Errorf("%s is a directory, use -r to remove directories", p)
+			}
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+		}
+	},
+}
+
+var filesReadCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Output the contents of a file",
+		ShortDescription: `
+'ipfs files read' outputs the contents of the file at <path>, starting at
+--offset. With --count, at most that many bytes are output.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "The path of the file to read"),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("offset", "o", "Byte offset to start reading at"),
+		cmds.IntOption("count", "n", "Maximum number of bytes to read"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fi, err := filesFile(root, req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		offset, _, err := req.Option("offset").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if offset < 0 {
+			res.SetError(errors.New("offset must not be negative"), cmds.ErrClient)
+			return
+		}
+
+		// read from a snapshot of the file, so that writes cannot move the
+		// read head under us
+		nd, err := fi.GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		dr, err := uio.NewDagReader(req.Context().Context, nd, n.DAG)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		if int64(offset) > dr.Size() {
+			res.SetError(fmt.Errorf("offset %d is past the end of the file", offset), cmds.ErrNormal)
+			return
+		}
+		_, err = dr.Seek(int64(offset), os.SEEK_SET)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		var r io.Reader = dr
+		count, found, err := req.Option("count").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if found {
+			if count < 0 {
+				res.SetError(errors.New("count must not be negative"), cmds.ErrClient)
+				return
+			}
+			r = io.LimitReader(dr, int64(count))
+		}
+
+		res.SetOutput(r)
+	},
+}
+
+var filesWriteCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Write data into a file",
+		ShortDescription: `
+'ipfs files write' writes the data read from <data> into the file at
+<path>, starting at --offset. With --create, the file is made if it does
+not exist. With --truncate, the file ends after the written data.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "The path of the file to write to"),
+		cmds.FileArg("data", true, false, "The data to write").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption("offset", "o", "Byte offset to start writing at (default: 0)"),
+		cmds.BoolOption("create", "e", "Create the file if it does not exist"),
+		cmds.BoolOption("truncate", "t", "Cut the file off after the written data"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		offset, _, err := req.Option("offset").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if offset < 0 {
+			res.SetError(errors.New("offset must not be negative"), cmds.ErrClient)
+			return
+		}
+		create, _, _ := req.Option("create").Bool()
+		truncate, _, _ := req.Option("truncate").Bool()
+
+		input, err := req.Files().NextFile()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		defer input.Close()
+
+		p := req.Arguments()[0]
+		fi, err := filesFile(root, p)
+		if err == os.ErrNotExist && create {
+			err = nsfs.PutNode(root, p, &dag.Node{Data: ft.FilePBData(nil, 0)})
+			if err == nil {
+				fi, err = filesFile(root, p)
+			}
+		}
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		err = filesWriteAt(fi, input, int64(offset), truncate)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+	},
+}
+
+// filesWriteAt writes the data read from r into fi at offset, and closes fi
+// to pass the changes up to the root, whether writing succeeded or not
+func filesWriteAt(fi *nsfs.File, r io.Reader, offset int64, truncate bool) error {
+	err := coreunix.WriteFileAt(fi, r, offset, truncate)
+	cerr := fi.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
+
+type FilesStatOutput struct {
+	Hash           string
+	Type           string
+	Size           uint64
+	CumulativeSize uint64
+	Blocks         int
+}
+
+var filesStatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Display file status",
+		ShortDescription: `
+'ipfs files stat' displays the hash, type and sizes of the file or
+directory at <path>.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "The path to stat"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		root, err := filesRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fsn, err := nsfs.Lookup(root, req.Arguments()[0])
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		nd, err := fsn.GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		st, err := nsfs.StatNode(nd)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		k, err := nd.Key()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&FilesStatOutput{
+			Hash:           k.B58String(),
+			Type:           filesTypeName(st.Type),
+			Size:           st.Size,
+			CumulativeSize: st.CumulativeSize,
+			Blocks:         st.Blocks,
+		})
+	},
+	Type: FilesStatOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*FilesStatOutput)
+
+			var buf bytes.Buffer
+			fmt.Fprintln(&buf, out.Hash)
+			fmt.Fprintf(&buf, "Size: %d\n", out.Size)
+			fmt.Fprintf(&buf, "CumulativeSize: %d\n", out.CumulativeSize)
+			fmt.Fprintf(&buf, "ChildBlocks: %d\n", out.Blocks)
+			fmt.Fprintf(&buf, "Type: %s\n", out.Type)
+			return &buf, nil
+		},
+	},
+}
+
+var filesFlushCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Publish the filesystem root now",
+		ShortDescription: `
+'ipfs files flush' publishes the current root of the filesystem right
+away, rather than waiting for the next scheduled publish, and outputs
+its hash.
+`,
+	},
+
+	Run: func(req cmds.Request, res cmds.Response) {
+		kr, err := filesKeyRoot(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		err = kr.Publish(req.Context().Context)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		nd, err := kr.GetValue().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		k, err := nd.Key()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&FileWriteOutput{Hash: k.B58String()})
+	},
+	Type: FileWriteOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*FileWriteOutput)
+			return strings.NewReader(out.Hash + "\n"), nil
+		},
+	},
+}
+
+// filesKeyRoot returns the root of the filesystem published under the
+// node's own peer ID
+func filesKeyRoot(req cmds.Request) (*nsfs.KeyRoot, error) {
+	n, err := req.Context().GetNode()
+	if err != nil {
+		return nil, err
+	}
+
+	if !n.OnlineMode() || n.IpnsFs == nil {
+		return nil, errNotOnline
+	}
+
+	return n.IpnsFs.GetRoot(n.Identity.Pretty())
+}
+
+func filesRoot(req cmds.Request) (*nsfs.Directory, error) {
+	kr, err := filesKeyRoot(req)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, ok := kr.GetValue().(*nsfs.Directory)
+	if !ok {
+		return nil, errors.New("the root of the filesystem is not a directory")
+	}
+	return dir, nil
+}
+
+func filesFile(root *nsfs.Directory, p string) (*nsfs.File, error) {
+	fsn, err := nsfs.Lookup(root, p)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, ok := fsn.(*nsfs.File)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a file", p)
+	}
+	return fi, nil
+}
+
+// filesSourceNode returns the node at src, which is either an /ipfs/ path or
+// a path in the filesystem
+func filesSourceNode(n *core.IpfsNode, root *nsfs.Directory, src string) (*dag.Node, error) {
+	if strings.HasPrefix(src, "/ipfs/") {
+		return core.Resolve(n, path.Path(src))
+	}
+
+	fsn, err := nsfs.Lookup(root, src)
+	if err != nil {
+		return nil, err
+	}
+	return fsn.GetNode()
+}
+
+func filesEntry(name string, fsn nsfs.FSNode) (*FilesEntry, error) {
+	nd, err := fsn.GetNode()
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := nsfs.StatNode(nd)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := nd.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FilesEntry{
+		Name: name,
+		Type: filesTypeName(st.Type),
+		Size: st.Size,
+		Hash: k.B58String(),
+	}, nil
+}
+
+func filesTypeName(t nsfs.NodeType) string {
+	if t == nsfs.TDir {
+		return "directory"
+	}
+	return "file"
+}
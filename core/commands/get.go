@@ -1,15 +1,20 @@
 package commands
 
 import (
+	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	gopath "path"
+	fp "path/filepath"
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	files "github.com/ipfs/go-ipfs/commands/files"
 	core "github.com/ipfs/go-ipfs/core"
 	path "github.com/ipfs/go-ipfs/path"
 	tar "github.com/ipfs/go-ipfs/thirdparty/tar"
@@ -19,6 +24,7 @@ import (
 )
 
 var ErrInvalidCompressionLevel = errors.New("Compression level must be between 1 and 9")
+var ErrInvalidArchiveFormat = errors.New("Archive format must be either 'tar' or 'zip'")
 
 var GetCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
@@ -30,6 +36,35 @@ By default, the output will be stored at ./<ipfs-path>, but an alternate path
 can be specified with '--output=<path>' or '-o=<path>'.
 
 To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
+For a ZIP archive, use '--archive=zip'.
+
+To compress the output with GZIP compression, use '--compress' or '-C'. You
+may also specify the level of compression by specifying '-l=<1-9>'.
+`,
+		LongDescription: `
+Retrieves the object named by <ipfs-or-ipns-path> and stores the data to disk.
+
+By default, the output will be stored at ./<ipfs-path>, but an alternate path
+can be specified with '--output=<path>' or '-o=<path>'.
+
+If the output path is an existing directory, the output is stored inside
+of it. 'ipfs get' fails rather than overwrite a file at the output path, use
+'--force' to overwrite it.
+
+An interrupted 'ipfs get' can be continued by running it again with
+'--continue'. The output path is then used as it is. Files that are already
+present with the same contents are skipped, and files holding the beginning
+of the ones being retrieved are continued where they stopped. Other files
+already present are an error, unless '--force' is given. Only files added
+with the default chunker and layout are recognized this way.
+
+To retrieve only part of a directory, pass a comma separated list of patterns
+to '--include' or '-i'. Patterns use shell syntax (as in 'a/*.txt'), and are
+matched against paths relative to the retrieved object. A pattern matching a
+directory includes everything below it.
+
+To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
+For a ZIP archive, use '--archive=zip'.
 
 To compress the output with GZIP compression, use '--compress' or '-C'. You
 may also specify the level of compression by specifying '-l=<1-9>'.
@@ -41,13 +76,47 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 	},
 	Options: []cmds.Option{
 		cmds.StringOption("output", "o", "The path where output should be stored"),
-		cmds.BoolOption("archive", "a", "Output a TAR archive"),
+		cmds.StringOption("archive", "a", "Output an archive instead of files, either 'tar' (default) or 'zip'"),
 		cmds.BoolOption("compress", "C", "Compress the output with GZIP compression"),
 		cmds.IntOption("compression-level", "l", "The level of compression (1-9)"),
+		cmds.StringOption("include", "i", "Comma separated patterns of the paths to retrieve"),
+		cmds.BoolOption("continue", "Continue an interrupted download to the output path"),
+		cmds.BoolOption("force", "f", "Overwrite files that already exist at the output path"),
 	},
 	PreRun: func(req cmds.Request) error {
 		_, err := getCompressOptions(req)
-		return err
+		if err != nil {
+			return err
+		}
+
+		archive, _, err := getArchiveOptions(req)
+		if err != nil || archive {
+			return err
+		}
+
+		cont, _, err := req.Option("continue").Bool()
+		if err != nil || !cont {
+			return err
+		}
+
+		// tell the daemon which files we already have, so it can leave
+		// them out of the archive
+		have, err := getLocalFiles(getOutPath(req), getIncludes(req))
+		if err != nil {
+			return err
+		}
+		if len(have) == 0 {
+			return nil
+		}
+
+		data, err := json.Marshal(have)
+		if err != nil {
+			return err
+		}
+
+		manifest := files.NewReaderFile("have.json", ioutil.NopCloser(bytes.NewReader(data)), nil)
+		req.SetFiles(files.NewSliceFile("", []files.File{manifest}))
+		return nil
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		cmplvl, err := getCompressOptions(req)
@@ -62,11 +131,44 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			return
 		}
 
-		reader, err := get(node, req.Arguments()[0], cmplvl)
+		archive, format, err := getArchiveOptions(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		opts := utar.Options{
+			Format:  format,
+			Include: getIncludes(req),
+		}
+		if !archive && req.Files() != nil {
+			manifest, err := req.Files().NextFile()
+			switch {
+			case err == io.EOF:
+				// nothing is there yet
+			case err != nil:
+				res.SetError(err, cmds.ErrNormal)
+				return
+			default:
+				err = json.NewDecoder(manifest).Decode(&opts.Have)
+				if err != nil {
+					res.SetError(err, cmds.ErrClient)
+					return
+				}
+			}
+		}
+
+		reader, err := get(node, req.Arguments()[0], cmplvl, opts)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
 		}
+		go func() {
+			// stop writing the archive once the request is over, whether
+			// or not it was read to its end
+			<-req.Context().Context.Done()
+			reader.Close()
+		}()
 		res.SetOutput(reader)
 	},
 	PostRun: func(req cmds.Request, res cmds.Response) {
@@ -76,11 +178,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		outReader := res.Output().(io.Reader)
 		res.SetOutput(nil)
 
-		outPath, _, _ := req.Option("output").String()
-		if len(outPath) == 0 {
-			_, outPath = gopath.Split(req.Arguments()[0])
-			outPath = gopath.Clean(outPath)
-		}
+		outPath := getOutPath(req)
 
 		cmplvl, err := getCompressOptions(req)
 		if err != nil {
@@ -88,12 +186,26 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			return
 		}
 
-		if archive, _, _ := req.Option("archive").Bool(); archive {
-			if !strings.HasSuffix(outPath, ".tar") {
-				outPath += ".tar"
-			}
-			if cmplvl != gzip.NoCompression {
-				outPath += ".gz"
+		archive, format, err := getArchiveOptions(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		if archive {
+			switch {
+			case format == utar.Zip:
+				// zip compresses each file by itself
+				if !strings.HasSuffix(outPath, ".zip") {
+					outPath += ".zip"
+				}
+			default:
+				if !strings.HasSuffix(outPath, ".tar") {
+					outPath += ".tar"
+				}
+				if cmplvl != gzip.NoCompression {
+					outPath += ".gz"
+				}
 			}
 			fmt.Printf("Saving archive to %s\n", outPath)
 
@@ -143,7 +255,9 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		bar.Start()
 		defer bar.Finish()
 
-		extractor := &tar.Extractor{outPath}
+		cont, _, _ := req.Option("continue").Bool()
+		force, _, _ := req.Option("force").Bool()
+		extractor := &tar.Extractor{Path: outPath, Continue: cont, Overwrite: force}
 		err = extractor.Extract(reader)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
@@ -165,12 +279,96 @@ func getCompressOptions(req cmds.Request) (int, error) {
 	return gzip.NoCompression, nil
 }
 
-func get(node *core.IpfsNode, p string, compression int) (io.Reader, error) {
+// getArchiveOptions returns whether an archive was requested, and its format
+func getArchiveOptions(req cmds.Request) (bool, utar.Format, error) {
+	archive, found, err := req.Option("archive").String()
+	if err != nil || !found {
+		return false, utar.Tar, err
+	}
+
+	switch strings.ToLower(archive) {
+	case "", "true", "tar":
+		return true, utar.Tar, nil
+	case "zip":
+		return true, utar.Zip, nil
+	case "false":
+		return false, utar.Tar, nil
+	}
+	return false, utar.Tar, ErrInvalidArchiveFormat
+}
+
+func getOutPath(req cmds.Request) string {
+	outPath, _, _ := req.Option("output").String()
+	if len(outPath) == 0 {
+		_, outPath = gopath.Split(req.Arguments()[0])
+		outPath = gopath.Clean(outPath)
+	}
+	return outPath
+}
+
+func getIncludes(req cmds.Request) []string {
+	include, _, _ := req.Option("include").String()
+	if len(include) == 0 {
+		return nil
+	}
+	return strings.Split(include, ",")
+}
+
+// getLocalFiles describes the files under outPath that match the include
+// patterns, keyed by their path relative to outPath
+func getLocalFiles(outPath string, include []string) (map[string]utar.LocalFile, error) {
+	if _, err := os.Stat(outPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	have := make(map[string]utar.LocalFile)
+	err := fp.Walk(outPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := fp.Rel(outPath, p)
+		if err != nil {
+			return err
+		}
+		rel = fp.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+
+		if !utar.Included(include, rel) {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h, err := utar.HashFile(f)
+		if err != nil {
+			return err
+		}
+
+		have[rel] = utar.LocalFile{
+			Size: uint64(info.Size()),
+			Hash: h,
+		}
+		return nil
+	})
+	return have, err
+}
+
+func get(node *core.IpfsNode, p string, compression int, opts utar.Options) (*utar.Reader, error) {
 	pathToResolve := path.Path(p)
 	dagnode, err := core.Resolve(node, pathToResolve)
 	if err != nil {
 		return nil, err
 	}
 
-	return utar.NewReader(pathToResolve, node.DAG, dagnode, compression)
+	return utar.NewReaderWithOptions(pathToResolve, node.DAG, dagnode, compression, opts)
 }
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"text/tabwriter"
@@ -14,15 +15,25 @@ import (
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
 	unixfs "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	unixfspb "github.com/ipfs/go-ipfs/unixfs/pb"
+	u "github.com/ipfs/go-ipfs/util"
 )
 
+// LsTypeUnknown is reported as the type of links whose target was not
+// fetched, see the --resolve-type option.
+const LsTypeUnknown unixfspb.Data_DataType = -1
+
 type LsLink struct {
 	Name, Hash string
 	Size       uint64
 	Type       unixfspb.Data_DataType
 }
 
+// LsObject is a (partial) listing of the object named by Hash. With
+// --stream, ls outputs a sequence of LsOutputs holding a single LsObject
+// each: the listing of each object starts with an LsObject without links,
+// followed by one for every entry.
 type LsObject struct {
 	Hash  string
 	Links []LsLink
@@ -32,6 +43,9 @@ type LsOutput struct {
 	Objects []LsObject
 }
 
+// errLsLimit stops a listing once --limit entries have been sent
+var errLsLimit = errors.New("ls: limit reached")
+
 var LsCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "List links from an object.",
@@ -40,6 +54,21 @@ Retrieves the object named by <ipfs-or-ipns-path> and displays the links
 it contains, with the following format:
 
   <link base58 hash> <link size in bytes> <link name>
+`,
+		LongDescription: `
+Retrieves the object named by <ipfs-or-ipns-path> and displays the links
+it contains, with the following format:
+
+  <link base58 hash> <link size in bytes> <link name>
+
+Directories are listed with a trailing slash. With --recursive, the contents
+of subdirectories are listed as well, down to --depth levels, with names
+relative to the listed object.
+
+Entries are produced as they are found. Use --offset and --limit to page
+through large directories, and --stream to print entries as soon as they
+arrive instead of aligning them in a table. --resolve-type=false avoids
+fetching linked objects that are only needed to find out their type.
 `,
 	},
 
@@ -47,7 +76,13 @@ it contains, with the following format:
 		cmds.StringArg("ipfs-path", true, true, "The path to the IPFS object(s) to list links from").EnableStdin(),
 	},
 	Options: []cmds.Option{
-		cmds.BoolOption("headers", "", "Print table headers (Hash, Name, Size)"),
+		cmds.BoolOption("headers", "", "Print table headers (Hash, Size, Name)"),
+		cmds.BoolOption("recursive", "r", "List the contents of subdirectories"),
+		cmds.IntOption("depth", "d", "Maximum depth to list with --recursive (default: unlimited)"),
+		cmds.IntOption("offset", "Number of entries to skip for each object"),
+		cmds.IntOption("limit", "Maximum number of entries to list for each object"),
+		cmds.BoolOption("resolve-type", "Resolve linked objects to find out their types (default: true)"),
+		cmds.BoolOption("stream", "s", "Print entries as they arrive, without aligning them"),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		node, err := req.Context().GetNode()
@@ -62,6 +97,58 @@ it contains, with the following format:
 			return
 		}
 
+		if _, _, err := req.Option("stream").Bool(); err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		recursive, _, err := req.Option("recursive").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		depth := 1
+		if recursive {
+			depth, _, err = req.Option("depth").Int()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			if depth == 0 {
+				depth = -1
+			}
+		}
+
+		offset, _, err := req.Option("offset").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		limit, found, err := req.Option("limit").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			limit = -1
+		}
+
+		if offset < 0 || (found && limit < 0) {
+			res.SetError(errors.New("offset and limit must not be negative"), cmds.ErrClient)
+			return
+		}
+
+		resolveType, found, err := req.Option("resolve-type").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			resolveType = true
+		}
+
 		paths := req.Arguments()
 
 		dagnodes := make([]*merkledag.Node, 0)
@@ -74,57 +161,99 @@ it contains, with the following format:
 			dagnodes = append(dagnodes, dagnode)
 		}
 
-		output := make([]LsObject, len(req.Arguments()))
-		for i, dagnode := range dagnodes {
-			output[i] = LsObject{
-				Hash:  paths[i],
-				Links: make([]LsLink, len(dagnode.Links)),
-			}
-			for j, link := range dagnode.Links {
-				ctx, cancel := context.WithTimeout(context.TODO(), time.Minute)
-				defer cancel()
-				link.Node, err = link.GetNode(ctx, node.DAG)
-				if err != nil {
-					res.SetError(err, cmds.ErrNormal)
-					return
+		ctx := req.Context().Context
+		list := func(out func(*LsObject) error) error {
+			for i, dagnode := range dagnodes {
+				w := &lsWalker{
+					ctx:         ctx,
+					dserv:       node.DAG,
+					out:         out,
+					object:      paths[i],
+					resolveType: resolveType,
+					maxDepth:    depth,
+					offset:      offset,
+					limit:       limit,
 				}
-				d, err := unixfs.FromBytes(link.Node.Data)
-				if err != nil {
-					res.SetError(err, cmds.ErrNormal)
-					return
+
+				err := out(&LsObject{Hash: paths[i]})
+				if err == nil {
+					err = w.walk(dagnode, "", 1)
 				}
-				output[i].Links[j] = LsLink{
-					Name: link.Name,
-					Hash: link.Hash.B58String(),
-					Size: link.Size,
-					Type: d.GetType(),
+				if err != nil && err != errLsLimit {
+					return err
 				}
 			}
+			return nil
+		}
+
+		if stream, _, _ := req.Option("stream").Bool(); !stream {
+			output := new(LsOutput)
+			err := list(func(obj *LsObject) error {
+				output.add(obj)
+				return nil
+			})
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			res.SetOutput(output)
+			return
 		}
 
-		res.SetOutput(&LsOutput{output})
+		outChan := make(chan interface{})
+		res.SetOutput((<-chan interface{})(outChan))
+
+		go func() {
+			defer close(outChan)
+
+			send := func(v interface{}) error {
+				select {
+				case outChan <- v:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			err := list(func(obj *LsObject) error {
+				return send(&LsOutput{Objects: []LsObject{*obj}})
+			})
+			if err != nil {
+				// the marshalers return it, failing the command
+				send(err)
+			}
+		}()
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
-
 			headers, _, _ := res.Request().Option("headers").Bool()
-			output := res.Output().(*LsOutput)
+			multiple := len(res.Request().Arguments()) > 1
+
+			if outChan, ok := res.Output().(<-chan interface{}); ok {
+				return &cmds.ChannelMarshaler{
+					Channel:   outChan,
+					Marshaler: lsStreamMarshaler(headers, multiple),
+				}, nil
+			}
+
+			output, ok := res.Output().(*LsOutput)
+			if !ok {
+				return nil, u.ErrCast()
+			}
+
 			var buf bytes.Buffer
 			w := tabwriter.NewWriter(&buf, 1, 2, 1, ' ', 0)
 			for _, object := range output.Objects {
-				if len(output.Objects) > 1 {
+				if multiple {
 					fmt.Fprintf(w, "%s:\n", object.Hash)
 				}
 				if headers {
 					fmt.Fprintln(w, "Hash\tSize\tName\t")
 				}
 				for _, link := range object.Links {
-					if link.Type == unixfspb.Data_Directory {
-						link.Name += "/"
-					}
-					fmt.Fprintf(w, "%s\t%v\t%s\t\n", link.Hash, link.Size, link.Name)
+					fmt.Fprintf(w, "%s\t%v\t%s\t\n", link.Hash, link.Size, lsLinkName(link))
 				}
-				if len(output.Objects) > 1 {
+				if multiple {
 					fmt.Fprintln(w)
 				}
 			}
@@ -132,6 +261,156 @@ it contains, with the following format:
 
 			return &buf, nil
 		},
+		cmds.JSON: streamJSONMarshaler,
 	},
 	Type: LsOutput{},
 }
+
+// add appends obj to the listing, merging it into the last object unless it
+// starts a new one
+func (o *LsOutput) add(obj *LsObject) {
+	last := len(o.Objects) - 1
+	if len(obj.Links) == 0 || last < 0 {
+		o.Objects = append(o.Objects, *obj)
+		return
+	}
+	o.Objects[last].Links = append(o.Objects[last].Links, obj.Links...)
+}
+
+// lsStreamMarshaler returns a marshaler printing each entry as its own line,
+// and a header whenever a new object starts
+func lsStreamMarshaler(headers, multiple bool) func(interface{}) (io.Reader, error) {
+	started := false
+	return func(v interface{}) (io.Reader, error) {
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		output, ok := v.(*LsOutput)
+		if !ok {
+			return nil, u.ErrCast()
+		}
+
+		buf := new(bytes.Buffer)
+		for _, obj := range output.Objects {
+			if len(obj.Links) == 0 {
+				if started && multiple {
+					fmt.Fprintln(buf)
+				}
+				if multiple {
+					fmt.Fprintf(buf, "%s:\n", obj.Hash)
+				}
+				if headers {
+					fmt.Fprintln(buf, "Hash Size Name")
+				}
+				started = true
+			}
+
+			for _, link := range obj.Links {
+				fmt.Fprintf(buf, "%s %v %s\n", link.Hash, link.Size, lsLinkName(link))
+			}
+		}
+		return buf, nil
+	}
+}
+
+func lsLinkName(link LsLink) string {
+	if link.Type == unixfspb.Data_Directory || link.Type == unixfspb.Data_HAMTShard {
+		return link.Name + "/"
+	}
+	return link.Name
+}
+
+// lsWalker lists the links of a single object, sending them one at a time
+type lsWalker struct {
+	ctx   context.Context
+	dserv merkledag.DAGService
+	out   func(*LsObject) error
+
+	// the argument the listed object was named by
+	object string
+
+	resolveType bool
+
+	// maxDepth is the number of levels to list, or -1 for no limit
+	maxDepth int
+
+	offset, limit int
+
+	// seen counts the entries visited, sent those actually output
+	seen, sent int
+}
+
+func (w *lsWalker) walk(nd *merkledag.Node, prefix string, depth int) error {
+	if !uio.IsDirectory(nd) {
+		// list the raw links of other objects, without descending
+		for _, l := range nd.Links {
+			err := w.visit(l, prefix, depth, false)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	dir, err := uio.NewDirectoryFromNode(w.dserv, nd)
+	if err != nil {
+		return err
+	}
+
+	recurse := w.maxDepth < 0 || depth < w.maxDepth
+	return dir.ForEachLink(w.ctx, func(l *merkledag.Link) error {
+		return w.visit(l, prefix, depth, recurse)
+	})
+}
+
+// visit outputs a single link if it is within the requested page, and lists
+// the directory it points to if recurse is set
+func (w *lsWalker) visit(l *merkledag.Link, prefix string, depth int, recurse bool) error {
+	if w.limit >= 0 && w.sent >= w.limit {
+		return errLsLimit
+	}
+
+	index := w.seen
+	w.seen++
+	emit := index >= w.offset
+
+	typ := LsTypeUnknown
+	var child *merkledag.Node
+	if (emit && w.resolveType) || recurse {
+		ctx, cancel := context.WithTimeout(w.ctx, time.Minute)
+		defer cancel()
+
+		var err error
+		child, err = l.GetNode(ctx, w.dserv)
+		if err != nil {
+			return err
+		}
+
+		d, err := unixfs.FromBytes(child.Data)
+		if err != nil {
+			return err
+		}
+		typ = d.GetType()
+	}
+
+	if emit {
+		err := w.out(&LsObject{
+			Hash: w.object,
+			Links: []LsLink{{
+				Name: prefix + l.Name,
+				Hash: l.Hash.B58String(),
+				Size: l.Size,
+				Type: typ,
+			}},
+		})
+		if err != nil {
+			return err
+		}
+		w.sent++
+	}
+
+	if recurse && (typ == unixfspb.Data_Directory || typ == unixfspb.Data_HAMTShard) {
+		return w.walk(child, prefix+l.Name+"/", depth+1)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,13 +10,18 @@ import (
 	"io/ioutil"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
 	dag "github.com/ipfs/go-ipfs/merkledag"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
 	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	u "github.com/ipfs/go-ipfs/util"
 )
 
 // ErrObjectTooLarge is returned when too much data was read from stdin. current limit 512k
@@ -45,11 +51,15 @@ var ObjectCmd = &cmds.Command{
 'ipfs object' is a plumbing command used to manipulate DAG objects
 directly.`,
 		Synopsis: `
-ipfs object get <key>   - Get the DAG node named by <key>
-ipfs object put <data>  - Stores input, outputs its key
-ipfs object data <key>  - Outputs raw bytes in an object
-ipfs object links <key> - Outputs links pointed to by object
-ipfs object stat <key>  - Outputs statistics of object
+ipfs object get <key>       - Get the DAG node named by <key>
+ipfs object put <data>      - Stores input, outputs its key
+ipfs object data <key>      - Outputs raw bytes in an object
+ipfs object links <key>     - Outputs links pointed to by object
+ipfs object stat <key>      - Outputs statistics of object
+ipfs object new <template>  - Create new ipfs objects
+ipfs object patch <args>    - Create new object from old ones
+ipfs object diff <a> <b>    - Display the changes between two objects
+ipfs object proof <path>    - Output the blocks proving a path
 `,
 	},
 
@@ -59,6 +69,10 @@ ipfs object stat <key>  - Outputs statistics of object
 		"get":   objectGetCmd,
 		"put":   objectPutCmd,
 		"stat":  objectStatCmd,
+		"new":   objectNewCmd,
+		"patch": objectPatchCmd,
+		"diff":  objectDiffCmd,
+		"proof": objectProofCmd,
 	},
 }
 
@@ -159,14 +173,37 @@ flag. It outputs to stdout, and <key> is a base58 encoded multihash.
 
 This command outputs data in the following encodings:
   * "protobuf"
+  * "cbor"
   * "json"
   * "xml"
-(Specified by the "--encoding" or "-enc" flag)`,
+(Specified by the "--encoding" or "-enc" flag)
+
+The "protobuf" and "cbor" encodings output the exact bytes of the object.
+In the "json" and "xml" encodings, the data field is output as text by
+default, which cannot represent arbitrary binary data. Use
+"--datafieldenc=base64" to output it base64 encoded instead.`,
 	},
 
 	Arguments: []cmds.Argument{
 		cmds.StringArg("key", true, false, "Key of the object to retrieve (in base58-encoded multihash format)").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		cmds.StringOption("datafieldenc", "Encoding type of the data field, either \"text\" or \"base64\""),
+	},
+	PreRun: func(req cmds.Request) error {
+		// the data is sent as json from the daemon, make sure binary
+		// encodings get every byte of it
+		if _, found, _ := req.Option("datafieldenc").String(); found {
+			return nil
+		}
+
+		enc, _, _ := req.Option(cmds.EncShort).String()
+		switch enc {
+		case objectEncodingProtobuf, objectEncodingCBOR:
+			req.SetOption("datafieldenc", dataFieldEncodingBase64)
+		}
+		return nil
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.Context().GetNode()
 		if err != nil {
@@ -174,6 +211,12 @@ This command outputs data in the following encodings:
 			return
 		}
 
+		dataenc, err := getDataFieldEnc(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
 		fpath := path.Path(req.Arguments()[0])
 
 		object, err := objectGet(n, fpath)
@@ -182,9 +225,15 @@ This command outputs data in the following encodings:
 			return
 		}
 
+		data, err := encodeDataField(object.Data, dataenc)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
 		node := &Node{
 			Links: make([]Link, len(object.Links)),
-			Data:  string(object.Data),
+			Data:  data,
 		}
 
 		for i, link := range object.Links {
@@ -200,8 +249,7 @@ This command outputs data in the following encodings:
 	Type: Node{},
 	Marshalers: cmds.MarshalerMap{
 		cmds.EncodingType("protobuf"): func(res cmds.Response) (io.Reader, error) {
-			node := res.Output().(*Node)
-			object, err := deserializeNode(node)
+			object, err := objectFromResponse(res)
 			if err != nil {
 				return nil, err
 			}
@@ -212,6 +260,18 @@ This command outputs data in the following encodings:
 			}
 			return bytes.NewReader(marshaled), nil
 		},
+		cmds.EncodingType("cbor"): func(res cmds.Response) (io.Reader, error) {
+			object, err := objectFromResponse(res)
+			if err != nil {
+				return nil, err
+			}
+
+			marshaled, err := object.MarshalCBOR()
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(marshaled), nil
+		},
 	},
 }
 
@@ -290,7 +350,11 @@ It reads from stdin, and the output is a base58 encoded multihash.
 Data should be in the format specified by the --inputenc flag.
 --inputenc may be one of the following:
 	* "protobuf"
+	* "cbor"
 	* "json" (default)
+
+In json input, the data field is read as text. To store arbitrary binary
+data, base64 encode it and use "--datafieldenc=base64".
 `,
 	},
 
@@ -298,7 +362,8 @@ Data should be in the format specified by the --inputenc flag.
 		cmds.FileArg("data", true, false, "Data to be stored as a DAG object").EnableStdin(),
 	},
 	Options: []cmds.Option{
-		cmds.StringOption("inputenc", "Encoding type of input data, either \"protobuf\" or \"json\""),
+		cmds.StringOption("inputenc", "Encoding type of input data, either \"protobuf\", \"cbor\" or \"json\""),
+		cmds.StringOption("datafieldenc", "Encoding type of the data field in json input, either \"text\" or \"base64\""),
 	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		n, err := req.Context().GetNode()
@@ -322,10 +387,16 @@ Data should be in the format specified by the --inputenc flag.
 			inputenc = "json"
 		}
 
-		output, err := objectPut(n, input, inputenc)
+		dataenc, err := getDataFieldEnc(req)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		output, err := objectPut(n, input, inputenc, dataenc)
 		if err != nil {
 			errType := cmds.ErrNormal
-			if err == ErrUnknownObjectEnc {
+			if err == ErrUnknownObjectEnc || err == ErrUnknownDataFieldEnc {
 				errType = cmds.ErrClient
 			}
 			res.SetError(err, errType)
@@ -343,9 +414,458 @@ Data should be in the format specified by the --inputenc flag.
 	Type: Object{},
 }
 
+var objectPatchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create a new merkledag object based on an existing one",
+		ShortDescription: `
+'ipfs object patch <root> <cmd> <args>' is a plumbing command used to
+build custom DAG objects. It applies a change to the object named by
+<root> and the objects below it, and outputs the key of the new root.
+
+Changes may be made to objects deep below the root, by giving a path
+relative to it. All objects along the way are rebuilt.
+
+Commands are:
+  add-link <path> <ref>       - link the object <ref> under <path>
+  rm-link <path>              - remove the link at <path>
+  set-data [<path>] <data>    - set the data of the object at <path>
+  append-data [<path>] <data> - append to the data of the object at <path>
+
+Without a <path>, set-data and append-data change the root itself.
+`,
+		LongDescription: `
+'ipfs object patch <root> <cmd> <args>' is a plumbing command used to
+build custom DAG objects. It applies a change to the object named by
+<root> and the objects below it, and outputs the key of the new root.
+
+Changes may be made to objects deep below the root, by giving a path
+relative to it. All objects along the way are rebuilt. Links in unixfs
+directories are looked up by their entry names, also in sharded ones.
+
+Commands are:
+  add-link <path> <ref>       - link the object <ref> under <path>
+  rm-link <path>              - remove the link at <path>
+  set-data [<path>] <data>    - set the data of the object at <path>
+  append-data [<path>] <data> - append to the data of the object at <path>
+
+Without a <path>, set-data and append-data change the root itself. With
+--create, add-link makes empty directories for any missing objects on
+the way to <path>.
+
+EXAMPLES:
+
+    EMPTY_DIR=$(ipfs object new unixfs-dir)
+    BAR=$(echo "bar" | ipfs add -q)
+    ipfs object patch $EMPTY_DIR add-link -p foo/bar $BAR
+
+This takes an empty directory, and adds a link named foo under it, to a
+new directory holding a link named bar to the file BAR.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The key of the object to modify"),
+		cmds.StringArg("command", true, false, "The operation to perform"),
+		cmds.StringArg("args", true, true, "Extra arguments"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("create", "p", "Create intermediate directories for add-link"),
+	},
+	Type: Object{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		create, _, err := req.Option("create").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		args := req.Arguments()
+		root, err := core.ResolveObject(n, path.Path(args[0]))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context().Context, time.Minute)
+		defer cancel()
+
+		output, err := objectPatch(ctx, n, root, args[1], args[2:], create)
+		if err != nil {
+			errType := cmds.ErrNormal
+			if err == ErrUnknownPatchCmd || err == ErrPatchArgs {
+				errType = cmds.ErrClient
+			}
+			res.SetError(err, errType)
+			return
+		}
+
+		res.SetOutput(output)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			object := res.Output().(*Object)
+			return strings.NewReader(object.Hash + "\n"), nil
+		},
+	},
+}
+
+var objectNewCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Creates a new object from an ipfs template",
+		ShortDescription: `
+'ipfs object new' is a plumbing command for creating new DAG nodes.
+`,
+		LongDescription: `
+'ipfs object new' is a plumbing command for creating new DAG nodes.
+By default it creates and returns a new empty merkledag node, but
+you may pass an optional template argument to create a preformatted
+node.
+
+Available templates:
+	* unixfs-dir
+	* symlink <target>
+
+A symlink points at an /ipfs/ or /ipns/ path, or at a path relative to the
+directory it is linked from, and is followed when paths are resolved.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("template", false, false, "optional template to use"),
+		cmds.StringArg("target", false, false, "target path for the symlink template"),
+	},
+	Type: Object{},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		node := new(dag.Node)
+		if args := req.Arguments(); len(args) > 0 {
+			node, err = nodeFromTemplate(args[0], args[1:]...)
+			if err != nil {
+				res.SetError(err, cmds.ErrClient)
+				return
+			}
+		}
+
+		_, err = n.DAG.Add(node)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		output, err := getOutput(node)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		res.SetOutput(output)
+	},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			object := res.Output().(*Object)
+			return strings.NewReader(object.Hash + "\n"), nil
+		},
+	},
+}
+
+// ObjectChange is a single difference reported by 'ipfs object diff'
+type ObjectChange struct {
+	Type   string
+	Path   string
+	Before string `json:",omitempty"`
+	After  string `json:",omitempty"`
+}
+
+type ObjectDiffOutput struct {
+	Changes []ObjectChange
+}
+
+var objectDiffCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Display the changes between two DAG objects",
+		ShortDescription: `
+'ipfs object diff' is a command used to show the differences between
+two DAG objects. It lists the paths that were added, removed or
+modified between <obj_a> and <obj_b>, with their hashes.
+`,
+		LongDescription: `
+'ipfs object diff' is a command used to show the differences between
+two DAG objects. It lists the paths that were added, removed or
+modified between <obj_a> and <obj_b>, with their hashes. Parts of the
+DAGs that are the same on both sides are not fetched.
+
+By default, objects are compared by their links. Objects whose links
+cannot be told apart by name, such as the blocks of a file, are reported
+as modified when those links differ. Use --unixfs to compare
+unixfs directories by their entries, reporting the paths of the files
+that changed. Added and removed directories are then listed file by file,
+and empty ones by themselves.
+
+EXAMPLES:
+
+    $ ipfs object diff --unixfs $RELEASE_1 $RELEASE_2
+    + docs/new.txt QmNew
+    - old.txt QmOld
+    ~ readme.md QmBefore QmAfter
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("obj_a", true, false, "object to diff against"),
+		cmds.StringArg("obj_b", true, false, "object to diff"),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("unixfs", "u", "Compare unixfs directories by their entries"),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		unixfs, _, err := req.Option("unixfs").Bool()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		a, err := core.ResolveObject(n, path.Path(req.Arguments()[0]))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		b, err := core.ResolveObject(n, path.Path(req.Arguments()[1]))
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		diff := dagutils.Diff
+		if unixfs {
+			diff = dagutils.DiffUnixfs
+		}
+
+		changes, err := diff(req.Context().Context, n.DAG, a, b)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		out := &ObjectDiffOutput{Changes: make([]ObjectChange, len(changes))}
+		for i, c := range changes {
+			out.Changes[i] = ObjectChange{
+				Type: c.Type.String(),
+				Path: c.Path,
+			}
+			if c.Before != "" {
+				out.Changes[i].Before = c.Before.B58String()
+			}
+			if c.After != "" {
+				out.Changes[i].After = c.After.B58String()
+			}
+		}
+		res.SetOutput(out)
+	},
+	Type: ObjectDiffOutput{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			out := res.Output().(*ObjectDiffOutput)
+
+			var buf bytes.Buffer
+			for _, c := range out.Changes {
+				p := c.Path
+				if p == "" {
+					p = "/"
+				}
+
+				switch c.Type {
+				case dagutils.Add.String():
+					fmt.Fprintf(&buf, "+ %s %s\n", p, c.After)
+				case dagutils.Remove.String():
+					fmt.Fprintf(&buf, "- %s %s\n", p, c.Before)
+				default:
+					fmt.Fprintf(&buf, "~ %s %s %s\n", p, c.Before, c.After)
+				}
+			}
+			return &buf, nil
+		},
+	},
+}
+
+type ObjectProof struct {
+	Path   string
+	Blocks [][]byte
+}
+
+var objectProofCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Output the blocks proving that a path exists",
+		ShortDescription: `
+'ipfs object proof <root>/<path>' outputs the blocks along <path>, which
+prove that the object it names is reachable from <root>. Anyone holding
+the proof and trusting <root> can check this without fetching anything
+else.
+`,
+		LongDescription: `
+'ipfs object proof <root>/<path>' outputs the blocks along <path>, which
+prove that the object it names is reachable from <root>. Anyone holding
+the proof and trusting <root> can check this without fetching anything
+else.
+
+The proof holds the encoded objects along the path, root first, including
+the shards of any sharded directories and the target object itself. By
+default, their keys and sizes are printed. Use --enc=json to output the
+blocks, base64 encoded. Proofs are checked with path.VerifyProof.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "The /ipfs/ path to prove").EnableStdin(),
+	},
+	Run: func(req cmds.Request, res cmds.Response) {
+		n, err := req.Context().GetNode()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		fpath := path.Path(req.Arguments()[0])
+		if segs := fpath.Segments(); len(segs) > 0 && segs[0] == "ipns" {
+			res.SetError(errors.New("proofs can only be made for /ipfs/ paths"), cmds.ErrClient)
+			return
+		}
+
+		r := &path.Resolver{DAG: n.DAG}
+		proof, err := r.Prove(fpath)
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+
+		res.SetOutput(&ObjectProof{
+			Path:   fpath.String(),
+			Blocks: proof,
+		})
+	},
+	Type: ObjectProof{},
+	Marshalers: cmds.MarshalerMap{
+		cmds.Text: func(res cmds.Response) (io.Reader, error) {
+			proof := res.Output().(*ObjectProof)
+
+			var buf bytes.Buffer
+			for _, b := range proof.Blocks {
+				fmt.Fprintf(&buf, "%s %d\n", u.Key(u.Hash(b)).B58String(), len(b))
+			}
+			return &buf, nil
+		},
+	},
+}
+
+// ErrUnknownPatchCmd is returned for unknown 'ipfs object patch' commands
+var ErrUnknownPatchCmd = errors.New("unknown patch command, must be one of add-link, rm-link, set-data or append-data")
+
+// ErrPatchArgs is returned when a patch command gets the wrong arguments
+var ErrPatchArgs = errors.New("wrong number of arguments for patch command")
+
+// objectPatch applies a single patch command to the DAG below root
+func objectPatch(ctx context.Context, n *core.IpfsNode, root *dag.Node, cmd string, args []string, create bool) (*Object, error) {
+	e := dagutils.NewDagEditor(n.DAG, root)
+
+	var p string
+	var err error
+	switch cmd {
+	case "add-link":
+		if len(args) != 2 {
+			return nil, ErrPatchArgs
+		}
+
+		p = args[0]
+		var child *dag.Node
+		child, err = core.ResolveObject(n, path.Path(args[1]))
+		if err != nil {
+			return nil, err
+		}
+
+		var mkdir func() *dag.Node
+		if create {
+			mkdir = func() *dag.Node {
+				return &dag.Node{Data: ft.FolderPBData()}
+			}
+		}
+		err = e.InsertNodeAtPath(ctx, p, child, mkdir)
+
+	case "rm-link":
+		if len(args) != 1 {
+			return nil, ErrPatchArgs
+		}
+		p = args[0]
+		err = e.RmLink(ctx, p)
+
+	case "set-data", "append-data":
+		switch len(args) {
+		case 1:
+		case 2:
+			p = args[0]
+		default:
+			return nil, ErrPatchArgs
+		}
+		data := []byte(args[len(args)-1])
+
+		if cmd == "set-data" {
+			err = e.SetData(ctx, p, data)
+		} else {
+			err = e.AppendData(ctx, p, data)
+		}
+
+	default:
+		return nil, ErrUnknownPatchCmd
+	}
+	if err == dag.ErrNotFound {
+		return nil, fmt.Errorf("no link found along path %s", p)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return getOutput(e.GetNode())
+}
+
+// ErrUnknownTemplate is returned for templates 'ipfs object new' does not know
+var ErrUnknownTemplate = errors.New("template not found")
+
+func nodeFromTemplate(template string, args ...string) (*dag.Node, error) {
+	switch template {
+	case "unixfs-dir":
+		return &dag.Node{Data: ft.FolderPBData()}, nil
+	case "symlink":
+		if len(args) != 1 || args[0] == "" {
+			return nil, errors.New("the symlink template needs a target")
+		}
+		data, err := ft.SymlinkData(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return &dag.Node{Data: data}, nil
+	default:
+		return nil, ErrUnknownTemplate
+	}
+}
+
 // objectData takes a key string and writes out the raw bytes of that node (if there is one)
 func objectData(n *core.IpfsNode, fpath path.Path) (io.Reader, error) {
-	dagnode, err := core.Resolve(n, fpath)
+	dagnode, err := core.ResolveObject(n, fpath)
 	if err != nil {
 		return nil, err
 	}
@@ -357,7 +877,7 @@ func objectData(n *core.IpfsNode, fpath path.Path) (io.Reader, error) {
 
 // objectLinks takes a key string and lists the links it points to
 func objectLinks(n *core.IpfsNode, fpath path.Path) (*Object, error) {
-	dagnode, err := core.Resolve(n, fpath)
+	dagnode, err := core.ResolveObject(n, fpath)
 	if err != nil {
 		return nil, err
 	}
@@ -369,7 +889,7 @@ func objectLinks(n *core.IpfsNode, fpath path.Path) (*Object, error) {
 
 // objectGet takes a key string from args and a format option and serializes the dagnode to that format
 func objectGet(n *core.IpfsNode, fpath path.Path) (*dag.Node, error) {
-	dagnode, err := core.Resolve(n, fpath)
+	dagnode, err := core.ResolveObject(n, fpath)
 	if err != nil {
 		return nil, err
 	}
@@ -383,7 +903,7 @@ func objectGet(n *core.IpfsNode, fpath path.Path) (*dag.Node, error) {
 var ErrEmptyNode = errors.New("no data or links in this node")
 
 // objectPut takes a format option, serializes bytes from stdin and updates the dag with that data
-func objectPut(n *core.IpfsNode, input io.Reader, encoding string) (*Object, error) {
+func objectPut(n *core.IpfsNode, input io.Reader, encoding string, dataFieldEncoding string) (*Object, error) {
 
 	data, err := ioutil.ReadAll(io.LimitReader(input, inputLimit+10))
 	if err != nil {
@@ -409,7 +929,7 @@ func objectPut(n *core.IpfsNode, input io.Reader, encoding string) (*Object, err
 			return nil, ErrEmptyNode
 		}
 
-		dagnode, err = deserializeNode(node)
+		dagnode, err = deserializeNode(node, dataFieldEncoding)
 		if err != nil {
 			return nil, err
 		}
@@ -417,6 +937,9 @@ func objectPut(n *core.IpfsNode, input io.Reader, encoding string) (*Object, err
 	case objectEncodingProtobuf:
 		dagnode, err = dag.Decoded(data)
 
+	case objectEncodingCBOR:
+		dagnode, err = dag.DecodedCBOR(data)
+
 	default:
 		return nil, ErrUnknownObjectEnc
 	}
@@ -441,8 +964,70 @@ type objectEncoding string
 const (
 	objectEncodingJSON     objectEncoding = "json"
 	objectEncodingProtobuf                = "protobuf"
+	objectEncodingCBOR                    = "cbor"
 )
 
+// ErrUnknownDataFieldEnc is returned if an invalid data field encoding is supplied
+var ErrUnknownDataFieldEnc = errors.New("unknown data field encoding, must be either text or base64")
+
+const (
+	dataFieldEncodingText   = "text"
+	dataFieldEncodingBase64 = "base64"
+)
+
+func getDataFieldEnc(req cmds.Request) (string, error) {
+	enc, found, err := req.Option("datafieldenc").String()
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return dataFieldEncodingText, nil
+	}
+
+	switch enc {
+	case dataFieldEncodingText, dataFieldEncodingBase64:
+		return enc, nil
+	default:
+		return "", ErrUnknownDataFieldEnc
+	}
+}
+
+func encodeDataField(data []byte, enc string) (string, error) {
+	switch enc {
+	case dataFieldEncodingText:
+		return string(data), nil
+	case dataFieldEncodingBase64:
+		return base64.StdEncoding.EncodeToString(data), nil
+	default:
+		return "", ErrUnknownDataFieldEnc
+	}
+}
+
+func decodeDataField(data string, enc string) ([]byte, error) {
+	switch enc {
+	case dataFieldEncodingText:
+		return []byte(data), nil
+	case dataFieldEncodingBase64:
+		return base64.StdEncoding.DecodeString(data)
+	default:
+		return nil, ErrUnknownDataFieldEnc
+	}
+}
+
+// objectFromResponse rebuilds the dag.Node output by 'ipfs object get'
+func objectFromResponse(res cmds.Response) (*dag.Node, error) {
+	node, ok := res.Output().(*Node)
+	if !ok {
+		return nil, u.ErrCast()
+	}
+
+	dataenc, err := getDataFieldEnc(res.Request())
+	if err != nil {
+		return nil, err
+	}
+	return deserializeNode(node, dataenc)
+}
+
 func getObjectEnc(o interface{}) objectEncoding {
 	v, ok := o.(string)
 	if !ok {
@@ -477,9 +1062,14 @@ func getOutput(dagnode *dag.Node) (*Object, error) {
 }
 
 // converts the Node object into a real dag.Node
-func deserializeNode(node *Node) (*dag.Node, error) {
+func deserializeNode(node *Node, dataFieldEncoding string) (*dag.Node, error) {
+	data, err := decodeDataField(node.Data, dataFieldEncoding)
+	if err != nil {
+		return nil, err
+	}
+
 	dagnode := new(dag.Node)
-	dagnode.Data = []byte(node.Data)
+	dagnode.Data = data
 	dagnode.Links = make([]*dag.Link, len(node.Links))
 	for i, link := range node.Links {
 		hash, err := mh.FromB58String(link.Hash)
@@ -5,12 +5,11 @@ import (
 	"fmt"
 	"io"
 	"strings"
-
-	b58 "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-base58"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
 	core "github.com/ipfs/go-ipfs/core"
-	nsys "github.com/ipfs/go-ipfs/namesys"
+	namesys "github.com/ipfs/go-ipfs/namesys"
 	crypto "github.com/ipfs/go-ipfs/p2p/crypto"
 	path "github.com/ipfs/go-ipfs/path"
 	u "github.com/ipfs/go-ipfs/util"
@@ -38,6 +37,21 @@ Publish an <ipfs-path> to your identity name:
   > ipfs name publish /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
   published name QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n to QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
+Publish another name, to be followed when your name is resolved:
+
+  > ipfs name publish /ipns/QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
+  published name QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy to /ipns/QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
+
+Publish an <ipfs-path> with a record valid for a week, that resolvers may
+cache for ten minutes:
+
+  > ipfs name publish --lifetime=168h --ttl=10m /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+  published name QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n to QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
+The lifetime defaults to Ipns.RecordLifetime in the config. While the daemon
+runs, it publishes your name again every Ipns.RepublishPeriod, so that it
+does not expire.
+
 Publish an <ipfs-path> to another public key (not implemented):
 
   > ipfs name publish QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
@@ -49,6 +63,10 @@ Publish an <ipfs-path> to another public key (not implemented):
 		cmds.StringArg("name", false, false, "The IPNS name to publish to. Defaults to your node's peerID"),
 		cmds.StringArg("ipfs-path", true, false, "IPFS path of the obejct to be published at <name>").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		cmds.StringOption("lifetime", "How long the record stays valid, such as \"24h\""),
+		cmds.StringOption("ttl", "How long resolvers may cache the record, such as \"1m\""),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 		log.Debug("Begin Publish")
 		n, err := req.Context().GetNode()
@@ -65,6 +83,21 @@ Publish an <ipfs-path> to another public key (not implemented):
 			}
 		}
 
+		lifetime, err := recordLifetime(req, n)
+		if err != nil {
+			res.SetError(err, cmds.ErrClient)
+			return
+		}
+
+		ttl := namesys.DefaultRecordTTL
+		if s, found, _ := req.Option("ttl").String(); found {
+			ttl, err = time.ParseDuration(s)
+			if err != nil {
+				res.SetError(fmt.Errorf("invalid ttl: %s", err), cmds.ErrClient)
+				return
+			}
+		}
+
 		args := req.Arguments()
 
 		if n.Identity == "" {
@@ -84,20 +117,30 @@ Publish an <ipfs-path> to another public key (not implemented):
 			pstr = args[0]
 		}
 
-		node, err := n.Resolver.ResolvePath(path.FromString(pstr))
-		if err != nil {
-			res.SetError(fmt.Errorf("failed to resolve path: %v", err), cmds.ErrNormal)
-			return
-		}
+		var val u.Key
+		var ref string
+		if strings.HasPrefix(pstr, "/ipns/") {
+			// names are published as they are, to be followed when resolved
+			ref = path.FromSegments(append([]string{""}, path.Path(pstr).Segments()...)...).String()
+			val = u.Key(ref)
+		} else {
+			node, err := n.Resolver.ResolvePath(path.FromString(pstr))
+			if err != nil {
+				res.SetError(fmt.Errorf("failed to resolve path: %v", err), cmds.ErrNormal)
+				return
+			}
 
-		key, err := node.Key()
-		if err != nil {
-			res.SetError(err, cmds.ErrNormal)
-			return
+			key, err := node.Key()
+			if err != nil {
+				res.SetError(err, cmds.ErrNormal)
+				return
+			}
+			ref, val = key.Pretty(), key
 		}
 
 		// TODO n.Keychain.Get(name).PrivKey
-		output, err := publish(n, n.PrivateKey, key.Pretty())
+		eol := time.Now().Add(lifetime)
+		output, err := publish(n, n.PrivateKey, val, ref, eol, ttl)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -114,10 +157,30 @@ Publish an <ipfs-path> to another public key (not implemented):
 	Type: IpnsEntry{},
 }
 
-func publish(n *core.IpfsNode, k crypto.PrivKey, ref string) (*IpnsEntry, error) {
-	pub := nsys.NewRoutingPublisher(n.Routing)
-	val := b58.Decode(ref)
-	err := pub.Publish(n.Context(), k, u.Key(val))
+// recordLifetime returns the lifetime given in the options of req, or else
+// the one set in the config of n.
+func recordLifetime(req cmds.Request, n *core.IpfsNode) (time.Duration, error) {
+	if s, found, _ := req.Option("lifetime").String(); found {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid lifetime: %s", err)
+		}
+		return d, nil
+	}
+
+	cfg := n.Repo.Config()
+	if cfg.Ipns.RecordLifetime == "" {
+		return namesys.DefaultRecordLifetime, nil
+	}
+	d, err := time.ParseDuration(cfg.Ipns.RecordLifetime)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Ipns.RecordLifetime in config: %s", err)
+	}
+	return d, nil
+}
+
+func publish(n *core.IpfsNode, k crypto.PrivKey, val u.Key, ref string, eol time.Time, ttl time.Duration) (*IpnsEntry, error) {
+	err := n.Namesys.PublishWithEOL(n.Context(), k, val, eol, ttl)
 	if err != nil {
 		return nil, err
 	}
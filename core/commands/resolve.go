@@ -6,11 +6,14 @@ import (
 	"strings"
 
 	cmds "github.com/ipfs/go-ipfs/commands"
+	nsys "github.com/ipfs/go-ipfs/namesys"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
 type ResolvedKey struct {
-	Key u.Key
+	Key   u.Key
+	Path  string
+	Steps []string `json:",omitempty"`
 }
 
 var resolveCmd = &cmds.Command{
@@ -39,12 +42,24 @@ Resolve te value of another name:
   > ipfs name resolve QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
   QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
 
+Names may point at other names, through DNS TXT records or IPNS entries,
+and are followed until an /ipfs/ path is found, at most --depth times. Use
+-r to see every step:
+
+  > ipfs name resolve -r ipfs.io
+  /ipns/QmbCMUZw6JFeZ7Wp9jkzbye3Fzp2GGcPgC3nmeUjfVF87n
+  /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+
 `,
 	},
 
 	Arguments: []cmds.Argument{
 		cmds.StringArg("name", false, false, "The IPNS name to resolve. Defaults to your node's peerID.").EnableStdin(),
 	},
+	Options: []cmds.Option{
+		cmds.BoolOption("recursive", "r", "Print every step of the resolution"),
+		cmds.IntOption("depth", "The number of names to follow at most (default: 32)"),
+	},
 	Run: func(req cmds.Request, res cmds.Response) {
 
 		n, err := req.Context().GetNode()
@@ -74,7 +89,20 @@ Resolve te value of another name:
 			name = req.Arguments()[0]
 		}
 
-		output, err := n.Namesys.Resolve(n.Context(), name)
+		depth, found, err := req.Option("depth").Int()
+		if err != nil {
+			res.SetError(err, cmds.ErrNormal)
+			return
+		}
+		if !found {
+			depth = nsys.DefaultDepthLimit
+		}
+		if depth < 1 {
+			res.SetError(errors.New("depth must be at least 1"), cmds.ErrClient)
+			return
+		}
+
+		steps, err := n.Namesys.ResolveN(req.Context().Context, name, depth)
 		if err != nil {
 			res.SetError(err, cmds.ErrNormal)
 			return
@@ -82,7 +110,16 @@ Resolve te value of another name:
 
 		// TODO: better errors (in the case of not finding the name, we get "failed to find any peer in table")
 
-		res.SetOutput(&ResolvedKey{output})
+		output := &ResolvedKey{Path: steps[len(steps)-1].String()}
+		if seg := steps[len(steps)-1].Segments(); len(seg) == 2 {
+			output.Key = u.B58KeyDecode(seg[1])
+		}
+		if recursive, _, _ := req.Option("recursive").Bool(); recursive {
+			for _, s := range steps {
+				output.Steps = append(output.Steps, s.String())
+			}
+		}
+		res.SetOutput(output)
 	},
 	Marshalers: cmds.MarshalerMap{
 		cmds.Text: func(res cmds.Response) (io.Reader, error) {
@@ -90,6 +127,13 @@ Resolve te value of another name:
 			if !ok {
 				return nil, u.ErrCast()
 			}
+
+			if len(output.Steps) > 0 {
+				return strings.NewReader(strings.Join(output.Steps, "\n") + "\n"), nil
+			}
+			if output.Key == "" {
+				return strings.NewReader(output.Path), nil
+			}
 			return strings.NewReader(output.Key.B58String()), nil
 		},
 	},
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
 	"strings"
 
@@ -35,6 +37,8 @@ DATA STRUCTURE COMMANDS
 
     block         Interact with raw blocks in the datastore
     object        Interact with raw dag nodes
+    file          Interact with unixfs files
+    files         Manipulate the mutable filesystem of this node
 
 ADVANCED COMMANDS
 
@@ -84,6 +88,8 @@ var rootSubcommands = map[string]*cmds.Command{
 	"config":    ConfigCmd,
 	"dht":       DhtCmd,
 	"diag":      DiagCmd,
+	"file":      FileCmd,
+	"files":     FilesCmd,
 	"get":       GetCmd,
 	"id":        IDCmd,
 	"log":       LogCmd,
@@ -113,3 +119,34 @@ type MessageOutput struct {
 func MessageTextMarshaler(res cmds.Response) (io.Reader, error) {
 	return strings.NewReader(res.Output().(*MessageOutput).Message), nil
 }
+
+// streamJSONMarshaler marshals the output as the default JSON marshaler
+// does, but fails on errors sent in place of values by commands whose output
+// is a channel, so that they can report errors after they started streaming.
+func streamJSONMarshaler(res cmds.Response) (io.Reader, error) {
+	if outChan, ok := res.Output().(<-chan interface{}); ok {
+		return &cmds.ChannelMarshaler{
+			Channel: outChan,
+			Marshaler: func(v interface{}) (io.Reader, error) {
+				if err, ok := v.(error); ok {
+					return nil, err
+				}
+				return marshalJSON(v)
+			},
+		}, nil
+	}
+
+	var value interface{} = res.Output()
+	if res.Error() != nil {
+		value = res.Error()
+	}
+	return marshalJSON(value)
+}
+
+func marshalJSON(v interface{}) (io.Reader, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
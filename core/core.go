@@ -22,11 +22,13 @@ import (
 	p2phost "github.com/ipfs/go-ipfs/p2p/host"
 	p2pbhost "github.com/ipfs/go-ipfs/p2p/host/basic"
 	rhost "github.com/ipfs/go-ipfs/p2p/host/routed"
+	pnet "github.com/ipfs/go-ipfs/p2p/net/pnet"
 	swarm "github.com/ipfs/go-ipfs/p2p/net/swarm"
 	addrutil "github.com/ipfs/go-ipfs/p2p/net/swarm/addr"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 
 	routing "github.com/ipfs/go-ipfs/routing"
+	compose "github.com/ipfs/go-ipfs/routing/compose"
 	dht "github.com/ipfs/go-ipfs/routing/dht"
 	kb "github.com/ipfs/go-ipfs/routing/kbucket"
 	offroute "github.com/ipfs/go-ipfs/routing/offline"
@@ -47,6 +49,7 @@ import (
 	pin "github.com/ipfs/go-ipfs/pin"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
+	u "github.com/ipfs/go-ipfs/util"
 )
 
 const IpnsValidatorTag = "ipns"
@@ -87,16 +90,19 @@ type IpfsNode struct {
 	Discovery  discovery.Service
 
 	// Online
-	PeerHost     p2phost.Host        // the network host (server+client)
-	Bootstrapper io.Closer           // the periodic bootstrapper
-	Routing      routing.IpfsRouting // the routing system. recommend ipfs-dht
-	Exchange     exchange.Interface  // the block exchange + strategy (bitswap)
-	Namesys      namesys.NameSystem  // the name system, resolves paths to hashes
-	Diagnostics  *diag.Diagnostics   // the diagnostics service
-	Reprovider   *rp.Reprovider      // the value reprovider system
+	PeerHost     p2phost.Host         // the network host (server+client)
+	Bootstrapper io.Closer            // the periodic bootstrapper
+	Routing      routing.IpfsRouting  // the routing system. recommend ipfs-dht
+	Exchange     exchange.Interface   // the block exchange + strategy (bitswap)
+	Namesys      namesys.NameSystem   // the name system, resolves paths to hashes
+	Diagnostics  *diag.Diagnostics    // the diagnostics service
+	Reprovider   *rp.Reprovider       // the value reprovider system
+	Republisher  *namesys.Republisher // republishes the names of this node
 
 	IpnsFs *ipnsfs.Filesystem
 
+	savedPeers []peer.ID // good peers saved by the last run
+
 	ctxgroup.ContextGroup
 
 	mode mode
@@ -220,8 +226,12 @@ func standardWithRouting(r repo.Repo, online bool, routingOption RoutingOption,
 		}
 
 		if online {
-			do := setupDiscoveryOption(n.Repo.Config().Discovery)
-			if err := n.startOnlineServices(ctx, routingOption, hostOption, do); err != nil {
+			protec, err := privateNetwork(n.Repo.Config().Swarm)
+			if err != nil {
+				return nil, err
+			}
+			do := setupDiscoveryOption(n.Repo.Config().Discovery, protec)
+			if err := n.startOnlineServices(ctx, routingOption, hostOption, do, protec); err != nil {
 				return nil, err
 			}
 		} else {
@@ -233,7 +243,7 @@ func standardWithRouting(r repo.Repo, online bool, routingOption RoutingOption,
 	}
 }
 
-func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, do DiscoveryOption) error {
+func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption RoutingOption, hostOption HostOption, do DiscoveryOption, protec *pnet.Protector) error {
 
 	if n.PeerHost != nil { // already online.
 		return errors.New("node already online")
@@ -247,7 +257,7 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	// Set reporter
 	n.Reporter = metrics.NewBandwidthCounter()
 
-	peerhost, err := hostOption(ctx, n.Identity, n.Peerstore, n.Reporter)
+	peerhost, err := hostOption(ctx, n.Identity, n.Peerstore, n.Reporter, protec)
 	if err != nil {
 		return err
 	}
@@ -261,8 +271,16 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 		return err
 	}
 
-	n.Reprovider = rp.NewReprovider(n.Routing, n.Blockstore)
-	go n.Reprovider.ProvideEvery(ctx, kReprovideFrequency)
+	n.loadSavedPeers(ctx)
+	go n.savePeersEvery(ctx, kSavePeersPeriod)
+
+	if err := n.startReprovider(ctx, n.Repo.Config().Reprovider); err != nil {
+		return err
+	}
+
+	if err := n.startRepublisher(ctx, n.Repo.Config().Ipns); err != nil {
+		return err
+	}
 
 	// setup local discovery
 	if do != nil {
@@ -278,18 +296,110 @@ func (n *IpfsNode) startOnlineServices(ctx context.Context, routingOption Routin
 	return n.Bootstrap(DefaultBootstrapConfig)
 }
 
-func setupDiscoveryOption(d config.Discovery) DiscoveryOption {
+// startRepublisher keeps the name of this node from expiring, as
+// configured in cfg.
+func (n *IpfsNode) startRepublisher(ctx context.Context, cfg config.Ipns) error {
+	n.Republisher = namesys.NewRepublisher(n.Repo.Datastore(), n.Namesys, n.PrivateKey)
+
+	if cfg.RepublishPeriod != "" {
+		d, err := time.ParseDuration(cfg.RepublishPeriod)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Ipns.RepublishPeriod: %s", err)
+		}
+		if d == 0 {
+			return nil // republishing is off
+		}
+		n.Republisher.Interval = d
+	}
+
+	if cfg.RecordLifetime != "" {
+		d, err := time.ParseDuration(cfg.RecordLifetime)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Ipns.RecordLifetime: %s", err)
+		}
+		n.Republisher.RecordLifetime = d
+	}
+
+	go n.Republisher.Run(ctx)
+	return nil
+}
+
+func (n *IpfsNode) startReprovider(ctx context.Context, cfg config.Reprovider) error {
+	var keyProvider rp.KeyChanFunc
+	switch cfg.Strategy {
+	case "", "all":
+		keyProvider = rp.NewBlockstoreProvider(n.Blockstore)
+	case "pinned":
+		keyProvider = rp.NewPinnedProvider(n.Pinning)
+	case "roots":
+		keyProvider = rp.NewRootsProvider(n.Pinning)
+	case "list":
+		var keys []u.Key
+		for _, s := range cfg.Keys {
+			k := u.B58KeyDecode(s)
+			if k == "" {
+				return fmt.Errorf("invalid key in config setting Reprovider.Keys: %q", s)
+			}
+			keys = append(keys, k)
+		}
+		keyProvider = rp.NewListProvider(keys)
+	default:
+		return fmt.Errorf("unknown reprovider strategy %q in config setting Reprovider.Strategy", cfg.Strategy)
+	}
+
+	n.Reprovider = rp.NewReprovider(n.Routing, keyProvider)
+	if cfg.BatchSize > 0 {
+		n.Reprovider.BatchSize = cfg.BatchSize
+	}
+	n.Reprovider.RateLimit = cfg.RateLimit
+
+	interval := kReprovideFrequency
+	if cfg.Interval != "" {
+		d, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return fmt.Errorf("failure to parse config setting Reprovider.Interval: %s", err)
+		}
+		if d == 0 {
+			return nil // only reprovide when asked to
+		}
+		interval = d
+	}
+
+	go n.Reprovider.ProvideEvery(ctx, interval)
+	return nil
+}
+
+func setupDiscoveryOption(d config.Discovery, protec *pnet.Protector) DiscoveryOption {
 	if d.MDNS.Enabled {
 		return func(h p2phost.Host) (discovery.Service, error) {
 			if d.MDNS.Interval == 0 {
 				d.MDNS.Interval = 5
 			}
-			return discovery.NewMdnsService(h, time.Duration(d.MDNS.Interval)*time.Second)
+			interval := time.Duration(d.MDNS.Interval) * time.Second
+			if protec != nil {
+				// only announce to, and look for, peers of the same network
+				tag := "pnet-" + protec.Fingerprint() + "." + discovery.ServiceTag
+				return discovery.NewMdnsServiceWithTag(h, interval, tag)
+			}
+			return discovery.NewMdnsService(h, interval)
 		}
 	}
 	return nil
 }
 
+// privateNetwork returns the protector of the private network set in cfg,
+// or nil to use the public network.
+func privateNetwork(cfg config.Swarm) (*pnet.Protector, error) {
+	if cfg.PrivateNetworkKey == "" {
+		return nil, nil
+	}
+	key, err := pnet.DecodeKey(cfg.PrivateNetworkKey)
+	if err != nil {
+		return nil, fmt.Errorf("bad config setting Swarm.PrivateNetworkKey: %s", err)
+	}
+	return pnet.NewProtector(key)
+}
+
 func (n *IpfsNode) HandlePeerFound(p peer.PeerInfo) {
 	log.Warning("trying peer info: ", p)
 	ctx, _ := context.WithTimeout(n.Context(), time.Second*10)
@@ -321,7 +431,7 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 	n.Exchange = bitswap.New(ctx, n.Identity, bitswapNetwork, n.Blockstore, alwaysSendToPeer)
 
 	// setup name system
-	n.Namesys = namesys.NewNameSystem(n.Routing)
+	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore())
 
 	return nil
 }
@@ -330,6 +440,12 @@ func (n *IpfsNode) startOnlineServicesWithHost(ctx context.Context, host p2phost
 // the first error.
 func (n *IpfsNode) teardown() error {
 	log.Debug("core is shutting down...")
+	if n.PeerHost != nil {
+		if err := n.savePeers(); err != nil {
+			log.Warningf("failed to save peers: %s", err)
+		}
+	}
+
 	// owned objects are closed in this teardown to ensure that they're closed
 	// regardless of which constructor was used to add them to the node.
 	closers := []io.Closer{
@@ -351,7 +467,7 @@ func (n *IpfsNode) teardown() error {
 		closers = append(closers, n.Bootstrapper)
 	}
 
-	if dht, ok := n.Routing.(*dht.IpfsDHT); ok {
+	if dht, ok := FindDHT(n.Routing); ok {
 		closers = append(closers, dht)
 	}
 
@@ -398,9 +514,9 @@ func (n *IpfsNode) Bootstrap(cfg BootstrapConfig) error {
 			ps, err := n.loadBootstrapPeers()
 			if err != nil {
 				log.Warningf("failed to parse bootstrap peers from config: %s", n.Repo.Config().Bootstrap)
-				return nil
 			}
-			return ps
+			// in case none of those can be reached
+			return append(ps, peer.PeerInfos(n.Peerstore, n.savedPeers)...)
 		}
 	}
 
@@ -465,7 +581,7 @@ func (n *IpfsNode) SetupOfflineRouting() error {
 
 	n.Routing = offroute.NewOfflineRouter(n.Repo.Datastore(), n.PrivateKey)
 
-	n.Namesys = namesys.NewNameSystem(n.Routing)
+	n.Namesys = namesys.NewNameSystem(n.Routing, n.Repo.Datastore())
 
 	return nil
 }
@@ -501,15 +617,17 @@ func listenAddresses(cfg *config.Config) ([]ma.Multiaddr, error) {
 	return listen, nil
 }
 
-type HostOption func(ctx context.Context, id peer.ID, ps peer.Peerstore, bwr metrics.Reporter) (p2phost.Host, error)
+// HostOption constructs the host of a node. A non-nil protec limits it to
+// the peers of that private network.
+type HostOption func(ctx context.Context, id peer.ID, ps peer.Peerstore, bwr metrics.Reporter, protec *pnet.Protector) (p2phost.Host, error)
 
 var DefaultHostOption HostOption = constructPeerHost
 
 // isolates the complex initialization steps
-func constructPeerHost(ctx context.Context, id peer.ID, ps peer.Peerstore, bwr metrics.Reporter) (p2phost.Host, error) {
+func constructPeerHost(ctx context.Context, id peer.ID, ps peer.Peerstore, bwr metrics.Reporter, protec *pnet.Protector) (p2phost.Host, error) {
 
 	// no addresses to begin with. we'll start later.
-	network, err := swarm.NewNetwork(ctx, nil, id, ps, bwr)
+	network, err := swarm.NewPrivateNetwork(ctx, nil, id, ps, bwr, protec)
 	if err != nil {
 		return nil, err
 	}
@@ -550,12 +668,30 @@ func startListening(ctx context.Context, host p2phost.Host, cfg *config.Config)
 
 func constructDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.ThreadSafeDatastore) (routing.IpfsRouting, error) {
 	dhtRouting := dht.NewDHT(ctx, host, dstore)
-	dhtRouting.Validator[IpnsValidatorTag] = namesys.IpnsRecordValidator
+	AddRecordTypes(dhtRouting.Validator)
 	return dhtRouting, nil
 }
 
+func constructClientDHTRouting(ctx context.Context, host p2phost.Host, dstore ds.ThreadSafeDatastore) (routing.IpfsRouting, error) {
+	dhtRouting := dht.NewDHTClient(ctx, host, dstore)
+	AddRecordTypes(dhtRouting.Validator)
+	return dhtRouting, nil
+}
+
+// FindDHT returns the DHT of the routing system r, which may be one of
+// the routers r is made of.
+func FindDHT(r routing.IpfsRouting) (*dht.IpfsDHT, bool) {
+	for _, r := range compose.Routers(r) {
+		if d, ok := r.(*dht.IpfsDHT); ok {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
 type RoutingOption func(context.Context, p2phost.Host, ds.ThreadSafeDatastore) (routing.IpfsRouting, error)
 
 type DiscoveryOption func(p2phost.Host) (discovery.Service, error)
 
 var DHTOption RoutingOption = constructDHTRouting
+var DHTClientOption RoutingOption = constructClientDHTRouting
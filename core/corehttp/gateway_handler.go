@@ -1,11 +1,14 @@
 package corehttp
 
 import (
+	"compress/gzip"
 	"fmt"
 	"html/template"
 	"io"
+	"mime"
 	"net/http"
 	gopath "path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +16,7 @@ import (
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	core "github.com/ipfs/go-ipfs/core"
+	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	"github.com/ipfs/go-ipfs/importer"
 	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	dag "github.com/ipfs/go-ipfs/merkledag"
@@ -20,6 +24,7 @@ import (
 	"github.com/ipfs/go-ipfs/routing"
 	ufs "github.com/ipfs/go-ipfs/unixfs"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	utar "github.com/ipfs/go-ipfs/unixfs/tar"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -76,36 +81,19 @@ func (i *gatewayHandler) loadTemplate() error {
 }
 
 func (i *gatewayHandler) resolveNamePath(ctx context.Context, p string) (string, error) {
-	p = gopath.Clean(p)
-
-	if strings.HasPrefix(p, IpnsPathPrefix) {
-		elements := strings.Split(p[len(IpnsPathPrefix):], "/")
-		hash := elements[0]
-		k, err := i.node.Namesys.Resolve(ctx, hash)
-		if err != nil {
-			return "", err
-		}
-
-		elements[0] = k.Pretty()
-		p = gopath.Join(elements...)
-	}
-	if !strings.HasPrefix(p, IpfsPathPrefix) {
-		p = gopath.Join(IpfsPathPrefix, p)
+	ipath, err := core.ResolveName(ctx, i.node, path.Path(p))
+	if err != nil {
+		return "", err
 	}
-	return p, nil
+	return ipath.String(), nil
 }
 
 func (i *gatewayHandler) ResolvePath(ctx context.Context, p string) (*dag.Node, string, error) {
-	p, err := i.resolveNamePath(ctx, p)
+	node, ipath, err := core.ResolvePath(ctx, i.node, path.Path(p), core.DefaultDepthLimit)
 	if err != nil {
 		return nil, "", err
 	}
-
-	node, err := i.node.Resolver.ResolvePath(path.Path(p))
-	if err != nil {
-		return nil, "", err
-	}
-	return node, p, err
+	return node, ipath.String(), nil
 }
 
 func (i *gatewayHandler) NewDagFromReader(r io.Reader) (*dag.Node, error) {
@@ -197,6 +185,11 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 	pathRoot := strings.SplitN(urlPath, "/", 4)[2]
 	w.Header().Set("Suborigin", pathRoot)
 
+	if format := r.URL.Query().Get("download"); format != "" {
+		i.serveArchive(w, r, nd, urlPath, format)
+		return
+	}
+
 	dr, err := i.NewDagReader(nd)
 	if err != nil && err != uio.ErrIsDir {
 		// not a directory and still an error
@@ -224,11 +217,23 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	dir, err := uio.NewDirectoryFromNode(i.node.DAG, nd)
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+
+	links, err := dir.Links(ctx)
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+
 	// storage for directory listing
 	var dirListing []directoryItem
 	// loop through files
 	foundIndex := false
-	for _, link := range nd.Links {
+	for _, link := range links {
 		if link.Name == "index.html" {
 			if urlPath[len(urlPath)-1] != '/' {
 				http.Redirect(w, r, urlPath+"/", 302)
@@ -277,6 +282,64 @@ func (i *gatewayHandler) getOrHeadHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// serveArchive sends the object as a tar or zip archive to be saved by the
+// client, rather than displayed
+func (i *gatewayHandler) serveArchive(w http.ResponseWriter, r *http.Request, nd *dag.Node, urlPath, format string) {
+	var opts utar.Options
+	var ctype, ext string
+	switch format {
+	case "tar":
+		opts.Format, ctype, ext = utar.Tar, "application/x-tar", ".tar"
+	case "zip":
+		opts.Format, ctype, ext = utar.Zip, "application/zip", ".zip"
+	default:
+		webError(w, "Unknown download format", fmt.Errorf("%q is neither tar nor zip", format), http.StatusBadRequest)
+		return
+	}
+
+	name := gopath.Base(urlPath)
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": name + ext,
+	}))
+
+	if r.Method == "HEAD" {
+		// the reader starts writing the archive right away, don't create
+		// one that would never be read
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	reader, err := utar.NewReaderWithOptions(path.Path(name), i.node.DAG, nd, gzip.NoCompression, opts)
+	if err != nil {
+		internalWebError(w, err)
+		return
+	}
+	defer reader.Close()
+
+	// stop building the archive as soon as the client goes away
+	if cn, ok := w.(http.CloseNotifier); ok {
+		clientGone := cn.CloseNotify()
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-clientGone:
+				reader.Close()
+			case <-done:
+			}
+		}()
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, reader)
+	if err != nil {
+		// too late to report it, the status has been sent already
+		log.Debugf("error sending archive of %s: %s", urlPath, err)
+	}
+}
+
 func (i *gatewayHandler) postHandler(w http.ResponseWriter, r *http.Request) {
 	nd, err := i.NewDagFromReader(r.Body)
 	if err != nil {
@@ -309,6 +372,11 @@ func (i *gatewayHandler) putEmptyDirHandler(w http.ResponseWriter, r *http.Reque
 }
 
 func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-Range") != "" {
+		i.putRangeHandler(w, r)
+		return
+	}
+
 	urlPath := r.URL.Path
 	pathext := urlPath[5:]
 	var err error
@@ -398,6 +466,109 @@ func (i *gatewayHandler) putHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, IpfsPathPrefix+key.String()+"/"+strings.Join(components, "/"), http.StatusCreated)
 }
 
+// putRangeHandler writes the request body into an existing file at the
+// offset given by the Content-Range header, "bytes <first>-<last>/<size>".
+// If <size> is given rather than "*", the file is made exactly that long.
+func (i *gatewayHandler) putRangeHandler(w http.ResponseWriter, r *http.Request) {
+	first, last, size, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		webErrorWithCode(w, "Invalid Content-Range", err, http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(i.node.Context())
+	defer cancel()
+
+	ipfspath, err := i.resolveNamePath(ctx, r.URL.Path)
+	if err != nil {
+		// FIXME HTTP error code
+		webError(w, "Could not resolve name", err, http.StatusInternalServerError)
+		return
+	}
+
+	// exactly the bytes in the range must be sent
+	body := &countingReader{r: io.LimitReader(r.Body, last-first+1)}
+	root, err := coreunix.WriteAt(ctx, i.node, path.Path(ipfspath), body, first, false)
+	if err == coreunix.ErrNotAFile {
+		webErrorWithCode(w, "Could not write to object", err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		webError(w, "Could not write to file", err, http.StatusInternalServerError)
+		return
+	}
+	if body.n != last-first+1 {
+		err = fmt.Errorf("expected %d bytes, got %d", last-first+1, body.n)
+		webErrorWithCode(w, "Request body does not match Content-Range", err, http.StatusBadRequest)
+		return
+	}
+
+	_, components, err := path.SplitAbsPath(path.Path(ipfspath))
+	if err != nil {
+		webError(w, "Could not split path", err, http.StatusInternalServerError)
+		return
+	}
+
+	key, err := root.Key()
+	if err != nil {
+		webError(w, "Could not get key of new node", err, http.StatusInternalServerError)
+		return
+	}
+
+	if size >= 0 {
+		npath := path.FromSegments(append([]string{key.String()}, components...)...)
+		root, err = coreunix.WriteAt(ctx, i.node, npath, strings.NewReader(""), size, true)
+		if err != nil {
+			webError(w, "Could not resize file", err, http.StatusInternalServerError)
+			return
+		}
+
+		key, err = root.Key()
+		if err != nil {
+			webError(w, "Could not get key of new node", err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("IPFS-Hash", key.String())
+	http.Redirect(w, r, IpfsPathPrefix+gopath.Join(append([]string{key.String()}, components...)...), http.StatusCreated)
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "bytes <first>-<last>/<size>". size is -1 if given as "*".
+func parseContentRange(s string) (first, last, size int64, err error) {
+	const prefix = "bytes "
+	if !strings.HasPrefix(s, prefix) {
+		return 0, 0, 0, fmt.Errorf("unsupported range unit in %q", s)
+	}
+
+	var total string
+	_, err = fmt.Sscanf(s[len(prefix):], "%d-%d/%s", &first, &last, &total)
+	if err != nil || first < 0 || last < first {
+		return 0, 0, 0, fmt.Errorf("malformed range %q", s)
+	}
+
+	if total == "*" {
+		return first, last, -1, nil
+	}
+	size, err = strconv.ParseInt(total, 10, 64)
+	if err != nil || size <= last {
+		return 0, 0, 0, fmt.Errorf("malformed range %q", s)
+	}
+	return first, last, size, nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
+}
+
 func (i *gatewayHandler) deleteHandler(w http.ResponseWriter, r *http.Request) {
 	urlPath := r.URL.Path
 	ctx, cancel := context.WithCancel(i.node.Context())
@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	b58 "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-base58"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
@@ -15,6 +16,7 @@ import (
 	coreunix "github.com/ipfs/go-ipfs/core/coreunix"
 	namesys "github.com/ipfs/go-ipfs/namesys"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	path "github.com/ipfs/go-ipfs/path"
 	repo "github.com/ipfs/go-ipfs/repo"
 	config "github.com/ipfs/go-ipfs/repo/config"
 	u "github.com/ipfs/go-ipfs/util"
@@ -35,6 +37,14 @@ func (m mockNamesys) Resolve(ctx context.Context, name string) (value u.Key, err
 	return u.Key(dec), nil
 }
 
+func (m mockNamesys) ResolveN(ctx context.Context, name string, depth int) ([]path.Path, error) {
+	k, err := m.Resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return []path.Path{path.FromSegments("", "ipfs", k.B58String())}, nil
+}
+
 func (m mockNamesys) CanResolve(name string) bool {
 	_, ok := m[name]
 	return ok
@@ -44,6 +54,10 @@ func (m mockNamesys) Publish(ctx context.Context, name ci.PrivKey, value u.Key)
 	return errors.New("not implemented for mockNamesys")
 }
 
+func (m mockNamesys) PublishWithEOL(ctx context.Context, name ci.PrivKey, value u.Key, eol time.Time, ttl time.Duration) error {
+	return errors.New("not implemented for mockNamesys")
+}
+
 func newNodeWithMockNamesys(t *testing.T, ns mockNamesys) *core.IpfsNode {
 	c := config.Config{
 		Identity: config.Identity{
@@ -6,6 +6,7 @@ import (
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	"github.com/ipfs/go-ipfs/core"
+	path "github.com/ipfs/go-ipfs/path"
 )
 
 // IPNSHostnameOption rewrites an incoming request if its Host: header contains
@@ -19,8 +20,8 @@ func IPNSHostnameOption() ServeOption {
 			defer cancel()
 
 			host := strings.SplitN(r.Host, ":", 2)[0]
-			if k, err := n.Namesys.Resolve(ctx, host); err == nil {
-				r.URL.Path = "/ipfs/" + k.Pretty() + r.URL.Path
+			if p, err := core.ResolveName(ctx, n, path.Path("/ipns/"+host)); err == nil {
+				r.URL.Path = p.String() + r.URL.Path
 			}
 			childMux.ServeHTTP(w, r)
 		})
@@ -0,0 +1,189 @@
+package corehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	core "github.com/ipfs/go-ipfs/core"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	delegated "github.com/ipfs/go-ipfs/routing/delegated"
+	pb "github.com/ipfs/go-ipfs/routing/dht/pb"
+	record "github.com/ipfs/go-ipfs/routing/record"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+const (
+	// delegatedTimeout bounds each query run for a delegated routing client.
+	delegatedTimeout = time.Minute
+
+	// defaultDelegatedProviders is how many providers are looked for when a
+	// client does not say.
+	defaultDelegatedProviders = 20
+)
+
+// DelegatedRoutingOption serves the routing queries of nodes using a
+// delegated.Client with this node's routing system.
+func DelegatedRoutingOption() ServeOption {
+	return func(n *core.IpfsNode, mux *http.ServeMux) (*http.ServeMux, error) {
+		if n.Routing == nil {
+			return nil, errors.New("delegated routing needs an online node")
+		}
+		// values from clients are checked as the DHT would check values
+		// from other peers, before this node stores and signs them
+		v := record.Validator{"pk": record.PublicKeyValidator}
+		core.AddRecordTypes(v)
+		mux.Handle(delegated.HTTPPath, &delegatedHandler{node: n, validator: v})
+		return mux, nil
+	}
+}
+
+type delegatedHandler struct {
+	node      *core.IpfsNode
+	validator record.Validator
+}
+
+func (h *delegatedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(h.node.Context(), delegatedTimeout)
+	defer cancel()
+	if cn, ok := w.(http.CloseNotifier); ok {
+		clientGone := cn.CloseNotify()
+		go func() {
+			select {
+			case <-clientGone:
+			case <-ctx.Done():
+			}
+			cancel()
+		}()
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, delegated.HTTPPath), "/")
+	if len(parts) != 2 || parts[1] == "" {
+		http.Error(w, "bad routing path: "+r.URL.Path, http.StatusBadRequest)
+		return
+	}
+	kind, arg := parts[0], parts[1]
+
+	switch {
+	case kind == "providers" && r.Method == "GET":
+		h.findProviders(ctx, w, r, arg)
+	case kind == "peers" && r.Method == "GET":
+		h.findPeer(ctx, w, arg)
+	case kind == "values" && r.Method == "GET":
+		h.getValue(ctx, w, arg)
+	case kind == "values" && r.Method == "PUT":
+		h.putValue(ctx, w, r, arg)
+	default:
+		http.Error(w, "method not allowed: "+r.Method+" "+kind, http.StatusMethodNotAllowed)
+	}
+}
+
+// decodeKey returns the key encoded in s, or answers the request with an
+// error.
+func decodeKey(w http.ResponseWriter, s string) (u.Key, bool) {
+	k := u.B58KeyDecode(s)
+	if k == "" {
+		http.Error(w, "invalid key: "+s, http.StatusBadRequest)
+		return "", false
+	}
+	return k, true
+}
+
+// routingError answers a request with err.
+func routingError(w http.ResponseWriter, err error) {
+	switch err {
+	case routing.ErrNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case context.DeadlineExceeded:
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *delegatedHandler) findProviders(ctx context.Context, w http.ResponseWriter, r *http.Request, arg string) {
+	k, ok := decodeKey(w, arg)
+	if !ok {
+		return
+	}
+	count := defaultDelegatedProviders
+	if s := r.URL.Query().Get("count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid count: "+s, http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for pi := range h.node.Routing.FindProvidersAsync(ctx, k, count) {
+		if err := enc.Encode(delegated.NewPeerInfo(pi)); err != nil {
+			log.Debugf("delegated routing: %s", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *delegatedHandler) findPeer(ctx context.Context, w http.ResponseWriter, arg string) {
+	id, err := peer.IDB58Decode(arg)
+	if err != nil {
+		http.Error(w, "invalid peer id: "+arg, http.StatusBadRequest)
+		return
+	}
+	pi, err := h.node.Routing.FindPeer(ctx, id)
+	if err != nil {
+		routingError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delegated.NewPeerInfo(pi))
+}
+
+func (h *delegatedHandler) getValue(ctx context.Context, w http.ResponseWriter, arg string) {
+	k, ok := decodeKey(w, arg)
+	if !ok {
+		return
+	}
+	val, err := h.node.Routing.GetValue(ctx, k)
+	if err != nil {
+		routingError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(val)
+}
+
+func (h *delegatedHandler) putValue(ctx context.Context, w http.ResponseWriter, r *http.Request, arg string) {
+	k, ok := decodeKey(w, arg)
+	if !ok {
+		return
+	}
+	val, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, delegated.MaxValueSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec := &pb.Record{Key: proto.String(string(k)), Value: val}
+	if err := h.validator.VerifyRecord(rec); err != nil {
+		http.Error(w, "invalid value: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.node.Routing.PutValue(ctx, k, val); err != nil {
+		routingError(w, err)
+		return
+	}
+}
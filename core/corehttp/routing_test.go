@@ -0,0 +1,148 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	delegated "github.com/ipfs/go-ipfs/routing/delegated"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	record "github.com/ipfs/go-ipfs/routing/record"
+	u "github.com/ipfs/go-ipfs/util"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
+)
+
+// knownPeers is a routing system that finds the peers it knows of.
+type knownPeers struct {
+	routing.IpfsRouting
+	peers map[peer.ID]peer.PeerInfo
+}
+
+func (r knownPeers) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	pi, ok := r.peers[id]
+	if !ok {
+		return peer.PeerInfo{}, routing.ErrNotFound
+	}
+	return pi, nil
+}
+
+func TestDelegatedRouting(t *testing.T) {
+	ctx := context.Background()
+	srv := mockrouting.NewServer()
+	known := testutil.RandIdentityOrFatal(t)
+	provs := []testutil.Identity{
+		testutil.RandIdentityOrFatal(t),
+		testutil.RandIdentityOrFatal(t),
+	}
+	k := u.Key("data")
+	for _, id := range provs {
+		if err := srv.Client(id).Provide(ctx, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := newNodeWithMockNamesys(t, nil)
+	n.Routing = knownPeers{
+		IpfsRouting: srv.Client(testutil.RandIdentityOrFatal(t)),
+		peers: map[peer.ID]peer.PeerInfo{
+			known.ID(): {ID: known.ID(), Addrs: []ma.Multiaddr{known.Address()}},
+		},
+	}
+	h, err := makeHandler(n, DelegatedRoutingOption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	c, err := delegated.NewClient(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.PutValue(ctx, "/v/hello", []byte("world")); err == nil {
+		t.Fatal("expected a value of an unknown type to be refused")
+	}
+	if err := n.Routing.PutValue(ctx, "/v/hello", []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetValue(ctx, "/v/hello"); err == nil {
+		t.Fatal("expected a value of an unknown type to be rejected")
+	}
+	c.Validator["v"] = &record.ValidChecker{
+		Func: func(u.Key, []byte) error { return nil },
+	}
+	val, err := c.GetValue(ctx, "/v/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "world" {
+		t.Fatalf("expected 'world' got '%s'", val)
+	}
+
+	pkb, err := known.PublicKey().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkkey := u.Key("/pk/" + string(u.Hash(pkb)))
+	if err := c.PutValue(ctx, pkkey, pkb); err != nil {
+		t.Fatal(err)
+	}
+	if val, err := c.GetValue(ctx, pkkey); err != nil || string(val) != string(pkb) {
+		t.Fatalf("public key not returned: %v", err)
+	}
+	badkey := u.Key("/pk/" + string(u.Hash([]byte("other"))))
+	if err := c.PutValue(ctx, badkey, pkb); err == nil {
+		t.Fatal("expected a public key not matching its key to be refused")
+	}
+	// as stored by a bad remote node
+	if err := n.Routing.PutValue(ctx, badkey, pkb); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetValue(ctx, badkey); err == nil {
+		t.Fatal("expected a public key not matching its key to be rejected")
+	}
+
+	found := make(map[peer.ID]bool)
+	for pi := range c.FindProvidersAsync(ctx, k, 10) {
+		found[pi.ID] = true
+	}
+	for _, id := range provs {
+		if !found[id.ID()] {
+			t.Fatalf("provider %s not found", id.ID())
+		}
+	}
+	count := 0
+	for _ = range c.FindProvidersAsync(ctx, k, 1) {
+		count++
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 provider, got %d", count)
+	}
+
+	pi, err := c.FindPeer(ctx, known.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pi.ID != known.ID() || len(pi.Addrs) != 1 || !pi.Addrs[0].Equal(known.Address()) {
+		t.Fatalf("wrong peer info: %v", pi)
+	}
+	if _, err := c.FindPeer(ctx, provs[0].ID()); err != routing.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	for _, path := range []string{"values/", "values/0OIl", "bogus/foo", "providers/foo?count=none"} {
+		res, err := http.Get(ts.URL + delegated.HTTPPath + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode < 400 || res.StatusCode >= 500 {
+			t.Fatalf("%s: expected a client error, got %s", path, res.Status)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package corerouting
+
+import (
+	"fmt"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	core "github.com/ipfs/go-ipfs/core"
+	"github.com/ipfs/go-ipfs/p2p/host"
+	"github.com/ipfs/go-ipfs/p2p/peer"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	routing "github.com/ipfs/go-ipfs/routing"
+	compose "github.com/ipfs/go-ipfs/routing/compose"
+	delegated "github.com/ipfs/go-ipfs/routing/delegated"
+	offroute "github.com/ipfs/go-ipfs/routing/offline"
+)
+
+// Composed returns a configuration for the routing system described by rc,
+// which may be made of several others. Supernode routers without servers
+// of their own use the ones in servers.
+func Composed(rc config.Router, servers []string) (core.RoutingOption, error) {
+	return composedOption(rc, servers, make(map[string]bool))
+}
+
+// composedOption builds the option for rc. used holds the kinds of
+// routers that can be set up only once on a host, and are already.
+func composedOption(rc config.Router, servers []string, used map[string]bool) (core.RoutingOption, error) {
+	once := func(kind string) error {
+		if used[kind] {
+			return fmt.Errorf("routing can use only one %s router", kind)
+		}
+		used[kind] = true
+		return nil
+	}
+
+	switch rc.Type {
+	case "dht":
+		if err := once("dht"); err != nil {
+			return nil, err
+		}
+		return core.DHTOption, nil
+
+	case "dhtclient":
+		if err := once("dht"); err != nil {
+			return nil, err
+		}
+		return core.DHTClientOption, nil
+
+	case "supernode":
+		if err := once("supernode"); err != nil {
+			return nil, err
+		}
+		if len(rc.Servers) > 0 {
+			servers = rc.Servers
+		}
+		infos, err := serverInfos(servers)
+		if err != nil {
+			return nil, err
+		}
+		return SupernodeClient(infos...), nil
+
+	case "offline":
+		return func(ctx context.Context, ph host.Host, dstore ds.ThreadSafeDatastore) (routing.IpfsRouting, error) {
+			return offroute.NewOfflineRouter(dstore, ph.Peerstore().PrivKey(ph.ID())), nil
+		}, nil
+
+	case "delegated":
+		if rc.Endpoint == "" {
+			return nil, fmt.Errorf("delegated router has no endpoint")
+		}
+		c, err := delegated.NewClient(rc.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		core.AddRecordTypes(c.Validator)
+		return func(context.Context, host.Host, ds.ThreadSafeDatastore) (routing.IpfsRouting, error) {
+			return c, nil
+		}, nil
+
+	case "parallel", "tiered":
+		if len(rc.Routers) == 0 {
+			return nil, fmt.Errorf("%s router has no routers", rc.Type)
+		}
+		var opts []core.RoutingOption
+		for _, sub := range rc.Routers {
+			opt, err := composedOption(sub, servers, used)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, opt)
+		}
+
+		parallel := rc.Type == "parallel"
+		return func(ctx context.Context, ph host.Host, dstore ds.ThreadSafeDatastore) (routing.IpfsRouting, error) {
+			var routers []routing.IpfsRouting
+			for _, opt := range opts {
+				r, err := opt(ctx, ph, dstore)
+				if err != nil {
+					return nil, err
+				}
+				routers = append(routers, r)
+			}
+			if parallel {
+				return compose.Parallel(routers), nil
+			}
+			return compose.Tiered(routers), nil
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown router type: %q", rc.Type)
+	}
+}
+
+// serverInfos returns the peer infos of the supernode servers at addrs.
+func serverInfos(addrs []string) ([]peer.PeerInfo, error) {
+	servers, err := (&config.SupernodeClientConfig{Servers: addrs}).ServerIPFSAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var infos []peer.PeerInfo
+	for _, addr := range servers {
+		infos = append(infos, peer.PeerInfo{
+			ID:    addr.ID(),
+			Addrs: []ma.Multiaddr{addr.Transport()},
+		})
+	}
+	return infos, nil
+}
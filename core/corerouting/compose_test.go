@@ -0,0 +1,80 @@
+package corerouting
+
+import (
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	core "github.com/ipfs/go-ipfs/core"
+	mocknet "github.com/ipfs/go-ipfs/p2p/net/mock"
+	config "github.com/ipfs/go-ipfs/repo/config"
+	compose "github.com/ipfs/go-ipfs/routing/compose"
+)
+
+func TestComposed(t *testing.T) {
+	ctx := context.Background()
+
+	rc := config.Router{
+		Type: "tiered",
+		Routers: []config.Router{
+			{Type: "offline"},
+			{Type: "parallel", Routers: []config.Router{{Type: "dht"}}},
+		},
+	}
+	opt, err := Composed(rc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := mocknet.New(ctx).GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := opt(ctx, h, dssync.MutexWrap(ds.NewMapDatastore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tiered, ok := r.(compose.Tiered)
+	if !ok || len(tiered) != 2 {
+		t.Fatalf("expected two tiers, got %#v", r)
+	}
+	if _, ok := tiered[1].(compose.Parallel); !ok {
+		t.Fatalf("expected a parallel router, got %T", tiered[1])
+	}
+	d, ok := core.FindDHT(r)
+	if !ok {
+		t.Fatal("the dht was not found")
+	}
+	d.Close()
+
+	// the offline tier stores values
+	if err := r.PutValue(ctx, "/foo/bar", []byte("baz")); err != nil {
+		t.Fatal(err)
+	}
+	val, err := tiered[0].GetValue(ctx, "/foo/bar")
+	if err != nil || string(val) != "baz" {
+		t.Fatalf("value not stored: %s, %v", val, err)
+	}
+}
+
+func TestComposedErrors(t *testing.T) {
+	bad := []config.Router{
+		{Type: "bogus"},
+		{Type: "tiered"},
+		{Type: "parallel", Routers: []config.Router{{Type: "dht"}, {Type: "dhtclient"}}},
+		{Type: "tiered", Routers: []config.Router{
+			{Type: "supernode"},
+			{Type: "parallel", Routers: []config.Router{{Type: "supernode"}}},
+		}},
+		{Type: "supernode", Servers: []string{"not an address"}},
+		{Type: "delegated"},
+		{Type: "delegated", Endpoint: "/ip4/1.2.3.4/tcp/8080"},
+	}
+	for _, rc := range bad {
+		if _, err := Composed(rc, config.DefaultSNRServers); err == nil {
+			t.Fatalf("no error for %#v", rc)
+		}
+	}
+}
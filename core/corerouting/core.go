@@ -28,11 +28,23 @@ var (
 // routing records to the provided datastore. Only routing records are store in
 // the datastore.
 func SupernodeServer(recordSource ds.ThreadSafeDatastore) core.RoutingOption {
+	return SupernodeReplicatedServer(recordSource, nil, 0)
+}
+
+// SupernodeReplicatedServer returns a configuration for a routing server
+// that shares its records with the other servers in servers, each record
+// being held by replicas of them. Without servers, it is a SupernodeServer.
+func SupernodeReplicatedServer(recordSource ds.ThreadSafeDatastore, servers []peer.PeerInfo, replicas int) core.RoutingOption {
 	return func(ctx context.Context, ph host.Host, dstore ds.ThreadSafeDatastore) (routing.IpfsRouting, error) {
 		server, err := supernode.NewServer(recordSource, ph.Peerstore(), ph.ID())
 		if err != nil {
 			return nil, err
 		}
+		if len(servers) > 0 {
+			if err := server.Replicate(ph, servers, replicas); err != nil {
+				return nil, err
+			}
+		}
 		proxy := &gcproxy.Loopback{
 			Handler: server,
 			Local:   ph.ID(),
@@ -17,7 +17,7 @@ import (
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
 	"github.com/ipfs/go-ipfs/pin"
 	"github.com/ipfs/go-ipfs/thirdparty/eventlog"
-	unixfs "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
 )
 
 var log = eventlog.Logger("coreunix")
@@ -135,7 +135,7 @@ func addFile(n *core.IpfsNode, file files.File) (*merkledag.Node, error) {
 
 func addDir(n *core.IpfsNode, dir files.File) (*merkledag.Node, error) {
 
-	tree := &merkledag.Node{Data: unixfs.FolderPBData()}
+	tree := uio.NewDirectory(n.DAG)
 
 Loop:
 	for {
@@ -154,15 +154,20 @@ Loop:
 
 		_, name := gopath.Split(file.FileName())
 
-		err = tree.AddNodeLink(name, node)
+		err = tree.SetChild(n.Context(), name, node)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	err := addNode(n, tree)
+	treenode, err := tree.GetNode()
 	if err != nil {
 		return nil, err
 	}
-	return tree, nil
+
+	err = addNode(n, treenode)
+	if err != nil {
+		return nil, err
+	}
+	return treenode, nil
 }
@@ -0,0 +1,132 @@
+package coreunix
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	core "github.com/ipfs/go-ipfs/core"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	mod "github.com/ipfs/go-ipfs/unixfs/mod"
+	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
+)
+
+var ErrNotAFile = errors.New("path does not name a file")
+
+// WriteAt writes the data read from r into the file named by p, starting at
+// offset. Writing past the end of the file extends it, filling any gap with
+// zeros. If truncate is set, the file is cut off after the written data.
+//
+// Nothing is changed in place: the file and the directories above it are
+// copied, and the new root of p is returned. Nothing is pinned.
+func WriteAt(ctx context.Context, n *core.IpfsNode, p path.Path, r io.Reader, offset int64, truncate bool) (*merkledag.Node, error) {
+	if offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+
+	p, err := core.ResolveName(ctx, n, p)
+	if err != nil {
+		return nil, err
+	}
+
+	_, components, err := path.SplitAbsPath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := n.Resolver.ResolvePathComponents(p)
+	if err != nil {
+		return nil, err
+	}
+	root, file := nodes[0], nodes[len(nodes)-1]
+
+	pbd, err := ft.FromBytes(file.Data)
+	if err != nil {
+		return nil, err
+	}
+	switch pbd.GetType() {
+	case ftpb.Data_File, ftpb.Data_Raw:
+	default:
+		return nil, ErrNotAFile
+	}
+
+	dm, err := mod.NewDagModifier(ctx, file, n.DAG, nil, chunk.DefaultSplitter)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WriteFileAt(dm, r, offset, truncate)
+	if err != nil {
+		return nil, err
+	}
+
+	nfile, err := dm.GetNode()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(components) == 0 {
+		_, err = n.DAG.Add(nfile)
+		if err != nil {
+			return nil, err
+		}
+		return nfile, nil
+	}
+
+	e := dagutils.NewDagEditor(n.DAG, root)
+	err = e.InsertNodeAtPath(ctx, strings.Join(components, "/"), nfile, nil)
+	if err != nil {
+		return nil, err
+	}
+	return e.GetNode(), nil
+}
+
+// WritableFile is a unixfs file open for writing, such as a
+// mod.DagModifier or an ipnsfs.File.
+type WritableFile interface {
+	io.WriteSeeker
+	Size() (int64, error)
+	Truncate(size int64) error
+}
+
+// WriteFileAt writes the data read from r into f the way WriteAt does, but
+// leaves it to the caller to store the result.
+func WriteFileAt(f WritableFile, r io.Reader, offset int64, truncate bool) error {
+	if offset < 0 {
+		return errors.New("offset must not be negative")
+	}
+
+	size, err := f.Size()
+	if err != nil {
+		return err
+	}
+	if offset > size {
+		// Truncate extends the file with zeros
+		err = f.Truncate(offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = f.Seek(offset, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	written, err := io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	if truncate {
+		return f.Truncate(offset + written)
+	}
+	return nil
+}
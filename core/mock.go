@@ -69,7 +69,7 @@ func NewMockNode() (*IpfsNode, error) {
 	nd.Pinning = pin.NewPinner(nd.Repo.Datastore(), nd.DAG)
 
 	// Namespace resolver
-	nd.Namesys = nsys.NewNameSystem(nd.Routing)
+	nd.Namesys = nsys.NewNameSystem(nd.Routing, nd.Repo.Datastore())
 
 	// Path resolver
 	nd.Resolver = &path.Resolver{DAG: nd.DAG}
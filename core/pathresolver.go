@@ -1,47 +1,163 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	gopath "path"
 	"strings"
+	"time"
 
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	namesys "github.com/ipfs/go-ipfs/namesys"
 	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	u "github.com/ipfs/go-ipfs/util"
 )
 
-// Resolves the given path by parsing out /ipns/ entries and then going
-// through the /ipfs/ entries and returning the final merkledage node.
-// Effectively enables /ipns/ in CLI commands.
+// DefaultDepthLimit is the number of IPNS names and symlinks Resolve follows
+// before it gives up on a path.
+const DefaultDepthLimit = 32
+
+// ErrDepthLimitExceeded is returned when resolving a path takes more
+// indirections than allowed, such as when names or symlinks form a loop.
+var ErrDepthLimitExceeded = errors.New("path resolution exceeded the depth limit")
+
+// ErrNoNamesys is returned for /ipns/ paths on a node without a name system.
+var ErrNoNamesys = errors.New("cannot resolve /ipns/ paths without a name system")
+
+// Resolve resolves the given path to its merkledag node. Paths may start
+// with /ipfs/<hash>, a bare <hash> or /ipns/<name>, and /ipns/ names and
+// unixfs symlinks met on the way are followed, up to DefaultDepthLimit of
+// them. Effectively enables /ipns/ in CLI commands.
 func Resolve(n *IpfsNode, p path.Path) (*merkledag.Node, error) {
-	strpath := string(p)
-
-	// for now, we only try to resolve ipns paths if
-	// they begin with "/ipns/". Otherwise, ambiguity
-	// emerges when resolving just a <hash>. Is it meant
-	// to be an ipfs or an ipns resolution?
-
-	if strings.HasPrefix(strpath, "/ipns/") {
-		// if it's an ipns path, try to resolve it.
-		// if we can't, we can give that error back to the user.
-		seg := p.Segments()
-		if len(seg) < 2 || seg[1] == "" { // just "/ipns/"
-			return nil, fmt.Errorf("invalid path: %s", string(p))
+	return ResolveDepth(n.Context(), n, p, DefaultDepthLimit)
+}
+
+// ResolveDepth is Resolve with a context and a custom depth limit.
+func ResolveDepth(ctx context.Context, n *IpfsNode, p path.Path, depth int) (*merkledag.Node, error) {
+	nd, _, err := ResolvePath(ctx, n, p, depth)
+	return nd, err
+}
+
+// ResolveObject resolves p like Resolve, but does not follow a symlink at
+// the end of p: the symlink object itself is returned.
+func ResolveObject(n *IpfsNode, p path.Path) (*merkledag.Node, error) {
+	nd, _, err := resolvePath(n.Context(), n, p, DefaultDepthLimit, false)
+	return nd, err
+}
+
+// ResolvePath resolves p like ResolveDepth, and also returns an /ipfs/ path
+// naming the same node without any /ipns/ names or symlinks.
+func ResolvePath(ctx context.Context, n *IpfsNode, p path.Path, depth int) (*merkledag.Node, path.Path, error) {
+	return resolvePath(ctx, n, p, depth, true)
+}
+
+func resolvePath(ctx context.Context, n *IpfsNode, p path.Path, depth int, followLast bool) (*merkledag.Node, path.Path, error) {
+	if depth <= 0 {
+		return nil, "", ErrDepthLimitExceeded
+	}
+
+	seg := p.Segments()
+	switch seg[0] {
+	case "ipns":
+		ipath, steps, err := resolveName(ctx, n, p, depth)
+		if err != nil {
+			return nil, "", err
 		}
+		return resolvePath(ctx, n, ipath, depth-steps, followLast)
+	case "ipfs":
+		seg = seg[1:]
+	}
+
+	// ok, we have an ipfs path now (or what we'll treat as one)
+	if len(seg) == 0 || seg[0] == "" {
+		return nil, "", fmt.Errorf("invalid path: %s", string(p))
+	}
+	h, err := mh.FromB58String(seg[0])
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid path %s: %s", string(p), err)
+	}
 
-		ipnsPath := seg[1]
-		extensions := seg[2:]
-		key, err := n.Namesys.Resolve(n.Context(), ipnsPath)
+	tctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	nd, err := n.DAG.Get(tctx, u.Key(h))
+	if err != nil {
+		return nil, "", err
+	}
+
+	resolved := []string{"", "ipfs", seg[0]}
+	names := seg[1:]
+	for {
+		target, ok := ft.SymlinkTarget(nd.Data)
+		if ok && (followLast || len(names) > 0) {
+			// relative targets start at the directory holding the link
+			if !strings.HasPrefix(target, "/") {
+				if len(resolved) < 4 {
+					return nil, "", fmt.Errorf("relative symlink %q at the root of %s", target, string(p))
+				}
+				dir := path.FromSegments(resolved[:len(resolved)-1]...)
+				target = gopath.Join(dir.String(), target)
+			}
+
+			next := path.FromSegments(append([]string{target}, names...)...)
+			return resolvePath(ctx, n, next, depth-1, followLast)
+		}
+
+		if len(names) == 0 {
+			return nd, path.FromSegments(resolved...), nil
+		}
+
+		nodes, err := n.Resolver.ResolveLinks(nd, names[:1])
 		if err != nil {
-			return nil, err
+			return nil, "", err
+		}
+		nd = nodes[1]
+		resolved = append(resolved, names[0])
+		names = names[1:]
+	}
+}
+
+// ResolveName resolves the /ipns/<name> at the head of p, and returns p
+// with it replaced by the /ipfs/ path it points at. The rest of p is not
+// resolved, so it may name links that do not exist yet. Paths that do not
+// start with /ipns/ are returned as /ipfs/ paths.
+func ResolveName(ctx context.Context, n *IpfsNode, p path.Path) (path.Path, error) {
+	ipath, _, err := resolveName(ctx, n, p, DefaultDepthLimit)
+	return ipath, err
+}
+
+// resolveName is ResolveName following at most depth names. It also
+// returns the number of names followed.
+func resolveName(ctx context.Context, n *IpfsNode, p path.Path, depth int) (path.Path, int, error) {
+	seg := p.Segments()
+	if seg[0] != "ipns" {
+		if seg[0] == "ipfs" {
+			seg = seg[1:]
 		}
+		return path.FromSegments(append([]string{"", "ipfs"}, seg...)...), 0, nil
+	}
 
-		pathHead := make([]string, 2)
-		pathHead[0] = "ipfs"
-		pathHead[1] = key.Pretty()
+	// for now, we only try to resolve ipns paths if they begin with
+	// "/ipns/". Otherwise, ambiguity emerges when resolving just a
+	// <hash>. Is it meant to be an ipfs or an ipns resolution?
+	if len(seg) < 2 || seg[1] == "" { // just "/ipns/"
+		return "", 0, fmt.Errorf("invalid path: %s", string(p))
+	}
+	if n.Namesys == nil {
+		return "", 0, ErrNoNamesys
+	}
 
-		p = path.FromSegments(append(pathHead, extensions...)...)
-		//p = path.RebasePath(path.FromSegments(extensions...), basePath)
+	steps, err := n.Namesys.ResolveN(ctx, seg[1], depth)
+	if err == namesys.ErrResolveRecursion {
+		return "", 0, ErrDepthLimitExceeded
+	}
+	if err != nil {
+		return "", 0, err
 	}
 
-	// ok, we have an ipfs path now (or what we'll treat as one)
-	return n.Resolver.ResolvePath(p)
+	// the name may point below a key, and p below the name
+	ipath := steps[len(steps)-1]
+	return path.FromSegments(append([]string{ipath.String()}, seg[2:]...)...), len(steps), nil
 }
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"testing"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+func addSymlink(t *testing.T, n *IpfsNode, target string) *merkledag.Node {
+	data, err := ft.SymlinkData(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd := &merkledag.Node{Data: data}
+	if _, err := n.DAG.Add(nd); err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
+
+func addDir(t *testing.T, n *IpfsNode, links map[string]*merkledag.Node) (*merkledag.Node, u.Key) {
+	dir := &merkledag.Node{Data: ft.FolderPBData()}
+	for name, child := range links {
+		if err := dir.AddNodeLinkClean(name, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k, err := n.DAG.Add(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, k
+}
+
+func TestResolvePath(t *testing.T) {
+	ctx := context.Background()
+	n, err := NewMockNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := &merkledag.Node{Data: ft.FilePBData([]byte("hello"), 5)}
+	fk, err := n.DAG.Add(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, inner := addDir(t, n, map[string]*merkledag.Node{"file": file})
+	innerNd, err := n.DAG.Get(ctx, inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Namesys.Publish(ctx, n.PrivateKey, inner); err != nil {
+		t.Fatal(err)
+	}
+	name := n.Identity.Pretty()
+
+	_, root := addDir(t, n, map[string]*merkledag.Node{
+		"inner": innerNd,
+		"ipns":  addSymlink(t, n, "/ipns/"+name),
+		"rel":   addSymlink(t, n, "inner/file"),
+		"loop":  addSymlink(t, n, "loop"),
+	})
+
+	cases := []struct {
+		p        string
+		resolved string
+	}{
+		{"/ipfs/" + root.B58String() + "/inner/file", "/ipfs/" + root.B58String() + "/inner/file"},
+		{root.B58String() + "/inner/file", "/ipfs/" + root.B58String() + "/inner/file"},
+		{"/ipns/" + name + "/file", "/ipfs/" + inner.B58String() + "/file"},
+		{"ipns/" + name + "/file", "/ipfs/" + inner.B58String() + "/file"},
+		{"/ipfs/" + root.B58String() + "/ipns/file", "/ipfs/" + inner.B58String() + "/file"},
+		{"/ipfs/" + root.B58String() + "/rel", "/ipfs/" + root.B58String() + "/inner/file"},
+	}
+	for _, c := range cases {
+		nd, resolved, err := ResolvePath(ctx, n, path.Path(c.p), DefaultDepthLimit)
+		if err != nil {
+			t.Fatalf("%s: %s", c.p, err)
+		}
+		k, err := nd.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k != fk {
+			t.Fatalf("%s: resolved to the wrong node", c.p)
+		}
+		if resolved.String() != c.resolved {
+			t.Fatalf("%s: expected %s, got %s", c.p, c.resolved, resolved)
+		}
+	}
+
+	// the symlink itself, and through it
+	nd, err := ResolveObject(n, path.Path("/ipfs/"+root.B58String()+"/rel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target, ok := ft.SymlinkTarget(nd.Data); !ok || target != "inner/file" {
+		t.Fatalf("expected the symlink, got %q", target)
+	}
+	if _, err := ResolveObject(n, path.Path("/ipfs/"+root.B58String()+"/ipns/file")); err != nil {
+		t.Fatal(err)
+	}
+
+	// one hop through the symlink, one through the name
+	via := path.Path("/ipfs/" + root.B58String() + "/ipns/file")
+	if _, err := ResolveDepth(ctx, n, via, 2); err != ErrDepthLimitExceeded {
+		t.Fatalf("expected ErrDepthLimitExceeded, got %v", err)
+	}
+	if _, err := ResolveDepth(ctx, n, via, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	loop := path.Path("/ipfs/" + root.B58String() + "/loop")
+	if _, err := Resolve(n, loop); err != ErrDepthLimitExceeded {
+		t.Fatalf("expected ErrDepthLimitExceeded, got %v", err)
+	}
+
+	if _, err := Resolve(n, path.Path("/ipns/")); err == nil {
+		t.Fatal("resolved an empty name")
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	namesys "github.com/ipfs/go-ipfs/namesys"
+	record "github.com/ipfs/go-ipfs/routing/record"
+)
+
+var recordTypes = struct {
+	sync.Mutex
+	v record.Validator
+}{
+	v: record.Validator{
+		IpnsValidatorTag: namesys.IpnsRecordValidator,
+	},
+}
+
+// RegisterRecordType makes the DHT of nodes constructed from now on accept
+// records for keys in the namespace ns, that is /<ns>/..., as checked by
+// vc. If vc has a Selector, it picks the best of several records found.
+// Set vc.Sign to have the records signed by the node putting them.
+func RegisterRecordType(ns string, vc *record.ValidChecker) error {
+	if vc == nil || vc.Func == nil {
+		return fmt.Errorf("record type %q needs a validator", ns)
+	}
+	if ns == "" || ns == "pk" {
+		return fmt.Errorf("cannot register record type %q", ns)
+	}
+
+	recordTypes.Lock()
+	defer recordTypes.Unlock()
+	if _, found := recordTypes.v[ns]; found {
+		return fmt.Errorf("record type %q already registered", ns)
+	}
+	recordTypes.v[ns] = vc
+	return nil
+}
+
+// AddRecordTypes adds the ipns record type and the registered ones to v,
+// for routing systems checking values outside of the DHT.
+func AddRecordTypes(v record.Validator) {
+	recordTypes.Lock()
+	defer recordTypes.Unlock()
+	for ns, vc := range recordTypes.v {
+		v[ns] = vc
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	mocknet "github.com/ipfs/go-ipfs/p2p/net/mock"
+	dht "github.com/ipfs/go-ipfs/routing/dht"
+	record "github.com/ipfs/go-ipfs/routing/record"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+func TestRegisterRecordType(t *testing.T) {
+	ctx := context.Background()
+
+	vc := &record.ValidChecker{
+		Func: func(k u.Key, val []byte) error {
+			if len(val) == 0 {
+				return errors.New("empty record")
+			}
+			return nil
+		},
+		Sign: true,
+	}
+	if err := RegisterRecordType("test", vc); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterRecordType("test", vc); err == nil {
+		t.Fatal("registered the same record type twice")
+	}
+	if err := RegisterRecordType(IpnsValidatorTag, vc); err == nil {
+		t.Fatal("replaced the ipns record type")
+	}
+
+	h, err := mocknet.New(ctx).GenPeer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := constructDHTRouting(ctx, h, dssync.MutexWrap(ds.NewMapDatastore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := r.(*dht.IpfsDHT)
+	defer d.Close()
+
+	if d.Validator["test"] != vc || d.Validator[IpnsValidatorTag] == nil {
+		t.Fatal("record types missing from the dht")
+	}
+	if signed, err := d.Validator.IsSigned(u.Key("/test/a")); err != nil || !signed {
+		t.Fatal("test records are not signed")
+	}
+}
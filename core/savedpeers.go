@@ -0,0 +1,51 @@
+package core
+
+import (
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+)
+
+// kSavePeersPeriod is how often an online node saves the peers it knows
+// to its repo.
+const kSavePeersPeriod = time.Minute * 10
+
+// loadSavedPeers adds the peers saved by the last run of the node to its
+// peerstore. The good ones seed the routing table, and are bootstrapped
+// to along with the configured bootstrap peers.
+func (n *IpfsNode) loadSavedPeers(ctx context.Context) {
+	good, err := peer.LoadPeers(n.Peerstore, n.Repo.Datastore())
+	if err != nil {
+		log.Warningf("failed to load saved peers: %s", err)
+	}
+	log.Debugf("loaded %d good peers from the last run", len(good))
+	n.savedPeers = good
+
+	if d, ok := FindDHT(n.Routing); ok {
+		for _, p := range good {
+			d.Update(ctx, p)
+		}
+	}
+}
+
+// savePeers saves the peers the node knows to its repo. The ones it is
+// connected to are saved as good ones.
+func (n *IpfsNode) savePeers() error {
+	return peer.SavePeers(n.Peerstore, n.Repo.Datastore(), n.PeerHost.Network().Peers())
+}
+
+func (n *IpfsNode) savePeersEvery(ctx context.Context, period time.Duration) {
+	tick := time.NewTicker(period)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := n.savePeers(); err != nil {
+				log.Warningf("failed to save peers: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package reprovide
+
+import (
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blocks "github.com/ipfs/go-ipfs/blocks/blockstore"
+	pin "github.com/ipfs/go-ipfs/pin"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// KeyChanFunc returns the keys a reprovide run announces. The channel is
+// closed once they have all been sent, or ctx is done.
+type KeyChanFunc func(context.Context) (<-chan u.Key, error)
+
+// NewBlockstoreProvider returns a KeyChanFunc for every block in bstore.
+func NewBlockstoreProvider(bstore blocks.Blockstore) KeyChanFunc {
+	return func(ctx context.Context) (<-chan u.Key, error) {
+		return bstore.AllKeysChan(ctx)
+	}
+}
+
+// NewPinnedProvider returns a KeyChanFunc for the pinned blocks: the pins
+// themselves, and every block under a recursive pin.
+func NewPinnedProvider(pinning pin.Pinner) KeyChanFunc {
+	return func(ctx context.Context) (<-chan u.Key, error) {
+		keys := pinning.DirectKeys()
+		keys = append(keys, pinning.RecursiveKeys()...)
+		for k := range pinning.IndirectKeys() {
+			keys = append(keys, k)
+		}
+		return sendKeys(ctx, keys), nil
+	}
+}
+
+// NewRootsProvider returns a KeyChanFunc for the pins only, without the
+// blocks under them.
+func NewRootsProvider(pinning pin.Pinner) KeyChanFunc {
+	return func(ctx context.Context) (<-chan u.Key, error) {
+		keys := pinning.DirectKeys()
+		keys = append(keys, pinning.RecursiveKeys()...)
+		return sendKeys(ctx, keys), nil
+	}
+}
+
+// NewListProvider returns a KeyChanFunc for the given keys.
+func NewListProvider(keys []u.Key) KeyChanFunc {
+	return func(ctx context.Context) (<-chan u.Key, error) {
+		return sendKeys(ctx, keys), nil
+	}
+}
+
+// sendKeys sends each of keys once on the returned channel.
+func sendKeys(ctx context.Context, keys []u.Key) <-chan u.Key {
+	out := make(chan u.Key)
+	go func() {
+		defer close(out)
+		seen := make(map[u.Key]struct{}, len(keys))
+		for _, k := range keys {
+			if _, ok := seen[k]; ok {
+				continue
+			}
+			seen[k] = struct{}{}
+
+			select {
+			case out <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -1,30 +1,59 @@
 package reprovide
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	backoff "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/cenkalti/backoff"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
-	blocks "github.com/ipfs/go-ipfs/blocks/blockstore"
 	routing "github.com/ipfs/go-ipfs/routing"
 	eventlog "github.com/ipfs/go-ipfs/thirdparty/eventlog"
+	u "github.com/ipfs/go-ipfs/util"
 )
 
 var log = eventlog.Logger("reprovider")
 
+// DefaultBatchSize is how many keys a Reprovider announces at once.
+const DefaultBatchSize = 16
+
+// provideRetryTime is how long announcing a key is retried before giving
+// up on it.
+var provideRetryTime = time.Minute
+
+// ErrRunning is returned when asked to reprovide while a run is going on.
+var ErrRunning = errors.New("reprovider is already running")
+
+// Progress tells how far a reprovide run has got.
+type Progress struct {
+	Provided int // keys announced so far
+	Failed   int // keys that could not be announced
+}
+
 type Reprovider struct {
 	// The routing system to provide values through
 	rsys routing.IpfsRouting
 
-	// The backing store for blocks to be provided
-	bstore blocks.Blockstore
+	// The keys to provide
+	keyProvider KeyChanFunc
+
+	// BatchSize is how many keys are announced at once
+	BatchSize int
+
+	// RateLimit is the most keys announced per second. Zero means no
+	// limit.
+	RateLimit int
+
+	runlk   sync.Mutex
+	running bool
 }
 
-func NewReprovider(rsys routing.IpfsRouting, bstore blocks.Blockstore) *Reprovider {
+func NewReprovider(rsys routing.IpfsRouting, keyProvider KeyChanFunc) *Reprovider {
 	return &Reprovider{
-		rsys:   rsys,
-		bstore: bstore,
+		rsys:        rsys,
+		keyProvider: keyProvider,
+		BatchSize:   DefaultBatchSize,
 	}
 }
 
@@ -47,27 +76,131 @@ func (rp *Reprovider) ProvideEvery(ctx context.Context, tick time.Duration) {
 	}
 }
 
+// Reprovide announces every key of the reprovider once.
 func (rp *Reprovider) Reprovide(ctx context.Context) error {
-	keychan, err := rp.bstore.AllKeysChan(ctx)
+	return rp.ReprovideProgress(ctx, nil)
+}
+
+// ReprovideProgress announces every key of the reprovider once, and sends
+// the progress made on progress after each batch, if it is not nil. Keys
+// that cannot be announced do not stop the run, but make it return an
+// error at the end.
+func (rp *Reprovider) ReprovideProgress(ctx context.Context, progress chan<- Progress) error {
+	rp.runlk.Lock()
+	if rp.running {
+		rp.runlk.Unlock()
+		return ErrRunning
+	}
+	rp.running = true
+	rp.runlk.Unlock()
+	defer func() {
+		rp.runlk.Lock()
+		rp.running = false
+		rp.runlk.Unlock()
+	}()
+
+	keychan, err := rp.keyProvider(ctx)
 	if err != nil {
-		return fmt.Errorf("Failed to get key chan from blockstore: %s", err)
+		return fmt.Errorf("Failed to get key chan: %s", err)
 	}
-	for k := range keychan {
-		op := func() error {
-			err := rp.rsys.Provide(ctx, k)
-			if err != nil {
-				log.Debugf("Failed to provide key: %s", err)
+
+	batchSize := rp.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var prog Progress
+	var lastErr error
+	for {
+		batch := nextBatch(ctx, keychan, batchSize)
+		if len(batch) == 0 || ctx.Err() != nil {
+			break
+		}
+
+		start := time.Now()
+		errs := make(chan error, len(batch))
+		for _, k := range batch {
+			go func(k u.Key) {
+				errs <- rp.provide(ctx, k)
+			}(k)
+		}
+		for _ = range batch {
+			if err := <-errs; err != nil {
+				prog.Failed++
+				lastErr = err
+			} else {
+				prog.Provided++
 			}
-			return err
 		}
 
-		// TODO: this backoff library does not respect our context, we should
-		// eventually work contexts into it. low priority.
-		err := backoff.Retry(op, backoff.NewExponentialBackOff())
-		if err != nil {
-			log.Debugf("Providing failed after number of retries: %s", err)
-			return err
+		if progress != nil {
+			select {
+			case progress <- prog:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
+
+		if len(batch) < batchSize {
+			break // no keys left
+		}
+		if rp.RateLimit > 0 {
+			wait := time.Duration(len(batch))*time.Second/time.Duration(rp.RateLimit) - time.Since(start)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if lastErr != nil {
+		return fmt.Errorf("failed to provide %d of %d keys: %s", prog.Failed, prog.Provided+prog.Failed, lastErr)
 	}
 	return nil
 }
+
+func (rp *Reprovider) provide(ctx context.Context, k u.Key) error {
+	op := func() error {
+		if ctx.Err() != nil {
+			return nil // stop retrying, the run is over
+		}
+		err := rp.rsys.Provide(ctx, k)
+		if err != nil {
+			log.Debugf("Failed to provide key: %s", err)
+		}
+		return err
+	}
+
+	// this backoff library does not respect our context, so op checks it
+	// to stop retrying.
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = provideRetryTime
+	err := backoff.Retry(op, b)
+	if err != nil {
+		log.Debugf("Providing failed after number of retries: %s", err)
+		return err
+	}
+	return ctx.Err()
+}
+
+// nextBatch reads up to n keys from keys. It returns fewer once keys is
+// closed, or ctx is done.
+func nextBatch(ctx context.Context, keys <-chan u.Key, n int) []u.Key {
+	var batch []u.Key
+	for len(batch) < n {
+		select {
+		case k, ok := <-keys:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, k)
+		case <-ctx.Done():
+			return batch
+		}
+	}
+	return batch
+}
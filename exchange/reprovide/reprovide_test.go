@@ -1,14 +1,21 @@
 package reprovide_test
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	blocks "github.com/ipfs/go-ipfs/blocks"
 	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	bserv "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	pin "github.com/ipfs/go-ipfs/pin"
 	mock "github.com/ipfs/go-ipfs/routing/mock"
+	u "github.com/ipfs/go-ipfs/util"
 	testutil "github.com/ipfs/go-ipfs/util/testutil"
 
 	. "github.com/ipfs/go-ipfs/exchange/reprovide"
@@ -31,7 +38,7 @@ func TestReprovide(t *testing.T) {
 	blk := blocks.NewBlock([]byte("this is a test"))
 	bstore.Put(blk)
 
-	reprov := NewReprovider(clA, bstore)
+	reprov := NewReprovider(clA, NewBlockstoreProvider(bstore))
 	err := reprov.Reprovide(ctx)
 	if err != nil {
 		t.Fatal(err)
@@ -50,3 +57,139 @@ func TestReprovide(t *testing.T) {
 		t.Fatal("Somehow got the wrong peer back as a provider.")
 	}
 }
+
+func collectKeys(t *testing.T, kp KeyChanFunc) map[u.Key]bool {
+	keys, err := kp(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[u.Key]bool)
+	for k := range keys {
+		if out[k] {
+			t.Fatalf("key %s sent twice", k)
+		}
+		out[k] = true
+	}
+	return out
+}
+
+func TestStrategies(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bstore := blockstore.NewBlockstore(dstore)
+	bs, err := bserv.New(bstore, offline.Exchange(bstore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dserv := mdag.NewDAGService(bs)
+	pinning := pin.NewPinner(dstore, dserv)
+
+	node := func(data string) (*mdag.Node, u.Key) {
+		nd := &mdag.Node{Data: []byte(data)}
+		k, err := dserv.Add(nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nd, k
+	}
+
+	// a is pinned directly, b recursively with its child c, d not at all
+	a, ak := node("a")
+	c, ck := node("child")
+	b := &mdag.Node{Data: []byte("b")}
+	if err := b.AddNodeLink("c", c); err != nil {
+		t.Fatal(err)
+	}
+	bk, err := dserv.Add(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dk := node("d")
+
+	if err := pinning.Pin(ctx, a, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinning.Pin(ctx, b, true); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		kp   KeyChanFunc
+		keys []u.Key
+	}{
+		{"all", NewBlockstoreProvider(bstore), []u.Key{ak, bk, ck, dk}},
+		{"pinned", NewPinnedProvider(pinning), []u.Key{ak, bk, ck}},
+		{"roots", NewRootsProvider(pinning), []u.Key{ak, bk}},
+		{"list", NewListProvider([]u.Key{dk, ak, dk}), []u.Key{ak, dk}},
+	}
+	for _, c := range cases {
+		got := collectKeys(t, c.kp)
+		if len(got) != len(c.keys) {
+			t.Fatalf("%s: expected %d keys, got %d", c.name, len(c.keys), len(got))
+		}
+		for _, k := range c.keys {
+			if !got[k] {
+				t.Fatalf("%s: missing key %s", c.name, k)
+			}
+		}
+	}
+}
+
+func TestReprovideBatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mrserv := mock.NewServer()
+	idA := testutil.RandIdentityOrFatal(t)
+	clA := mrserv.Client(idA)
+	clB := mrserv.Client(testutil.RandIdentityOrFatal(t))
+
+	var keys []u.Key
+	for i := 0; i < 40; i++ {
+		keys = append(keys, u.Key(u.Hash([]byte(fmt.Sprint(i)))))
+	}
+
+	reprov := NewReprovider(clA, NewListProvider(keys))
+	reprov.BatchSize = 16
+	reprov.RateLimit = 400
+
+	progress := make(chan Progress)
+	var got []int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range progress {
+			if p.Failed != 0 {
+				t.Errorf("%d keys failed", p.Failed)
+			}
+			got = append(got, p.Provided)
+		}
+	}()
+
+	start := time.Now()
+	err := reprov.ReprovideProgress(ctx, progress)
+	close(progress)
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint([]int{16, 32, 40}) {
+		t.Fatalf("unexpected progress: %v", got)
+	}
+	// two full batches wait for the rate limit
+	if took := time.Since(start); took < time.Millisecond*80 {
+		t.Fatalf("rate limit not kept: took %s", took)
+	}
+
+	for _, k := range keys {
+		provs, err := clB.FindProviders(ctx, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(provs) == 0 || provs[0].ID != idA.ID() {
+			t.Fatalf("%s was not provided", k)
+		}
+	}
+}
@@ -252,8 +252,13 @@ func (s *Directory) Lookup(ctx context.Context, name string) (fs.Node, error) {
 
 // ReadDirAll reads the link structure as directory entries
 func (dir *Directory) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
+	names, err := dir.dir.List()
+	if err != nil {
+		return nil, err
+	}
+
 	var entries []fuse.Dirent
-	for _, name := range dir.dir.List() {
+	for _, name := range names {
 		dirent := fuse.Dirent{Name: name}
 
 		// TODO: make dir.dir.List() return dirinfos
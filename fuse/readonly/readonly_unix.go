@@ -91,7 +91,7 @@ func (s *Node) Attr() fuse.Attr {
 		s.loadData()
 	}
 	switch s.cached.GetType() {
-	case ftpb.Data_Directory:
+	case ftpb.Data_Directory, ftpb.Data_HAMTShard:
 		return fuse.Attr{
 			Mode: os.ModeDir | 0555,
 			Uid:  uint32(os.Getuid()),
@@ -136,8 +136,21 @@ func (s *Node) Lookup(ctx context.Context, name string) (fs.Node, error) {
 // ReadDirAll reads the link structure as directory entries
 func (s *Node) ReadDirAll(ctx context.Context) ([]fuse.Dirent, error) {
 	log.Debug("Node ReadDir")
-	entries := make([]fuse.Dirent, len(s.Nd.Links))
-	for i, link := range s.Nd.Links {
+	links := s.Nd.Links
+	if uio.IsDirectory(s.Nd) {
+		dir, err := uio.NewDirectoryFromNode(s.Ipfs.DAG, s.Nd)
+		if err != nil {
+			return nil, err
+		}
+
+		links, err = dir.Links(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]fuse.Dirent, len(links))
+	for i, link := range links {
 		n := link.Name
 		if len(n) == 0 {
 			n = link.Hash.B58String()
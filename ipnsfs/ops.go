@@ -0,0 +1,216 @@
+package ipnsfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	gopath "path"
+	"strings"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+// The functions below operate on slash separated paths below a root
+// Directory. Paths are cleaned first, and "/" names the root itself.
+
+// Lookup returns the file or directory at path p below root
+func Lookup(root *Directory, p string) (FSNode, error) {
+	parts := splitPath(p)
+
+	var cur FSNode = root
+	for i, name := range parts {
+		dir, ok := cur.(*Directory)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a directory", gopath.Join(parts[:i]...))
+		}
+
+		child, err := dir.Child(name)
+		if err != nil {
+			return nil, err
+		}
+		cur = child
+	}
+	return cur, nil
+}
+
+// DirLookup returns the directory at path p below root
+func DirLookup(root *Directory, p string) (*Directory, error) {
+	nd, err := Lookup(root, p)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, ok := nd.(*Directory)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a directory", p)
+	}
+	return dir, nil
+}
+
+// Mkdir creates a directory at path p below root. If parents is set,
+// missing parent directories are created and an existing directory at p is
+// not an error.
+func Mkdir(root *Directory, p string, parents bool) error {
+	parts := splitPath(p)
+	if len(parts) == 0 {
+		if parents {
+			return nil
+		}
+		return os.ErrExist
+	}
+
+	cur := root
+	for i, name := range parts {
+		last := i == len(parts)-1
+
+		next, err := cur.Child(name)
+		switch {
+		case err == os.ErrNotExist && (parents || last):
+			cur, err = cur.Mkdir(name)
+			if err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		case last && !parents:
+			return os.ErrExist
+		}
+
+		dir, ok := next.(*Directory)
+		if !ok {
+			return fmt.Errorf("%s is not a directory", gopath.Join(parts[:i+1]...))
+		}
+		cur = dir
+	}
+	return nil
+}
+
+// PutNode links nd at path p below root. The parent directory must exist,
+// and nothing may exist at p yet.
+func PutNode(root *Directory, p string, nd *dag.Node) error {
+	dirp, name := gopath.Split(cleanPath(p))
+	if name == "" {
+		return errors.New("cannot put a node at the root")
+	}
+
+	dir, err := DirLookup(root, dirp)
+	if err != nil {
+		return err
+	}
+
+	_, err = dir.Child(name)
+	if err == nil {
+		return os.ErrExist
+	}
+	if err != os.ErrNotExist {
+		return err
+	}
+	return dir.AddChild(name, nd)
+}
+
+// Remove unlinks the entry at path p below root. Directories are only
+// removed if recursive is set.
+func Remove(root *Directory, p string, recursive bool) error {
+	dirp, name := gopath.Split(cleanPath(p))
+	if name == "" {
+		return errors.New("cannot remove the root")
+	}
+
+	dir, err := DirLookup(root, dirp)
+	if err != nil {
+		return err
+	}
+
+	child, err := dir.Child(name)
+	if err != nil {
+		return err
+	}
+	if child.Type() == TDir && !recursive {
+		return ErrIsDirectory
+	}
+	return dir.Unlink(name)
+}
+
+// Mv moves the entry at path src below root to dst. If dst is an existing
+// directory, the entry is moved into it, keeping its name.
+func Mv(root *Directory, src, dst string) error {
+	src = cleanPath(src)
+	dst = cleanPath(dst)
+	if src == "/" {
+		return errors.New("cannot move the root")
+	}
+	if dst == src || strings.HasPrefix(dst, src+"/") {
+		return fmt.Errorf("cannot move %s into itself", src)
+	}
+
+	child, err := Lookup(root, src)
+	if err != nil {
+		return err
+	}
+
+	if _, err := DirLookup(root, dst); err == nil {
+		dst = gopath.Join(dst, gopath.Base(src))
+	}
+
+	nd, err := child.GetNode()
+	if err != nil {
+		return err
+	}
+
+	err = PutNode(root, dst, nd)
+	if err != nil {
+		return err
+	}
+	return Remove(root, src, true)
+}
+
+// Stat describes the node nd of an entry
+type Stat struct {
+	Type           NodeType
+	Size           uint64
+	CumulativeSize uint64
+	Blocks         int
+}
+
+// StatNode returns the Stat of the dag node of an entry
+func StatNode(nd *dag.Node) (*Stat, error) {
+	pbn, err := ft.FromBytes(nd.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	cumsize, err := nd.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Stat{
+		CumulativeSize: cumsize,
+		Blocks:         len(nd.Links),
+	}
+	switch pbn.GetType() {
+	case ft.TDirectory, ft.THAMTShard:
+		st.Type = TDir
+	default:
+		st.Type = TFile
+		st.Size = pbn.GetFilesize()
+		if pbn.GetType() == ft.TRaw {
+			st.Size = uint64(len(pbn.Data))
+		}
+	}
+	return st, nil
+}
+
+func cleanPath(p string) string {
+	return gopath.Clean("/" + p)
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(cleanPath(p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
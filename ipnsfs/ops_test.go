@@ -0,0 +1,210 @@
+package ipnsfs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	pin "github.com/ipfs/go-ipfs/pin"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+)
+
+// nopCloser stands in for a KeyRoot
+type nopCloser struct{}
+
+func (nopCloser) closeChild(string, *dag.Node) error { return nil }
+
+func getTestRoot(t *testing.T) *Directory {
+	dserv := mdtest.Mock(t)
+	fs := &Filesystem{
+		dserv: dserv,
+		pins:  pin.NewPinner(dssync.MutexWrap(ds.NewMapDatastore()), dserv),
+	}
+
+	root, err := NewDirectory("root", &dag.Node{Data: ft.FolderPBData()}, nopCloser{}, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func checkList(t *testing.T, root *Directory, p string, exp ...string) {
+	dir, err := DirLookup(root, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := dir.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	sort.Strings(exp)
+	if len(names) != len(exp) {
+		t.Fatalf("%s: expected %v, got %v", p, exp, names)
+	}
+	for i := range names {
+		if names[i] != exp[i] {
+			t.Fatalf("%s: expected %v, got %v", p, exp, names)
+		}
+	}
+}
+
+func readFile(t *testing.T, root *Directory, p string) []byte {
+	fsn, err := Lookup(root, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd, err := fsn.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dr, err := uio.NewDagReader(context.Background(), nd, root.fs.dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestMkdir(t *testing.T) {
+	root := getTestRoot(t)
+
+	if err := Mkdir(root, "/a", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mkdir(root, "/a", false); err != os.ErrExist {
+		t.Fatalf("expected ErrExist, got %v", err)
+	}
+	if err := Mkdir(root, "/x/y", false); err != os.ErrNotExist {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+	if err := Mkdir(root, "/a/b/c", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Mkdir(root, "/a/b", true); err != nil {
+		t.Fatal(err)
+	}
+
+	checkList(t, root, "/", "a")
+	checkList(t, root, "/a", "b")
+	checkList(t, root, "/a/b", "c")
+	checkList(t, root, "/a/b/c")
+}
+
+func TestPutMoveRemove(t *testing.T) {
+	root := getTestRoot(t)
+	if err := Mkdir(root, "/a/b", true); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("some data")
+	nd := &dag.Node{Data: ft.FilePBData(data, uint64(len(data)))}
+	if err := PutNode(root, "/a/file", nd); err != nil {
+		t.Fatal(err)
+	}
+	if err := PutNode(root, "/a/file", nd); err != os.ErrExist {
+		t.Fatalf("expected ErrExist, got %v", err)
+	}
+	if !bytes.Equal(readFile(t, root, "/a/file"), data) {
+		t.Fatal("read wrong data")
+	}
+
+	// into an existing directory
+	if err := Mv(root, "/a/file", "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	checkList(t, root, "/a", "b")
+	checkList(t, root, "/a/b", "file")
+
+	// to a new name
+	if err := Mv(root, "/a/b/file", "/moved"); err != nil {
+		t.Fatal(err)
+	}
+	checkList(t, root, "/", "a", "moved")
+	checkList(t, root, "/a/b")
+	if !bytes.Equal(readFile(t, root, "/moved"), data) {
+		t.Fatal("read wrong data after move")
+	}
+
+	if err := Mv(root, "/a", "/a/b"); err == nil {
+		t.Fatal("moved a directory into itself")
+	}
+
+	if err := Remove(root, "/a", false); err != ErrIsDirectory {
+		t.Fatalf("expected ErrIsDirectory, got %v", err)
+	}
+	if err := Remove(root, "/a", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(root, "/a", true); err != os.ErrNotExist {
+		t.Fatalf("expected ErrNotExist, got %v", err)
+	}
+	checkList(t, root, "/", "moved")
+}
+
+func TestWriteNewFile(t *testing.T) {
+	root := getTestRoot(t)
+
+	empty := &dag.Node{Data: ft.FilePBData(nil, 0)}
+	if err := PutNode(root, "/file", empty); err != nil {
+		t.Fatal(err)
+	}
+
+	// the node must be stored, not just linked
+	k, err := empty.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.fs.dserv.Get(context.Background(), k); err != nil {
+		t.Fatal(err)
+	}
+
+	fsn, err := Lookup(root, "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi := fsn.(*File)
+
+	if _, err := fi.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(readFile(t, root, "/file")) != "hello" {
+		t.Fatal("read wrong data")
+	}
+
+	st, err := StatNode(mustGetNode(t, fi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Type != TFile || st.Size != 5 {
+		t.Fatalf("bad stat: %+v", st)
+	}
+}
+
+func mustGetNode(t *testing.T, fsn FSNode) *dag.Node {
+	nd, err := fsn.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nd
+}
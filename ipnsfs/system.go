@@ -178,8 +178,12 @@ func (fs *Filesystem) newKeyRoot(parent context.Context, k ci.PrivKey) (*KeyRoot
 	}
 
 	switch pbn.GetType() {
-	case ft.TDirectory:
-		root.val = NewDirectory(pointsTo.B58String(), mnode, root, fs)
+	case ft.TDirectory, ft.THAMTShard:
+		dir, err := NewDirectory(pointsTo.B58String(), mnode, root, fs)
+		if err != nil {
+			return nil, err
+		}
+		root.val = dir
 	case ft.TFile, ft.TMetadata, ft.TRaw:
 		fi, err := NewFile(pointsTo.B58String(), mnode, root, fs)
 		if err != nil {
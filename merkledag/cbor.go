@@ -0,0 +1,244 @@
+package merkledag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+)
+
+// Nodes are encoded in CBOR (RFC 7049) as a map with two entries:
+//
+//   "Data":  byte string
+//   "Links": array of maps with the entries
+//            "Hash": byte string, the multihash of the target
+//            "Name": text string
+//            "Size": unsigned integer
+//
+// The encoding is canonical: map keys are sorted, lengths are as short as
+// possible and links are sorted by name, so equal nodes encode to equal bytes.
+// Only the parts of CBOR needed for this are supported.
+
+var ErrCBORFormat = errors.New("merkledag: invalid cbor node")
+
+const (
+	cborUint  = 0
+	cborBytes = 2
+	cborText  = 3
+	cborArray = 4
+	cborMap   = 5
+)
+
+// MarshalCBOR encodes a *Node instance into CBOR
+func (n *Node) MarshalCBOR() ([]byte, error) {
+	sort.Stable(LinkSlice(n.Links)) // keep links sorted
+
+	var buf bytes.Buffer
+	cborHead(&buf, cborMap, 2)
+	cborString(&buf, cborText, "Data")
+	cborString(&buf, cborBytes, string(n.Data))
+	cborString(&buf, cborText, "Links")
+	cborHead(&buf, cborArray, uint64(len(n.Links)))
+	for _, l := range n.Links {
+		cborHead(&buf, cborMap, 3)
+		cborString(&buf, cborText, "Hash")
+		cborString(&buf, cborBytes, string(l.Hash))
+		cborString(&buf, cborText, "Name")
+		cborString(&buf, cborText, l.Name)
+		cborString(&buf, cborText, "Size")
+		cborHead(&buf, cborUint, l.Size)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCBOR decodes a node encoded with MarshalCBOR
+func (n *Node) UnmarshalCBOR(encoded []byte) error {
+	d := &cborDecoder{buf: encoded}
+
+	fields, err := d.head(cborMap)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	var links []*Link
+	for i := uint64(0); i < fields; i++ {
+		key, err := d.str(cborText)
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "Data":
+			s, err := d.str(cborBytes)
+			if err != nil {
+				return err
+			}
+			data = []byte(s)
+		case "Links":
+			links, err = d.links()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("merkledag: unknown cbor node field %q", key)
+		}
+	}
+
+	if len(d.buf) != 0 {
+		return ErrCBORFormat
+	}
+
+	sort.Stable(LinkSlice(links))
+	n.encoded = nil
+	n.Data = data
+	n.Links = links
+	return nil
+}
+
+// DecodedCBOR decodes CBOR data and returns a new Node instance.
+func DecodedCBOR(encoded []byte) (*Node, error) {
+	n := new(Node)
+	err := n.UnmarshalCBOR(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("incorrectly formatted merkledag node: %s", err)
+	}
+	return n, nil
+}
+
+func cborHead(buf *bytes.Buffer, major byte, v uint64) {
+	major <<= 5
+	switch {
+	case v < 24:
+		buf.WriteByte(major | byte(v))
+	case v <= 0xff:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(v))
+	case v <= 0xffff:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(v))
+	case v <= 0xffffffff:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(v))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+func cborString(buf *bytes.Buffer, major byte, s string) {
+	cborHead(buf, major, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+type cborDecoder struct {
+	buf []byte
+}
+
+func (d *cborDecoder) head(major byte) (uint64, error) {
+	if len(d.buf) == 0 || d.buf[0]>>5 != major {
+		return 0, ErrCBORFormat
+	}
+
+	info := d.buf[0] & 0x1f
+	d.buf = d.buf[1:]
+
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// indefinite lengths are not canonical
+		return 0, ErrCBORFormat
+	}
+
+	if len(d.buf) < size {
+		return 0, ErrCBORFormat
+	}
+
+	var v uint64
+	for _, b := range d.buf[:size] {
+		v = v<<8 | uint64(b)
+	}
+	d.buf = d.buf[size:]
+	return v, nil
+}
+
+func (d *cborDecoder) str(major byte) (string, error) {
+	l, err := d.head(major)
+	if err != nil {
+		return "", err
+	}
+	if uint64(len(d.buf)) < l {
+		return "", ErrCBORFormat
+	}
+
+	s := string(d.buf[:l])
+	d.buf = d.buf[l:]
+	return s, nil
+}
+
+func (d *cborDecoder) links() ([]*Link, error) {
+	count, err := d.head(cborArray)
+	if err != nil {
+		return nil, err
+	}
+
+	// every link takes at least a byte, do not trust larger counts
+	if count > uint64(len(d.buf)) {
+		return nil, ErrCBORFormat
+	}
+
+	links := make([]*Link, 0, count)
+	for i := uint64(0); i < count; i++ {
+		fields, err := d.head(cborMap)
+		if err != nil {
+			return nil, err
+		}
+
+		l := new(Link)
+		for j := uint64(0); j < fields; j++ {
+			key, err := d.str(cborText)
+			if err != nil {
+				return nil, err
+			}
+
+			switch key {
+			case "Hash":
+				h, err := d.str(cborBytes)
+				if err != nil {
+					return nil, err
+				}
+				l.Hash, err = mh.Cast([]byte(h))
+				if err != nil {
+					return nil, fmt.Errorf("Link hash is not valid multihash. %v", err)
+				}
+			case "Name":
+				l.Name, err = d.str(cborText)
+			case "Size":
+				l.Size, err = d.head(cborUint)
+			default:
+				return nil, fmt.Errorf("merkledag: unknown cbor link field %q", key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if l.Hash == nil {
+			return nil, ErrCBORFormat
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
@@ -0,0 +1,66 @@
+package merkledag
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCBORRoundtrip(t *testing.T) {
+	child1 := &Node{Data: []byte("child one")}
+	child2 := &Node{Data: []byte(strings.Repeat("x", 300))}
+
+	n := &Node{Data: []byte{0x00, 0xff, 0xfe, 'b', 'i', 'n'}}
+	if err := n.AddNodeLink("zz", child2); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.AddNodeLink("aa", child1); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := n.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := DecodedCBOR(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out.Data, n.Data) {
+		t.Fatal("data did not survive the roundtrip")
+	}
+
+	k1, _ := n.Key()
+	k2, _ := out.Key()
+	if k1 != k2 {
+		t.Fatal("decoded node has a different key")
+	}
+
+	reenc, err := out.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, reenc) {
+		t.Fatal("encoding is not canonical")
+	}
+}
+
+func TestCBORInvalid(t *testing.T) {
+	n := &Node{Data: []byte("data")}
+	n.AddNodeLink("link", &Node{Data: []byte("child")})
+	enc, _ := n.MarshalCBOR()
+
+	for i := 0; i < len(enc); i++ {
+		_, err := DecodedCBOR(enc[:i])
+		if err == nil {
+			t.Fatalf("truncated input of %d bytes decoded without error", i)
+		}
+	}
+
+	_, err := DecodedCBOR(append(enc, 0))
+	if err == nil {
+		t.Fatal("trailing data decoded without error")
+	}
+}
@@ -0,0 +1,284 @@
+package dagutils
+
+import (
+	"bytes"
+	"fmt"
+	gopath "path"
+	"sort"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// ChangeType is the kind of a Change
+type ChangeType int
+
+const (
+	Add ChangeType = iota
+	Remove
+	Mod
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case Add:
+		return "added"
+	case Remove:
+		return "removed"
+	case Mod:
+		return "modified"
+	default:
+		return fmt.Sprintf("ChangeType(%d)", int(t))
+	}
+}
+
+// Change is a single difference between two DAGs. Path is relative to the
+// roots, with "" naming the roots themselves. Before is empty for additions,
+// After for removals.
+type Change struct {
+	Type   ChangeType
+	Path   string
+	Before u.Key
+	After  u.Key
+}
+
+func (c *Change) String() string {
+	switch c.Type {
+	case Add:
+		return fmt.Sprintf("added %s: %s", c.Path, c.After.B58String())
+	case Remove:
+		return fmt.Sprintf("removed %s: %s", c.Path, c.Before.B58String())
+	default:
+		return fmt.Sprintf("modified %s: %s -> %s", c.Path, c.Before.B58String(), c.After.B58String())
+	}
+}
+
+// Diff returns the changes between the DAGs below a and b. Links are matched
+// by name, and subtrees with the same hash on both sides are skipped. When a
+// node is present on both sides with different hashes, its links are
+// compared, and it is reported as modified itself if its data differs, it
+// has no links on both sides, or its links that cannot be told apart by
+// name differ. Those are links without a name, such as the blocks of a
+// file, or sharing it with others, and are not compared further. Added and
+// removed subtrees are reported by their root alone.
+func Diff(ctx context.Context, ds dag.DAGService, a, b *dag.Node) ([]*Change, error) {
+	d := &differ{ctx: ctx, dserv: ds}
+	err := d.diff("", a, b)
+	return d.changes, err
+}
+
+// DiffUnixfs returns the changes between two unixfs trees. Directories,
+// sharded or not, are compared by their entries. Everything else is
+// compared as a whole, so changes are reported for file paths. Added and
+// removed directories are reported as one change for every file in them, or
+// as a change of their own if they are empty.
+func DiffUnixfs(ctx context.Context, ds dag.DAGService, a, b *dag.Node) ([]*Change, error) {
+	d := &differ{ctx: ctx, dserv: ds, unixfs: true}
+	err := d.diff("", a, b)
+	return d.changes, err
+}
+
+type differ struct {
+	ctx     context.Context
+	dserv   dag.DAGService
+	unixfs  bool
+	changes []*Change
+}
+
+func (d *differ) diff(p string, a, b *dag.Node) error {
+	ak, err := a.Key()
+	if err != nil {
+		return err
+	}
+	bk, err := b.Key()
+	if err != nil {
+		return err
+	}
+	if ak == bk {
+		return nil
+	}
+
+	if d.unixfs {
+		if !uio.IsDirectory(a) || !uio.IsDirectory(b) {
+			d.add(Mod, p, ak, bk)
+			return nil
+		}
+	} else if !bytes.Equal(a.Data, b.Data) || len(a.Links) == 0 && len(b.Links) == 0 ||
+		!sameLinks(unnamedLinks(a), unnamedLinks(b)) {
+		d.add(Mod, p, ak, bk)
+	}
+
+	alinks, err := d.links(a)
+	if err != nil {
+		return err
+	}
+	blinks, err := d.links(b)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]struct{})
+	for name := range alinks {
+		names[name] = struct{}{}
+	}
+	for name := range blinks {
+		names[name] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		al, inA := alinks[name]
+		bl, inB := blinks[name]
+		cp := gopath.Join(p, name)
+
+		switch {
+		case !inA:
+			err = d.added(Add, cp, bl)
+		case !inB:
+			err = d.added(Remove, cp, al)
+		case u.Key(al.Hash) == u.Key(bl.Hash):
+			continue
+		default:
+			var an, bn *dag.Node
+			an, err = al.GetNode(d.ctx, d.dserv)
+			if err != nil {
+				return err
+			}
+			bn, err = bl.GetNode(d.ctx, d.dserv)
+			if err != nil {
+				return err
+			}
+			err = d.diff(cp, an, bn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// added reports the subtree at lnk as added or removed
+func (d *differ) added(t ChangeType, p string, lnk *dag.Link) error {
+	if !d.unixfs {
+		d.addLink(t, p, lnk)
+		return nil
+	}
+
+	nd, err := lnk.GetNode(d.ctx, d.dserv)
+	if err != nil {
+		return err
+	}
+	if !uio.IsDirectory(nd) {
+		d.addLink(t, p, lnk)
+		return nil
+	}
+
+	links, err := d.links(nd)
+	if err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		// nothing below it would show the empty directory
+		d.addLink(t, p, lnk)
+		return nil
+	}
+
+	names := make([]string, 0, len(links))
+	for name := range links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := d.added(t, gopath.Join(p, name), links[name])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *differ) addLink(t ChangeType, p string, lnk *dag.Link) {
+	if t == Add {
+		d.add(t, p, "", u.Key(lnk.Hash))
+	} else {
+		d.add(t, p, u.Key(lnk.Hash), "")
+	}
+}
+
+func (d *differ) add(t ChangeType, p string, before, after u.Key) {
+	d.changes = append(d.changes, &Change{
+		Type:   t,
+		Path:   p,
+		Before: before,
+		After:  after,
+	})
+}
+
+// links returns the links of nd by name, leaving out the unnamed ones. In
+// unixfs mode, directories are read by their entries.
+func (d *differ) links(nd *dag.Node) (map[string]*dag.Link, error) {
+	out := make(map[string]*dag.Link)
+	if !d.unixfs {
+		names := linkNames(nd)
+		for _, l := range nd.Links {
+			if l.Name != "" && names[l.Name] == 1 {
+				out[l.Name] = l
+			}
+		}
+		return out, nil
+	}
+
+	dir, err := uio.NewDirectoryFromNode(d.dserv, nd)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dir.ForEachLink(d.ctx, func(l *dag.Link) error {
+		out[l.Name] = l
+		return nil
+	})
+	return out, err
+}
+
+// unnamedLinks returns, in order, the links of nd that have no name, or
+// share it with other links
+func unnamedLinks(nd *dag.Node) []*dag.Link {
+	names := linkNames(nd)
+	var out []*dag.Link
+	for _, l := range nd.Links {
+		if l.Name == "" || names[l.Name] > 1 {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// linkNames counts the links of nd with each name
+func linkNames(nd *dag.Node) map[string]int {
+	names := make(map[string]int)
+	for _, l := range nd.Links {
+		names[l.Name]++
+	}
+	return names
+}
+
+func sameLinks(a, b []*dag.Link) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || u.Key(a[i].Hash) != u.Key(b[i].Hash) {
+			return false
+		}
+	}
+	return true
+}
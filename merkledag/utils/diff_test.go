@@ -0,0 +1,180 @@
+package dagutils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	imp "github.com/ipfs/go-ipfs/importer"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+func buildTree(t *testing.T, ds dag.DAGService, files map[string]string) *dag.Node {
+	e := NewDagEditor(ds, emptyDir())
+	for p, data := range files {
+		err := e.InsertNodeAtPath(context.Background(), p, &dag.Node{Data: ft.FilePBData([]byte(data), uint64(len(data)))}, emptyDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e.GetNode()
+}
+
+func checkChanges(t *testing.T, changes []*Change, exp []Change) {
+	if len(changes) != len(exp) {
+		for _, c := range changes {
+			t.Log(c)
+		}
+		t.Fatalf("expected %d changes, got %d", len(exp), len(changes))
+	}
+
+	for i, c := range changes {
+		if c.Type != exp[i].Type || c.Path != exp[i].Path {
+			t.Fatalf("change %d: expected %s %s, got %s %s", i, exp[i].Type, exp[i].Path, c.Type, c.Path)
+		}
+	}
+}
+
+func TestDiffUnixfs(t *testing.T) {
+	ds := mdtest.Mock(t)
+	a := buildTree(t, ds, map[string]string{
+		"same/x":    "x",
+		"changed/y": "y",
+		"gone/z":    "z",
+		"gone/w":    "w",
+	})
+	b := buildTree(t, ds, map[string]string{
+		"same/x":    "x",
+		"changed/y": "y2",
+		"new/v":     "v",
+	})
+
+	changes, err := DiffUnixfs(context.Background(), ds, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkChanges(t, changes, []Change{
+		{Type: Mod, Path: "changed/y"},
+		{Type: Remove, Path: "gone/w"},
+		{Type: Remove, Path: "gone/z"},
+		{Type: Add, Path: "new/v"},
+	})
+
+	if changes[0].Before == changes[0].After || changes[0].After == "" {
+		t.Fatal("modified entry should have both hashes")
+	}
+
+	changes, err = DiffUnixfs(context.Background(), ds, a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatal("expected no changes between identical trees")
+	}
+}
+
+func TestDiffUnixfsEmptyDir(t *testing.T) {
+	ds := mdtest.Mock(t)
+	a := buildTree(t, ds, map[string]string{"x": "x"})
+
+	e := NewDagEditor(ds, a)
+	for _, p := range []string{"empty", "new/empty"} {
+		err := e.InsertNodeAtPath(context.Background(), p, emptyDir(), emptyDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := e.GetNode()
+
+	changes, err := DiffUnixfs(context.Background(), ds, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChanges(t, changes, []Change{
+		{Type: Add, Path: "empty"},
+		{Type: Add, Path: "new/empty"},
+	})
+
+	changes, err = DiffUnixfs(context.Background(), ds, b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChanges(t, changes, []Change{
+		{Type: Remove, Path: "empty"},
+		{Type: Remove, Path: "new/empty"},
+	})
+}
+
+func TestDiffRaw(t *testing.T) {
+	ds := mdtest.Mock(t)
+	a := buildTree(t, ds, map[string]string{
+		"d/x": "x",
+		"d/y": "y",
+	})
+	b := buildTree(t, ds, map[string]string{
+		"d/x": "x2",
+		"e":   "e",
+	})
+
+	changes, err := Diff(context.Background(), ds, a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkChanges(t, changes, []Change{
+		{Type: Mod, Path: "d/x"},
+		{Type: Remove, Path: "d/y"},
+		{Type: Add, Path: "e"},
+	})
+}
+
+func TestDiffRawUnnamedLinks(t *testing.T) {
+	ds := mdtest.Mock(t)
+	data := make([]byte, 1024*1024)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	spl := &chunk.SizeSplitter{Size: 1024}
+
+	a, err := imp.BuildDagFromReader(bytes.NewReader(data), ds, nil, spl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1]++
+	b, err := imp.BuildDagFromReader(bytes.NewReader(data), ds, nil, spl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewDagEditor(ds, emptyDir())
+	if err := e.InsertNodeAtPath(context.Background(), "f", a, emptyDir); err != nil {
+		t.Fatal(err)
+	}
+	ra := e.GetNode()
+	e = NewDagEditor(ds, emptyDir())
+	if err := e.InsertNodeAtPath(context.Background(), "f", b, emptyDir); err != nil {
+		t.Fatal(err)
+	}
+	rb := e.GetNode()
+
+	changes, err := Diff(context.Background(), ds, ra, rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChanges(t, changes, []Change{
+		{Type: Mod, Path: "f"},
+	})
+
+	changes, err = Diff(context.Background(), ds, a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatal("expected no changes between identical files")
+	}
+}
@@ -0,0 +1,216 @@
+// package dagutils provides helpers to edit a DAG below a root node.
+package dagutils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+)
+
+var ErrEmptyPath = errors.New("dagutils: path must name a link")
+
+// Editor applies changes to the DAG below a root node. Nodes are never
+// modified in place: every change adds new copies of the nodes along the
+// changed path to the DAGService, up to and including a new root.
+//
+// Links inside unixfs directories, whether sharded or not, are looked up and
+// changed by their entry names. All other nodes are treated as plain DAG
+// nodes.
+type Editor struct {
+	root  *dag.Node
+	dserv dag.DAGService
+}
+
+// NewDagEditor returns an Editor for the DAG below root
+func NewDagEditor(dserv dag.DAGService, root *dag.Node) *Editor {
+	return &Editor{
+		root:  root,
+		dserv: dserv,
+	}
+}
+
+// GetNode returns the current root node
+func (e *Editor) GetNode() *dag.Node {
+	return e.root
+}
+
+// InsertNodeAtPath links nd under the given slash separated path, replacing
+// any link by the same name. Missing intermediate nodes are made by calling
+// create, or cause dag.ErrNotFound if create is nil.
+func (e *Editor) InsertNodeAtPath(ctx context.Context, p string, nd *dag.Node, create func() *dag.Node) error {
+	elems := splitPath(p)
+	if len(elems) == 0 {
+		return ErrEmptyPath
+	}
+
+	_, err := e.dserv.Add(nd)
+	if err != nil {
+		return err
+	}
+
+	last := len(elems) - 1
+	return e.modify(ctx, elems[:last], create, func(parent *dag.Node) (*dag.Node, error) {
+		return e.setChild(ctx, parent, elems[last], nd)
+	})
+}
+
+// RmLink removes the link at the given path
+func (e *Editor) RmLink(ctx context.Context, p string) error {
+	elems := splitPath(p)
+	if len(elems) == 0 {
+		return ErrEmptyPath
+	}
+
+	last := len(elems) - 1
+	return e.modify(ctx, elems[:last], nil, func(parent *dag.Node) (*dag.Node, error) {
+		return e.removeChild(ctx, parent, elems[last])
+	})
+}
+
+// SetData replaces the data of the node at the given path. An empty path
+// names the root.
+func (e *Editor) SetData(ctx context.Context, p string, data []byte) error {
+	return e.modify(ctx, splitPath(p), nil, func(nd *dag.Node) (*dag.Node, error) {
+		nd.Data = data
+		return nd, nil
+	})
+}
+
+// AppendData appends to the data of the node at the given path. An empty
+// path names the root.
+func (e *Editor) AppendData(ctx context.Context, p string, data []byte) error {
+	return e.modify(ctx, splitPath(p), nil, func(nd *dag.Node) (*dag.Node, error) {
+		nd.Data = append(nd.Data, data...)
+		return nd, nil
+	})
+}
+
+// modify calls f with a copy of the node at the given path, and replaces that
+// node with the result
+func (e *Editor) modify(ctx context.Context, elems []string, create func() *dag.Node, f func(*dag.Node) (*dag.Node, error)) error {
+	nroot, err := e.modifyNode(ctx, e.root, elems, create, f)
+	if err != nil {
+		return err
+	}
+
+	e.root = nroot
+	return nil
+}
+
+func (e *Editor) modifyNode(ctx context.Context, nd *dag.Node, elems []string, create func() *dag.Node, f func(*dag.Node) (*dag.Node, error)) (*dag.Node, error) {
+	var nnd *dag.Node
+	var err error
+	if len(elems) == 0 {
+		nnd, err = f(nd.Copy())
+	} else {
+		var child *dag.Node
+		child, err = e.getChild(ctx, nd, elems[0])
+		if err == dag.ErrNotFound && create != nil {
+			child, err = create(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		child, err = e.modifyNode(ctx, child, elems[1:], create, f)
+		if err != nil {
+			return nil, err
+		}
+
+		nnd, err = e.setChild(ctx, nd, elems[0], child)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = e.dserv.Add(nnd)
+	if err != nil {
+		return nil, err
+	}
+	return nnd, nil
+}
+
+func (e *Editor) getChild(ctx context.Context, nd *dag.Node, name string) (*dag.Node, error) {
+	var lnk *dag.Link
+	var err error
+	if uio.IsDirectory(nd) {
+		var dir *uio.Directory
+		dir, err = uio.NewDirectoryFromNode(e.dserv, nd)
+		if err != nil {
+			return nil, err
+		}
+		lnk, err = dir.Find(ctx, name)
+	} else {
+		lnk, err = nd.GetNodeLink(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return lnk.GetNode(ctx, e.dserv)
+}
+
+// setChild returns a copy of nd, with child linked under name
+func (e *Editor) setChild(ctx context.Context, nd *dag.Node, name string, child *dag.Node) (*dag.Node, error) {
+	nnd := nd.Copy()
+	if uio.IsDirectory(nnd) {
+		dir, err := uio.NewDirectoryFromNode(e.dserv, nnd)
+		if err != nil {
+			return nil, err
+		}
+
+		err = dir.SetChild(ctx, name, child)
+		if err != nil {
+			return nil, err
+		}
+		return dir.GetNode()
+	}
+
+	err := nnd.RemoveNodeLink(name)
+	if err != nil && err != dag.ErrNotFound {
+		return nil, err
+	}
+
+	err = nnd.AddNodeLinkClean(name, child)
+	if err != nil {
+		return nil, err
+	}
+	return nnd, nil
+}
+
+// removeChild returns a copy of nd without the link called name
+func (e *Editor) removeChild(ctx context.Context, nd *dag.Node, name string) (*dag.Node, error) {
+	nnd := nd.Copy()
+	if uio.IsDirectory(nnd) {
+		dir, err := uio.NewDirectoryFromNode(e.dserv, nnd)
+		if err != nil {
+			return nil, err
+		}
+
+		err = dir.RemoveChild(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return dir.GetNode()
+	}
+
+	err := nnd.RemoveNodeLink(name)
+	if err != nil {
+		return nil, err
+	}
+	return nnd, nil
+}
+
+func splitPath(p string) []string {
+	var elems []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			elems = append(elems, s)
+		}
+	}
+	return elems
+}
@@ -0,0 +1,157 @@
+package dagutils
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+)
+
+func emptyDir() *dag.Node {
+	return &dag.Node{Data: ft.FolderPBData()}
+}
+
+func getPath(t *testing.T, ds dag.DAGService, root *dag.Node, elems ...string) *dag.Node {
+	ctx := context.Background()
+	nd := root
+	for _, name := range elems {
+		lnk, err := nd.GetNodeLink(name)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		nd, err = lnk.GetNode(ctx, ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nd
+}
+
+func TestInsertNodeAtPath(t *testing.T) {
+	ds := mdtest.Mock(t)
+	ctx := context.Background()
+	root := emptyDir()
+	rootKey, _ := root.Key()
+
+	e := NewDagEditor(ds, root)
+	child := &dag.Node{Data: []byte("child")}
+	err := e.InsertNodeAtPath(ctx, "a/b/c", child, nil)
+	if err != dag.ErrNotFound {
+		t.Fatal("expected ErrNotFound without create, got: ", err)
+	}
+
+	err = e.InsertNodeAtPath(ctx, "a/b/c", child, emptyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd := getPath(t, ds, e.GetNode(), "a", "b", "c")
+	if string(nd.Data) != "child" {
+		t.Fatal("wrong node at inserted path")
+	}
+
+	k, _ := root.Key()
+	if k != rootKey {
+		t.Fatal("original root was modified")
+	}
+
+	err = e.RmLink(ctx, "a/b/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd = getPath(t, ds, e.GetNode(), "a", "b")
+	if len(nd.Links) != 0 {
+		t.Fatal("link was not removed")
+	}
+
+	err = e.RmLink(ctx, "a/b/c")
+	if err != dag.ErrNotFound {
+		t.Fatal("expected ErrNotFound, got: ", err)
+	}
+}
+
+func TestSetAndAppendData(t *testing.T) {
+	ds := mdtest.Mock(t)
+	ctx := context.Background()
+
+	e := NewDagEditor(ds, &dag.Node{})
+	err := e.InsertNodeAtPath(ctx, "x/y", &dag.Node{Data: []byte("foo")}, func() *dag.Node { return new(dag.Node) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = e.AppendData(ctx, "x/y", []byte("bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd := getPath(t, ds, e.GetNode(), "x", "y")
+	if string(nd.Data) != "foobar" {
+		t.Fatalf("expected data foobar, got %q", nd.Data)
+	}
+
+	err = e.SetData(ctx, "", []byte("root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(e.GetNode().Data) != "root" {
+		t.Fatal("root data was not set")
+	}
+	getPath(t, ds, e.GetNode(), "x", "y")
+}
+
+func TestEditShardedDirectory(t *testing.T) {
+	ds := mdtest.Mock(t)
+	ctx := context.Background()
+
+	old := uio.ShardSplitThreshold
+	uio.ShardSplitThreshold = 4
+	defer func() { uio.ShardSplitThreshold = old }()
+
+	e := NewDagEditor(ds, emptyDir())
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	for _, name := range names {
+		err := e.InsertNodeAtPath(ctx, "dir/"+name, &dag.Node{Data: ft.WrapData([]byte(name))}, emptyDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir, err := uio.NewDirectoryFromNode(ds, getPath(t, ds, e.GetNode(), "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dir.IsSharded() {
+		t.Fatal("expected directory to be sharded")
+	}
+
+	err = e.RmLink(ctx, "dir/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err = uio.NewDirectoryFromNode(ds, getPath(t, ds, e.GetNode(), "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links, err := dir.Links(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != len(names)-1 {
+		t.Fatalf("expected %d entries, got %d", len(names)-1, len(links))
+	}
+
+	_, err = dir.Find(ctx, "c")
+	if err != dag.ErrNotFound {
+		t.Fatal("expected removed entry to be gone, got: ", err)
+	}
+}
@@ -2,19 +2,22 @@ package namesys
 
 import (
 	"net"
+	"strings"
+	"time"
 
 	b58 "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-base58"
 	isd "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-is-domain"
 	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
+	path "github.com/ipfs/go-ipfs/path"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
 // DNSResolver implements a Resolver on DNS domains
 type DNSResolver struct {
-	// TODO: maybe some sort of caching?
-	// cache would need a timeout
+	// lookupTXT is net.LookupTXT, unless replaced by tests
+	lookupTXT func(name string) ([]string, error)
 }
 
 // CanResolve implements Resolver
@@ -23,16 +26,37 @@ func (r *DNSResolver) CanResolve(name string) bool {
 }
 
 // Resolve implements Resolver
-// TXT records for a given domain name should contain a b58
-// encoded multihash.
 func (r *DNSResolver) Resolve(ctx context.Context, name string) (u.Key, error) {
-	log.Info("DNSResolver resolving %v", name)
-	txt, err := net.LookupTXT(name)
+	return resolveKey(ctx, r, name)
+}
+
+// ResolveN implements Resolver. Only domains pointing at other domains are
+// followed.
+func (r *DNSResolver) ResolveN(ctx context.Context, name string, depth int) ([]path.Path, error) {
+	return resolveN(ctx, []resolver{r}, nil, name, depth)
+}
+
+// TXT records for a given domain name should contain a b58 encoded
+// multihash, or an /ipfs/ or /ipns/ path, optionally as "dnslink=<path>".
+// The TTL of the records is not known, so DefaultCacheTTL is used.
+func (r *DNSResolver) resolveOnce(ctx context.Context, name string) (path.Path, time.Duration, error) {
+	log.Infof("DNSResolver resolving %v", name)
+	lookup := r.lookupTXT
+	if lookup == nil {
+		lookup = net.LookupTXT
+	}
+
+	txt, err := lookup(name)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	for _, t := range txt {
+		t = strings.TrimPrefix(t, "dnslink=")
+		if p, ok := parseValue(t); ok {
+			return p, DefaultCacheTTL, nil
+		}
+
 		chk := b58.Decode(t)
 		if len(chk) == 0 {
 			continue
@@ -42,8 +66,8 @@ func (r *DNSResolver) Resolve(ctx context.Context, name string) (u.Key, error) {
 		if err != nil {
 			continue
 		}
-		return u.Key(chk), nil
+		return keyValue(u.Key(chk)), DefaultCacheTTL, nil
 	}
 
-	return "", ErrResolveFailed
+	return "", 0, ErrResolveFailed
 }
@@ -3,15 +3,38 @@ package namesys
 
 import (
 	"errors"
+	"time"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	path "github.com/ipfs/go-ipfs/path"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
 // ErrResolveFailed signals an error when attempting to resolve.
 var ErrResolveFailed = errors.New("could not resolve name.")
 
+// ErrResolveRecursion signals that a name points through more names than
+// allowed.
+var ErrResolveRecursion = errors.New("could not resolve name (recursion limit exceeded).")
+
+// ErrResolveLoop signals that a name points back at itself.
+var ErrResolveLoop = errors.New("could not resolve name (loop detected).")
+
+// ErrNotAKey signals that a name points at a path below a key, which
+// Resolve cannot return.
+var ErrNotAKey = errors.New("name resolves to a path, not a key.")
+
+// DefaultDepthLimit is the number of names Resolve follows before it gives
+// up with ErrResolveRecursion.
+const DefaultDepthLimit = 32
+
+// DefaultCacheTTL is the longest time a resolved name is cached for.
+const DefaultCacheTTL = time.Minute
+
+// DefaultCacheSize is the number of names a name system caches.
+const DefaultCacheSize = 128
+
 // ErrPublishFailed signals an error when attempting to publish.
 var ErrPublishFailed = errors.New("could not publish name.")
 
@@ -30,9 +53,15 @@ type NameSystem interface {
 // Resolver is an object capable of resolving names.
 type Resolver interface {
 
-	// Resolve looks up a name, and returns the value previously published.
+	// Resolve looks up a name, following it through other names, and
+	// returns the key it points at.
 	Resolve(ctx context.Context, name string) (value u.Key, err error)
 
+	// ResolveN looks up a name, following it through at most depth names,
+	// and returns the /ipfs/ or /ipns/ path found at every step. The last
+	// one is an /ipfs/ path unless an error is returned.
+	ResolveN(ctx context.Context, name string, depth int) ([]path.Path, error)
+
 	// CanResolve checks whether this Resolver can resolve a name
 	CanResolve(name string) bool
 }
@@ -40,7 +69,12 @@ type Resolver interface {
 // Publisher is an object capable of publishing particular names.
 type Publisher interface {
 
-	// Publish establishes a name-value mapping.
+	// Publish establishes a name-value mapping. The value is a key, or an
+	// /ipfs/ or /ipns/ path given as a Key.
 	// TODO make this not PrivKey specific.
 	Publish(ctx context.Context, name ci.PrivKey, value u.Key) error
+
+	// PublishWithEOL is Publish with a record that stays valid until eol,
+	// and that resolvers may cache for ttl.
+	PublishWithEOL(ctx context.Context, name ci.PrivKey, value u.Key, eol time.Time, ttl time.Duration) error
 }
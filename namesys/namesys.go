@@ -1,8 +1,15 @@
 package namesys
 
 import (
+	"strings"
+	"time"
+
+	lru "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/hashicorp/golang-lru"
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	path "github.com/ipfs/go-ipfs/path"
 	routing "github.com/ipfs/go-ipfs/routing"
 	u "github.com/ipfs/go-ipfs/util"
 )
@@ -16,31 +23,37 @@ import (
 //
 // It can only publish to: (a) ipfs routing naming.
 //
+// Names may point at other names, of any of the three kinds, and are
+// followed until they reach an /ipfs/ path. Every step is cached for the
+// TTL of the record it came from.
 type ipns struct {
-	resolvers []Resolver
+	resolvers []resolver
 	publisher Publisher
+	cache     *resolveCache
 }
 
-// NewNameSystem will construct the IPFS naming system based on Routing
-func NewNameSystem(r routing.IpfsRouting) NameSystem {
+// NewNameSystem will construct the IPFS naming system based on Routing. The
+// records it publishes are kept in dstore.
+func NewNameSystem(r routing.IpfsRouting, dstore ds.Datastore) NameSystem {
 	return &ipns{
-		resolvers: []Resolver{
+		resolvers: []resolver{
 			new(DNSResolver),
 			new(ProquintResolver),
-			NewRoutingResolver(r),
+			&routingResolver{routing: r},
 		},
-		publisher: NewRoutingPublisher(r),
+		publisher: NewRoutingPublisher(r, dstore),
+		cache:     newResolveCache(DefaultCacheSize),
 	}
 }
 
 // Resolve implements Resolver
 func (ns *ipns) Resolve(ctx context.Context, name string) (u.Key, error) {
-	for _, r := range ns.resolvers {
-		if r.CanResolve(name) {
-			return r.Resolve(ctx, name)
-		}
-	}
-	return "", ErrResolveFailed
+	return resolveKey(ctx, ns, name)
+}
+
+// ResolveN implements Resolver
+func (ns *ipns) ResolveN(ctx context.Context, name string, depth int) ([]path.Path, error) {
+	return resolveN(ctx, ns.resolvers, ns.cache, name, depth)
 }
 
 // CanResolve implements Resolver
@@ -55,5 +68,172 @@ func (ns *ipns) CanResolve(name string) bool {
 
 // Publish implements Publisher
 func (ns *ipns) Publish(ctx context.Context, name ci.PrivKey, value u.Key) error {
-	return ns.publisher.Publish(ctx, name, value)
+	eol := time.Now().Add(DefaultRecordLifetime)
+	return ns.PublishWithEOL(ctx, name, value, eol, DefaultRecordTTL)
+}
+
+// PublishWithEOL implements Publisher
+func (ns *ipns) PublishWithEOL(ctx context.Context, name ci.PrivKey, value u.Key, eol time.Time, ttl time.Duration) error {
+	err := ns.publisher.PublishWithEOL(ctx, name, value, eol, ttl)
+	if err != nil {
+		return err
+	}
+
+	// our own name must not resolve to what it pointed at before
+	h, err := name.GetPublic().Hash()
+	if err != nil {
+		return err
+	}
+	ns.cache.remove(u.Key(h).Pretty())
+	return nil
+}
+
+// resolver takes a single step of a name resolution: it returns the
+// /ipfs/ or /ipns/ path a name points at, and how long that may be cached.
+type resolver interface {
+	CanResolve(name string) bool
+	resolveOnce(ctx context.Context, name string) (path.Path, time.Duration, error)
+}
+
+// resolveN follows name through the resolvers rs until it reaches an /ipfs/
+// path, taking at most depth steps, and returns the value found at every
+// step. The steps found before an error are returned along with it.
+func resolveN(ctx context.Context, rs []resolver, c *resolveCache, name string, depth int) ([]path.Path, error) {
+	var steps []path.Path
+	var rest []string
+	seen := make(map[string]bool)
+	for {
+		if seen[name] {
+			return steps, ErrResolveLoop
+		}
+		seen[name] = true
+
+		if len(steps) >= depth {
+			return steps, ErrResolveRecursion
+		}
+
+		p, err := resolveOnce(ctx, rs, c, name)
+		if err != nil {
+			return steps, err
+		}
+
+		// keep the remainder of earlier values below the new one
+		seg := append(p.Segments(), rest...)
+		steps = append(steps, path.FromSegments(append([]string{""}, seg...)...))
+		if seg[0] == "ipfs" {
+			return steps, nil
+		}
+		name, rest = seg[1], seg[2:]
+	}
+}
+
+func resolveOnce(ctx context.Context, rs []resolver, c *resolveCache, name string) (path.Path, error) {
+	if p, ok := c.get(name); ok {
+		return p, nil
+	}
+
+	for _, r := range rs {
+		if !r.CanResolve(name) {
+			continue
+		}
+
+		p, ttl, err := r.resolveOnce(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		c.put(name, p, ttl)
+		return p, nil
+	}
+	return "", ErrResolveFailed
+}
+
+// resolveKey resolves name through r all the way, and returns the key it
+// points at.
+func resolveKey(ctx context.Context, r Resolver, name string) (u.Key, error) {
+	steps, err := r.ResolveN(ctx, name, DefaultDepthLimit)
+	if err != nil {
+		return "", err
+	}
+
+	p := steps[len(steps)-1]
+	seg := p.Segments()
+	if len(seg) != 2 {
+		return "", ErrNotAKey
+	}
+	return u.B58KeyDecode(seg[1]), nil
+}
+
+// parseValue checks that s is an /ipfs/ or /ipns/ path as names point at,
+// and returns it cleaned.
+func parseValue(s string) (path.Path, bool) {
+	if !strings.HasPrefix(s, "/ipfs/") && !strings.HasPrefix(s, "/ipns/") {
+		return "", false
+	}
+
+	seg := path.Path(s).Segments()
+	if len(seg) < 2 || seg[1] == "" {
+		return "", false
+	}
+	if seg[0] == "ipfs" {
+		if _, err := mh.FromB58String(seg[1]); err != nil {
+			return "", false
+		}
+	}
+	return path.FromSegments(append([]string{""}, seg...)...), true
+}
+
+// keyValue returns the /ipfs/ path of key k
+func keyValue(k u.Key) path.Path {
+	return path.FromSegments("", "ipfs", k.B58String())
+}
+
+// resolveCache holds the values names were resolved to, each until the TTL
+// of its record runs out. A nil *resolveCache caches nothing.
+type resolveCache struct {
+	cache *lru.Cache
+}
+
+type cacheEntry struct {
+	val path.Path
+	eol time.Time
+}
+
+func newResolveCache(size int) *resolveCache {
+	c, err := lru.New(size)
+	if err != nil {
+		panic(err) // only fails for a bad size
+	}
+	return &resolveCache{cache: c}
+}
+
+func (c *resolveCache) get(name string) (path.Path, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	v, ok := c.cache.Get(name)
+	if !ok {
+		return "", false
+	}
+
+	e := v.(cacheEntry)
+	if time.Now().After(e.eol) {
+		c.cache.Remove(name)
+		return "", false
+	}
+	return e.val, true
+}
+
+func (c *resolveCache) put(name string, val path.Path, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+	c.cache.Add(name, cacheEntry{val: val, eol: time.Now().Add(ttl)})
+}
+
+func (c *resolveCache) remove(name string) {
+	if c == nil {
+		return
+	}
+	c.cache.Remove(name)
 }
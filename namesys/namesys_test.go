@@ -0,0 +1,374 @@
+package namesys
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	pb "github.com/ipfs/go-ipfs/namesys/internal/pb"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	path "github.com/ipfs/go-ipfs/path"
+	routing "github.com/ipfs/go-ipfs/routing"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	record "github.com/ipfs/go-ipfs/routing/record"
+	u "github.com/ipfs/go-ipfs/util"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
+)
+
+type mockDNS map[string][]string
+
+func (m mockDNS) lookupTXT(name string) ([]string, error) {
+	txt, ok := m[name]
+	if !ok {
+		return nil, ErrResolveFailed
+	}
+	return txt, nil
+}
+
+func randName(t *testing.T) (ci.PrivKey, string) {
+	privk, pubk, err := testutil.RandTestKeyPair(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := pubk.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privk, u.Key(h).Pretty()
+}
+
+func checkSteps(t *testing.T, steps []path.Path, exp ...string) {
+	if len(steps) != len(exp) {
+		t.Fatalf("expected %v, got %v", exp, steps)
+	}
+	for i := range exp {
+		if steps[i].String() != exp[i] {
+			t.Fatalf("expected %v, got %v", exp, steps)
+		}
+	}
+}
+
+func TestResolveChain(t *testing.T) {
+	ctx := context.Background()
+	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	dns := mockDNS{}
+	ns := &ipns{
+		resolvers: []resolver{
+			&DNSResolver{lookupTXT: dns.lookupTXT},
+			&routingResolver{routing: d},
+		},
+		publisher: NewRoutingPublisher(d, ds.NewMapDatastore()),
+		cache:     newResolveCache(DefaultCacheSize),
+	}
+
+	ka, a := randName(t)
+	kb, b := randName(t)
+	h := u.Key(u.Hash([]byte("Hello")))
+
+	dns["example.com"] = []string{"v=spf1 -all", "dnslink=/ipns/" + a + "/sub"}
+	dns["www.example.com"] = []string{"/ipns/example.com"}
+	if err := ns.Publish(ctx, ka, u.Key("/ipns/"+b)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.Publish(ctx, kb, h); err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := ns.ResolveN(ctx, "www.example.com", DefaultDepthLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSteps(t, steps,
+		"/ipns/example.com",
+		"/ipns/"+a+"/sub",
+		"/ipns/"+b+"/sub",
+		"/ipfs/"+h.B58String()+"/sub")
+
+	// the chain ends below a key
+	if _, err := ns.Resolve(ctx, "www.example.com"); err != ErrNotAKey {
+		t.Fatalf("expected ErrNotAKey, got %v", err)
+	}
+	k, err := ns.Resolve(ctx, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != h {
+		t.Fatal("resolved to the wrong key")
+	}
+
+	steps, err = ns.ResolveN(ctx, "www.example.com", 2)
+	if err != ErrResolveRecursion {
+		t.Fatalf("expected ErrResolveRecursion, got %v", err)
+	}
+	checkSteps(t, steps, "/ipns/example.com", "/ipns/"+a+"/sub")
+
+	// a loop is found before the depth runs out
+	if err := ns.Publish(ctx, kb, u.Key("/ipns/"+a)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.ResolveN(ctx, a, DefaultDepthLimit); err != ErrResolveLoop {
+		t.Fatalf("expected ErrResolveLoop, got %v", err)
+	}
+}
+
+func TestResolveCache(t *testing.T) {
+	ctx := context.Background()
+	dns := mockDNS{}
+	ns := &ipns{
+		resolvers: []resolver{&DNSResolver{lookupTXT: dns.lookupTXT}},
+		cache:     newResolveCache(DefaultCacheSize),
+	}
+
+	h1 := u.Key(u.Hash([]byte("one")))
+	h2 := u.Key(u.Hash([]byte("two")))
+
+	dns["example.com"] = []string{h1.B58String()}
+	k, err := ns.Resolve(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != h1 {
+		t.Fatal("resolved to the wrong key")
+	}
+
+	// still cached
+	dns["example.com"] = []string{h2.B58String()}
+	k, err = ns.Resolve(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != h1 {
+		t.Fatal("expected the cached key")
+	}
+
+	// expired
+	ns.cache.put("example.com", keyValue(h1), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	k, err = ns.Resolve(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != h2 {
+		t.Fatal("expected the new key")
+	}
+}
+
+func getEntry(t *testing.T, r routing.IpfsRouting, name string) *pb.IpnsEntry {
+	h, err := mh.FromB58String(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := r.GetValue(context.Background(), u.Key("/ipns/"+string(h)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(val, entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestPublishSequence(t *testing.T) {
+	ctx := context.Background()
+	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	pub := NewRoutingPublisher(d, ds.NewMapDatastore())
+	k, name := randName(t)
+
+	h1 := u.Key(u.Hash([]byte("one")))
+	h2 := u.Key(u.Hash([]byte("two")))
+	if err := pub.Publish(ctx, k, h1); err != nil {
+		t.Fatal(err)
+	}
+	first := getEntry(t, d, name)
+	if err := pub.Publish(ctx, k, h2); err != nil {
+		t.Fatal(err)
+	}
+	second := getEntry(t, d, name)
+
+	if first.GetSequence() != 1 || second.GetSequence() != 2 {
+		t.Fatalf("bad sequence numbers: %d, %d", first.GetSequence(), second.GetSequence())
+	}
+	if time.Duration(second.GetTtl()) != DefaultRecordTTL {
+		t.Fatalf("bad ttl: %d", second.GetTtl())
+	}
+
+	// the newer one wins, whatever order they are found in
+	vals := make([][]byte, 2)
+	for i, e := range []*pb.IpnsEntry{second, first} {
+		b, err := proto.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals[i] = b
+	}
+	for _, order := range [][]int{{0, 1}, {1, 0}} {
+		i, err := SelectIpnsRecord("", [][]byte{vals[order[0]], vals[order[1]]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order[i] != 0 {
+			t.Fatal("selected the older record")
+		}
+	}
+
+	// the sequence number is signed
+	second.Sequence = proto.Uint64(5)
+	if ok, _ := k.GetPublic().Verify(ipnsEntryDataForSig(second), second.GetSignature()); ok {
+		t.Fatal("signature still verifies with a changed sequence number")
+	}
+}
+
+func TestSelectSkipsForgedEntries(t *testing.T) {
+	owner, name := randName(t)
+	forger, _ := randName(t)
+	hash, err := mh.FromB58String(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := u.Key("/ipns/" + string(hash))
+
+	real, err := createRoutingEntryData(owner, u.Key(u.Hash([]byte("real"))), 1, time.Now().Add(time.Hour), DefaultRecordTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged, err := createRoutingEntryData(forger, u.Key(u.Hash([]byte("forged"))), math.MaxUint64, time.Now().Add(time.Hour*24*365*100), DefaultRecordTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := record.Validator{"ipns": IpnsRecordValidator}
+	getKey := func(p peer.ID) (ci.PubKey, error) {
+		if p != peer.ID(hash) {
+			t.Fatalf("asked for the key of %s", p)
+		}
+		return owner.GetPublic(), nil
+	}
+
+	if err := v.VerifyValueSig(k, real, getKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyValueSig(k, forged, getKey); err == nil {
+		t.Fatal("forged entry verifies")
+	}
+	for _, vals := range [][][]byte{{forged, real}, {real, forged}} {
+		i, err := v.Select(k, vals, getKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(vals[i]) != string(real) {
+			t.Fatal("selected the forged entry")
+		}
+	}
+	if _, err := v.Select(k, [][]byte{forged}, getKey); err == nil {
+		t.Fatal("selected the forged entry")
+	}
+}
+
+func TestEntryWithoutSequence(t *testing.T) {
+	k, _ := randName(t)
+	h := u.Key(u.Hash([]byte("old")))
+
+	// as published before entries had sequence numbers
+	entry := new(pb.IpnsEntry)
+	entry.Value = []byte(h)
+	typ := pb.IpnsEntry_EOL
+	entry.ValidityType = &typ
+	entry.Validity = []byte(u.FormatRFC3339(time.Now().Add(time.Hour)))
+	sig, err := k.Sign(ipnsEntryDataForSig(entry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Signature = sig
+
+	if ok, err := k.GetPublic().Verify(ipnsEntryDataForSig(entry), entry.GetSignature()); err != nil || !ok {
+		t.Fatal("old entry does not verify")
+	}
+	if ttl := entryTTL(entry); ttl != DefaultCacheTTL {
+		t.Fatalf("expected the default ttl, got %s", ttl)
+	}
+
+	entry.Ttl = proto.Uint64(uint64(time.Hour * 2))
+	if ttl := entryTTL(entry); ttl > time.Hour {
+		t.Fatalf("ttl %s goes past the eol", ttl)
+	}
+}
+
+func TestRepublish(t *testing.T) {
+	ctx := context.Background()
+	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	dstore := ds.NewMapDatastore()
+	ns := NewNameSystem(d, dstore)
+	k, name := randName(t)
+	other, _ := randName(t)
+
+	h := u.Key(u.Hash([]byte("Hello")))
+	eol := time.Now().Add(time.Hour)
+	if err := ns.PublishWithEOL(ctx, k, h, eol, time.Second*30); err != nil {
+		t.Fatal(err)
+	}
+	first := getEntry(t, d, name)
+	if time.Duration(first.GetTtl()) != time.Second*30 {
+		t.Fatalf("bad ttl: %d", first.GetTtl())
+	}
+
+	// names never published are left alone
+	rp := NewRepublisher(dstore, ns, k, other)
+	rp.RecordLifetime = time.Hour * 48
+	if err := rp.Republish(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	second := getEntry(t, d, name)
+	if u.Key(second.GetValue()) != h {
+		t.Fatal("republished the wrong value")
+	}
+	if second.GetSequence() != first.GetSequence()+1 {
+		t.Fatalf("bad sequence numbers: %d, %d", first.GetSequence(), second.GetSequence())
+	}
+	if second.GetTtl() != first.GetTtl() {
+		t.Fatal("the ttl was not kept")
+	}
+	neweol, err := u.ParseRFC3339(string(second.GetValidity()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !neweol.After(time.Now().Add(time.Hour * 47)) {
+		t.Fatalf("eol was not extended: %s", neweol)
+	}
+}
+
+func TestRepublishLostRecord(t *testing.T) {
+	ctx := context.Background()
+	dstore := ds.NewMapDatastore()
+	k, name := randName(t)
+
+	h := u.Key(u.Hash([]byte("Hello")))
+	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	if err := NewNameSystem(d, dstore).Publish(ctx, k, h); err != nil {
+		t.Fatal(err)
+	}
+	first := getEntry(t, d, name)
+
+	// as after the record expired while the node was down
+	d = mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	if err := NewRepublisher(dstore, NewNameSystem(d, dstore), k).Republish(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	second := getEntry(t, d, name)
+	if u.Key(second.GetValue()) != h {
+		t.Fatal("republished the wrong value")
+	}
+	if second.GetSequence() != first.GetSequence()+1 {
+		t.Fatalf("bad sequence numbers: %d, %d", first.GetSequence(), second.GetSequence())
+	}
+}
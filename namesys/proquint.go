@@ -2,9 +2,11 @@ package namesys
 
 import (
 	"errors"
+	"time"
 
 	proquint "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/bren2010/proquint"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	path "github.com/ipfs/go-ipfs/path"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -18,9 +20,20 @@ func (r *ProquintResolver) CanResolve(name string) bool {
 
 // Resolve implements Resolver. Decodes the proquint string.
 func (r *ProquintResolver) Resolve(ctx context.Context, name string) (u.Key, error) {
+	return resolveKey(ctx, r, name)
+}
+
+// ResolveN implements Resolver. A proquint is a single step.
+func (r *ProquintResolver) ResolveN(ctx context.Context, name string, depth int) ([]path.Path, error) {
+	return resolveN(ctx, []resolver{r}, nil, name, depth)
+}
+
+// resolveOnce decodes the proquint string. The result never changes, so it
+// is cached for as long as any other.
+func (r *ProquintResolver) resolveOnce(ctx context.Context, name string) (path.Path, time.Duration, error) {
 	ok := r.CanResolve(name)
 	if !ok {
-		return "", errors.New("not a valid proquint string")
+		return "", 0, errors.New("not a valid proquint string")
 	}
-	return u.Key(proquint.Decode(name)), nil
+	return keyValue(u.Key(proquint.Decode(name))), DefaultCacheTTL, nil
 }
@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	dag "github.com/ipfs/go-ipfs/merkledag"
 	pb "github.com/ipfs/go-ipfs/namesys/internal/pb"
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	pin "github.com/ipfs/go-ipfs/pin"
 	routing "github.com/ipfs/go-ipfs/routing"
 	record "github.com/ipfs/go-ipfs/routing/record"
@@ -28,32 +31,45 @@ var ErrExpiredRecord = errors.New("expired record")
 // unknown validity type.
 var ErrUnrecognizedValidity = errors.New("unrecognized validity type")
 
+// DefaultRecordLifetime is how long a published record stays valid.
+const DefaultRecordLifetime = time.Hour * 24
+
+// DefaultRecordTTL is how long resolvers may cache a published record.
+const DefaultRecordTTL = DefaultCacheTTL
+
 // ipnsPublisher is capable of publishing and resolving names to the IPFS
-// routing system.
+// routing system. It keeps the record it last published for every name in
+// its datastore, see getPublished.
 type ipnsPublisher struct {
 	routing routing.IpfsRouting
+	ds      ds.Datastore
 }
 
 // NewRoutingPublisher constructs a publisher for the IPFS Routing name system.
-func NewRoutingPublisher(route routing.IpfsRouting) Publisher {
-	return &ipnsPublisher{routing: route}
+// The records it publishes are kept in dstore.
+func NewRoutingPublisher(route routing.IpfsRouting, dstore ds.Datastore) Publisher {
+	return &ipnsPublisher{routing: route, ds: dstore}
 }
 
 // Publish implements Publisher. Accepts a keypair and a value,
 // and publishes it out to the routing system
 func (p *ipnsPublisher) Publish(ctx context.Context, k ci.PrivKey, value u.Key) error {
+	eol := time.Now().Add(DefaultRecordLifetime)
+	return p.PublishWithEOL(ctx, k, value, eol, DefaultRecordTTL)
+}
+
+// PublishWithEOL implements Publisher
+func (p *ipnsPublisher) PublishWithEOL(ctx context.Context, k ci.PrivKey, value u.Key, eol time.Time, ttl time.Duration) error {
 	log.Debugf("namesys: Publish %s", value)
 
-	// validate `value` is a ref (multihash)
-	_, err := mh.FromB58String(value.Pretty())
-	if err != nil {
-		return fmt.Errorf("publish value must be str multihash. %v", err)
+	// validate `value` is a ref (multihash), or a path to one or to a name
+	if _, ok := parseValue(string(value)); !ok {
+		_, err := mh.FromB58String(value.Pretty())
+		if err != nil {
+			return fmt.Errorf("publish value must be str multihash or path. %v", err)
+		}
 	}
 
-	data, err := createRoutingEntryData(k, value)
-	if err != nil {
-		return err
-	}
 	pubkey := k.GetPublic()
 	pkbytes, err := pubkey.Bytes()
 	if err != nil {
@@ -62,6 +78,22 @@ func (p *ipnsPublisher) Publish(ctx context.Context, k ci.PrivKey, value u.Key)
 
 	nameb := u.Hash(pkbytes)
 	namekey := u.Key("/pk/" + string(nameb))
+	ipnskey := u.Key("/ipns/" + string(nameb))
+
+	// the new record must win over the one published before
+	seq := p.getPreviousSeqNo(ctx, nameb, ipnskey) + 1
+
+	data, err := createRoutingEntryData(k, value, seq, eol, ttl)
+	if err != nil {
+		return err
+	}
+
+	// keep it first, so that it is published again even if putting it
+	// fails now
+	err = p.ds.Put(publishedKey(nameb), data)
+	if err != nil {
+		return err
+	}
 
 	log.Debugf("Storing pubkey at: %s", namekey)
 	// Store associated public key
@@ -71,8 +103,6 @@ func (p *ipnsPublisher) Publish(ctx context.Context, k ci.PrivKey, value u.Key)
 		return err
 	}
 
-	ipnskey := u.Key("/ipns/" + string(nameb))
-
 	log.Debugf("Storing ipns entry at: %s", ipnskey)
 	// Store ipns entry at "/ipns/"+b58(h(pubkey))
 	timectx, _ = context.WithDeadline(ctx, time.Now().Add(time.Second*10))
@@ -84,13 +114,68 @@ func (p *ipnsPublisher) Publish(ctx context.Context, k ci.PrivKey, value u.Key)
 	return nil
 }
 
-func createRoutingEntryData(pk ci.PrivKey, val u.Key) ([]byte, error) {
+// getPreviousSeqNo returns the sequence number of the record last published
+// for the name nameb, or 0 if there is none. Names that were not published
+// from here are looked up at ipnskey.
+func (p *ipnsPublisher) getPreviousSeqNo(ctx context.Context, nameb []byte, ipnskey u.Key) uint64 {
+	entry, err := getPublished(p.ds, nameb)
+	if err == nil {
+		return entry.GetSequence()
+	}
+	if err != ds.ErrNotFound {
+		log.Debugf("reading the ipns entry last published at %s: %s", ipnskey, err)
+	}
+
+	timectx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	val, err := p.routing.GetValue(timectx, ipnskey)
+	if err != nil {
+		log.Debugf("no previous ipns entry at %s: %s", ipnskey, err)
+		return 0
+	}
+
+	entry = new(pb.IpnsEntry)
+	if err := proto.Unmarshal(val, entry); err != nil {
+		return 0
+	}
+	return entry.GetSequence()
+}
+
+// publishedKey is the key of the record last published for the name nameb
+// in the datastore of a publisher
+func publishedKey(nameb []byte) ds.Key {
+	return ds.NewKey("/local/ipns/" + u.Key(nameb).B58String())
+}
+
+// getPublished returns the record last published for the name nameb with
+// the datastore dstore, or ds.ErrNotFound.
+func getPublished(dstore ds.Datastore, nameb []byte) (*pb.IpnsEntry, error) {
+	v, err := dstore.Get(publishedKey(nameb))
+	if err != nil {
+		return nil, err
+	}
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, u.ErrCast()
+	}
+
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func createRoutingEntryData(pk ci.PrivKey, val u.Key, seq uint64, eol time.Time, ttl time.Duration) ([]byte, error) {
 	entry := new(pb.IpnsEntry)
 
 	entry.Value = []byte(val)
 	typ := pb.IpnsEntry_EOL
 	entry.ValidityType = &typ
-	entry.Validity = []byte(u.FormatRFC3339(time.Now().Add(time.Hour * 24)))
+	entry.Validity = []byte(u.FormatRFC3339(eol))
+	entry.Sequence = proto.Uint64(seq)
+	entry.Ttl = proto.Uint64(uint64(ttl.Nanoseconds()))
 
 	sig, err := pk.Sign(ipnsEntryDataForSig(entry))
 	if err != nil {
@@ -100,18 +185,75 @@ func createRoutingEntryData(pk ci.PrivKey, val u.Key) ([]byte, error) {
 	return proto.Marshal(entry)
 }
 
+// ipnsEntryDataForSig returns the data an entry is signed over. The
+// sequence number and the TTL are only in it when set, so that entries
+// from before they existed still verify.
 func ipnsEntryDataForSig(e *pb.IpnsEntry) []byte {
-	return bytes.Join([][]byte{
+	data := [][]byte{
 		e.Value,
 		e.Validity,
 		[]byte(fmt.Sprint(e.GetValidityType())),
-	},
-		[]byte{})
+	}
+	if e.Sequence != nil || e.Ttl != nil {
+		data = append(data,
+			[]byte(fmt.Sprint(e.GetSequence())),
+			[]byte(fmt.Sprint(e.GetTtl())))
+	}
+	return bytes.Join(data, []byte{})
 }
 
 var IpnsRecordValidator = &record.ValidChecker{
-	Func: ValidateIpnsRecord,
-	Sign: true,
+	Func:        ValidateIpnsRecord,
+	Sign:        true,
+	Selector:    record.SelectorFunc(SelectIpnsRecord),
+	SignedValue: ipnsSignedValue,
+}
+
+// ipnsSignedValue implements record.SignedValueFunc. Entries are signed
+// with the key whose hash is the name.
+func ipnsSignedValue(k u.Key, val []byte) (peer.ID, []byte, []byte, error) {
+	name := strings.TrimPrefix(string(k), "/ipns/")
+	if len(name) == len(k) {
+		return "", nil, nil, fmt.Errorf("not an ipns key: %s", k)
+	}
+
+	entry := new(pb.IpnsEntry)
+	if err := proto.Unmarshal(val, entry); err != nil {
+		return "", nil, nil, err
+	}
+	return peer.ID(name), ipnsEntryDataForSig(entry), entry.GetSignature(), nil
+}
+
+// SelectIpnsRecord implements SelectorFunc. It picks the valid entry with
+// the highest sequence number, and of those the one valid the longest.
+// Signatures are not checked here: record.Validator.Select leaves out the
+// entries not signed by the owner of the name beforehand.
+func SelectIpnsRecord(k u.Key, vals [][]byte) (int, error) {
+	best := -1
+	var bestSeq uint64
+	var bestEOL time.Time
+	for i, val := range vals {
+		if ValidateIpnsRecord(k, val) != nil {
+			continue
+		}
+
+		entry := new(pb.IpnsEntry)
+		if err := proto.Unmarshal(val, entry); err != nil {
+			continue
+		}
+
+		// ValidateIpnsRecord checked the EOL parses
+		eol, _ := u.ParseRFC3339(string(entry.GetValidity()))
+		seq := entry.GetSequence()
+		if best < 0 || seq > bestSeq || (seq == bestSeq && eol.After(bestEOL)) {
+			best, bestSeq, bestEOL = i, seq, eol
+		}
+	}
+
+	if best < 0 {
+		return 0, errors.New("no valid ipns records to select from")
+	}
+	return best, nil
 }
 
 // ValidateIpnsRecord implements ValidatorFunc and verifies that the
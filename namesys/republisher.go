@@ -0,0 +1,95 @@
+package namesys
+
+import (
+	"time"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// DefaultRepublishPeriod is how often a Republisher publishes its names.
+const DefaultRepublishPeriod = time.Hour * 12
+
+// Republisher periodically publishes the names of a set of keys again with
+// a fresh EOL, so that they do not expire while the node is up.
+type Republisher struct {
+	ds   ds.Datastore
+	ns   Publisher
+	keys []ci.PrivKey
+
+	// Interval is how often the names are published again
+	Interval time.Duration
+
+	// RecordLifetime is how long the new records stay valid
+	RecordLifetime time.Duration
+}
+
+// NewRepublisher returns a Republisher for the names of keys. It publishes
+// the values last published with the datastore dstore through ns, which
+// should keep its records in dstore too.
+func NewRepublisher(dstore ds.Datastore, ns Publisher, keys ...ci.PrivKey) *Republisher {
+	return &Republisher{
+		ds:             dstore,
+		ns:             ns,
+		keys:           keys,
+		Interval:       DefaultRepublishPeriod,
+		RecordLifetime: DefaultRecordLifetime,
+	}
+}
+
+// Run republishes the names every Interval until ctx is done.
+func (rp *Republisher) Run(ctx context.Context) {
+	// dont republish immediately.
+	// may have just started the daemon and shutting it down immediately.
+	after := time.After(time.Minute)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-after:
+			if err := rp.Republish(ctx); err != nil {
+				log.Debugf("republishing names failed: %s", err)
+			}
+			after = time.After(rp.Interval)
+		}
+	}
+}
+
+// Republish publishes the current value of every name once more, however
+// long ago it was last published. Names that have never been published
+// with the datastore of rp are skipped.
+func (rp *Republisher) Republish(ctx context.Context) error {
+	var lastErr error
+	for _, k := range rp.keys {
+		if err := rp.republish(ctx, k); err != nil {
+			log.Debugf("republishing name failed: %s", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (rp *Republisher) republish(ctx context.Context, k ci.PrivKey) error {
+	pkbytes, err := k.GetPublic().Bytes()
+	if err != nil {
+		return err
+	}
+
+	entry, err := getPublished(rp.ds, u.Hash(pkbytes))
+	if err == ds.ErrNotFound {
+		// nothing to publish again
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ttl := DefaultRecordTTL
+	if entry.Ttl != nil {
+		ttl = time.Duration(entry.GetTtl())
+	}
+	eol := time.Now().Add(rp.RecordLifetime)
+	return rp.ns.PublishWithEOL(ctx, k, u.Key(entry.GetValue()), eol, ttl)
+}
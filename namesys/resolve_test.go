@@ -3,6 +3,7 @@ package namesys
 import (
 	"testing"
 
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
 	u "github.com/ipfs/go-ipfs/util"
@@ -13,7 +14,7 @@ func TestRoutingResolve(t *testing.T) {
 	d := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
 
 	resolver := NewRoutingResolver(d)
-	publisher := NewRoutingPublisher(d)
+	publisher := NewRoutingPublisher(d, ds.NewMapDatastore())
 
 	privk, pubk, err := testutil.RandTestKeyPair(512)
 	if err != nil {
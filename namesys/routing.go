@@ -2,11 +2,13 @@ package namesys
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
 	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	pb "github.com/ipfs/go-ipfs/namesys/internal/pb"
+	path "github.com/ipfs/go-ipfs/path"
 	routing "github.com/ipfs/go-ipfs/routing"
 	u "github.com/ipfs/go-ipfs/util"
 )
@@ -37,11 +39,23 @@ func (r *routingResolver) CanResolve(name string) bool {
 // Resolve implements Resolver. Uses the IPFS routing system to resolve SFS-like
 // names.
 func (r *routingResolver) Resolve(ctx context.Context, name string) (u.Key, error) {
+	return resolveKey(ctx, r, name)
+}
+
+// ResolveN implements Resolver. Only names pointing at other SFS-like names
+// are followed.
+func (r *routingResolver) ResolveN(ctx context.Context, name string, depth int) ([]path.Path, error) {
+	return resolveN(ctx, []resolver{r}, nil, name, depth)
+}
+
+// resolveOnce looks up the record of name in the routing system. It may be
+// cached for its TTL.
+func (r *routingResolver) resolveOnce(ctx context.Context, name string) (path.Path, time.Duration, error) {
 	log.Debugf("RoutingResolve: '%s'", name)
 	hash, err := mh.FromB58String(name)
 	if err != nil {
 		log.Warning("RoutingResolve: bad input hash: [%s]\n", name)
-		return "", err
+		return "", 0, err
 	}
 	// name should be a multihash. if it isn't, error out here.
 
@@ -53,19 +67,19 @@ func (r *routingResolver) Resolve(ctx context.Context, name string) (u.Key, erro
 	val, err := r.routing.GetValue(ctx, ipnsKey)
 	if err != nil {
 		log.Warning("RoutingResolve get failed.")
-		return "", err
+		return "", 0, err
 	}
 
 	entry := new(pb.IpnsEntry)
 	err = proto.Unmarshal(val, entry)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	// name should be a public key retrievable from ipfs
 	pubkey, err := routing.GetPublicKey(r.routing, ctx, hash)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
 	hsh, _ := pubkey.Hash()
@@ -73,9 +87,46 @@ func (r *routingResolver) Resolve(ctx context.Context, name string) (u.Key, erro
 
 	// check sig with pk
 	if ok, err := pubkey.Verify(ipnsEntryDataForSig(entry), entry.GetSignature()); err != nil || !ok {
-		return "", fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", pubkey)
+		return "", 0, fmt.Errorf("Invalid value. Not signed by PrivateKey corresponding to %v", pubkey)
 	}
 
 	// ok sig checks out. this is a valid name.
-	return u.Key(entry.GetValue()), nil
+	p, err := entryValue(entry)
+	if err != nil {
+		return "", 0, err
+	}
+	return p, entryTTL(entry), nil
+}
+
+// entryValue returns the path an entry points at. Its value is either a
+// raw key, or an /ipfs/ or /ipns/ path.
+func entryValue(entry *pb.IpnsEntry) (path.Path, error) {
+	val := entry.GetValue()
+	if p, ok := parseValue(string(val)); ok {
+		return p, nil
+	}
+
+	if _, err := mh.Cast(val); err != nil {
+		return "", fmt.Errorf("invalid value in ipns entry: %s", err)
+	}
+	return keyValue(u.Key(val)), nil
+}
+
+// entryTTL returns how long the value of entry may be cached: its TTL, or
+// DefaultCacheTTL for entries without one, but never past its EOL.
+func entryTTL(entry *pb.IpnsEntry) time.Duration {
+	ttl := DefaultCacheTTL
+	if entry.Ttl != nil {
+		ttl = time.Duration(entry.GetTtl())
+	}
+	if entry.GetValidityType() == pb.IpnsEntry_EOL {
+		eol, err := u.ParseRFC3339(string(entry.GetValidity()))
+		if err != nil {
+			return 0
+		}
+		if d := eol.Sub(time.Now()); d < ttl {
+			ttl = d
+		}
+	}
+	return ttl
 }
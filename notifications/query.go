@@ -2,6 +2,7 @@ package notifications
 
 import (
 	"encoding/json"
+	"time"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
@@ -18,13 +19,20 @@ const (
 	QueryError
 	Provider
 	Value
+	DialingPeer
+	FoundValue
 )
 
+// QueryEvent is one step of a routing query. ID is the peer the step is
+// about, Time is when it happened, and Duration how long the step took:
+// for PeerResponse and QueryError, the time since the peer was asked.
 type QueryEvent struct {
 	ID        peer.ID
 	Type      QueryEventType
 	Responses []*peer.PeerInfo
 	Extra     string
+	Time      time.Time
+	Duration  time.Duration
 }
 
 func RegisterForQueryEvents(ctx context.Context, ch chan<- *QueryEvent) context.Context {
@@ -42,6 +50,10 @@ func PublishQueryEvent(ctx context.Context, ev *QueryEvent) {
 		return
 	}
 
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
 	select {
 	case ch <- ev:
 	case <-ctx.Done():
@@ -54,6 +66,8 @@ func (qe *QueryEvent) MarshalJSON() ([]byte, error) {
 	out["Type"] = int(qe.Type)
 	out["Responses"] = qe.Responses
 	out["Extra"] = qe.Extra
+	out["Time"] = qe.Time
+	out["Duration"] = int64(qe.Duration)
 	return json.Marshal(out)
 }
 
@@ -63,6 +77,8 @@ func (qe *QueryEvent) UnmarshalJSON(b []byte) error {
 		Type      int
 		Responses []*peer.PeerInfo
 		Extra     string
+		Time      time.Time
+		Duration  int64
 	}{}
 	err := json.Unmarshal(b, &temp)
 	if err != nil {
@@ -78,5 +94,7 @@ func (qe *QueryEvent) UnmarshalJSON(b []byte) error {
 	qe.Type = QueryEventType(temp.Type)
 	qe.Responses = temp.Responses
 	qe.Extra = temp.Extra
+	qe.Time = temp.Time
+	qe.Duration = time.Duration(temp.Duration)
 	return nil
 }
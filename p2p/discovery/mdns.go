@@ -40,6 +40,7 @@ type mdnsService struct {
 	lk       sync.Mutex
 	notifees []Notifee
 	interval time.Duration
+	tag      string
 }
 
 func getDialableListenAddr(ph host.Host) (*net.TCPAddr, error) {
@@ -57,6 +58,12 @@ func getDialableListenAddr(ph host.Host) (*net.TCPAddr, error) {
 }
 
 func NewMdnsService(peerhost host.Host, interval time.Duration) (Service, error) {
+	return NewMdnsServiceWithTag(peerhost, interval, ServiceTag)
+}
+
+// NewMdnsServiceWithTag returns a service finding the peers announced under
+// tag instead of ServiceTag, such as those of a private network.
+func NewMdnsServiceWithTag(peerhost host.Host, interval time.Duration, tag string) (Service, error) {
 
 	// TODO: dont let mdns use logging...
 	golog.SetOutput(ioutil.Discard)
@@ -75,7 +82,7 @@ func NewMdnsService(peerhost host.Host, interval time.Duration) (Service, error)
 	myid := peerhost.ID().Pretty()
 
 	info := []string{myid}
-	service, err := mdns.NewMDNSService(myid, ServiceTag, "", "", port, ipaddrs, info)
+	service, err := mdns.NewMDNSService(myid, tag, "", "", port, ipaddrs, info)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +98,7 @@ func NewMdnsService(peerhost host.Host, interval time.Duration) (Service, error)
 		service:  service,
 		host:     peerhost,
 		interval: interval,
+		tag:      tag,
 	}
 
 	go s.pollForEntries()
@@ -117,7 +125,7 @@ func (m *mdnsService) pollForEntries() {
 			qp := mdns.QueryParam{}
 			qp.Domain = "local"
 			qp.Entries = entriesCh
-			qp.Service = ServiceTag
+			qp.Service = m.tag
 			qp.Timeout = time.Second * 5
 
 			err := mdns.Query(&qp)
@@ -53,6 +53,16 @@ func (d *Dialer) Dial(ctx context.Context, raddr ma.Multiaddr, remote peer.ID) (
 			maconn = d.Wrapper(maconn)
 		}
 
+		if d.Protector != nil {
+			pconn, err := d.Protector.Protect(maconn, true)
+			if err != nil {
+				maconn.Close()
+				errOut = err
+				return
+			}
+			maconn = pconn
+		}
+
 		c, err := newSingleConn(ctx, d.LocalPeer, remote, maconn)
 		if err != nil {
 			maconn.Close()
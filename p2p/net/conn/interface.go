@@ -6,6 +6,7 @@ import (
 	"time"
 
 	ic "github.com/ipfs/go-ipfs/p2p/crypto"
+	pnet "github.com/ipfs/go-ipfs/p2p/net/pnet"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	u "github.com/ipfs/go-ipfs/util"
 
@@ -69,6 +70,9 @@ type Dialer struct {
 
 	// Wrapper to wrap the raw connection (optional)
 	Wrapper func(manet.Conn) manet.Conn
+
+	// Protector of the private network to dial in (optional)
+	Protector *pnet.Protector
 }
 
 // Listener is an object that can accept connections. It matches net.Listener
@@ -13,6 +13,7 @@ import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	ic "github.com/ipfs/go-ipfs/p2p/crypto"
+	pnet "github.com/ipfs/go-ipfs/p2p/net/pnet"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 )
 
@@ -28,6 +29,8 @@ type listener struct {
 
 	wrapper ConnWrapper
 
+	protec *pnet.Protector // protector of the private network, if any
+
 	cg ctxgroup.ContextGroup
 }
 
@@ -86,6 +89,16 @@ func (l *listener) Accept() (net.Conn, error) {
 			maconn = l.wrapper(maconn)
 		}
 
+		if l.protec != nil {
+			pconn, err := l.protec.Protect(maconn, false)
+			if err != nil {
+				log.Infof("ignoring conn from outside the private network: %s %s", err, maconn.RemoteMultiaddr())
+				maconn.Close()
+				continue
+			}
+			maconn = pconn
+		}
+
 		c, err := newSingleConn(ctx, l.local, "", maconn)
 		if err != nil {
 			if catcher.IsTemporary(err) {
@@ -129,6 +142,7 @@ func (l *listener) Loggable() map[string]interface{} {
 			"peer":    l.LocalPeer(),
 			"address": l.Multiaddr(),
 			"secure":  (l.privk != nil),
+			"private": (l.protec != nil),
 		},
 	}
 }
@@ -163,6 +177,16 @@ func (l *listener) SetConnWrapper(cw ConnWrapper) {
 	l.wrapper = cw
 }
 
+type ListenerProtector interface {
+	SetProtector(*pnet.Protector)
+}
+
+// SetProtector makes the listener accept only connections from the private
+// network of p. MUST be set _before_ calling `Accept()`
+func (l *listener) SetProtector(p *pnet.Protector) {
+	l.protec = p
+}
+
 func manetListen(addr ma.Multiaddr) (manet.Listener, error) {
 	network, naddr, err := manet.DialArgs(addr)
 	if err != nil {
@@ -0,0 +1,213 @@
+// package pnet implements private networks: peers of a private network
+// share a secret key, and accept connections only from peers that hold it
+// too.
+//
+// The key protects raw connections before they are secured: both ends
+// prove that they hold it, then encrypt all traffic with it, so that
+// outsiders can neither connect nor see who the peers of the network are.
+package pnet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	manet "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+)
+
+// KeySize is the size in bytes of the keys of private networks.
+const KeySize = 32
+
+// HandshakeTimeout bounds how long the remote end of a connection has to
+// prove that it is in the private network.
+var HandshakeTimeout = 10 * time.Second
+
+// ErrNotInNetwork is returned when the remote end of a connection does not
+// hold the key of the private network.
+var ErrNotInNetwork = errors.New("remote peer is not in the private network")
+
+const nonceSize = aes.BlockSize
+
+// Protector protects the connections of a private network.
+type Protector struct {
+	encKey      []byte // derives the keys traffic is encrypted with
+	macKey      []byte // derives the proofs that peers hold the key
+	fingerprint string
+}
+
+// NewProtector returns a Protector for the private network of key, which
+// must be KeySize bytes long.
+func NewProtector(key []byte) (*Protector, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("private network key must be %d bytes, not %d", KeySize, len(key))
+	}
+	sum := sha256.Sum256(key)
+	return &Protector{
+		encKey:      deriveKey(key, "encryption"),
+		macKey:      deriveKey(key, "authentication"),
+		fingerprint: hex.EncodeToString(sum[:8]),
+	}, nil
+}
+
+// DecodeKey decodes a base64 encoded private network key, as stored in
+// the config.
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private network key: %s", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("private network key must be %d bytes, not %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// Fingerprint identifies the private network without revealing its key.
+func (p *Protector) Fingerprint() string {
+	return p.fingerprint
+}
+
+// Protect runs the handshake of the private network on c, and returns a
+// connection encrypting everything sent over c. dialer tells which end of
+// c this is: one end must be the dialer and the other the listener. It
+// fails with ErrNotInNetwork if the remote end does not hold the key.
+func (p *Protector) Protect(c manet.Conn, dialer bool) (manet.Conn, error) {
+	if err := c.SetDeadline(time.Now().Add(HandshakeTimeout)); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	remoteNonce, err := exchange(c, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(nonce, remoteNonce) {
+		// our own messages sent back to us
+		return nil, ErrNotInNetwork
+	}
+
+	// everything below is bound to both nonces, in the same order at both
+	// ends, and to the role of the end it comes from: messages of one
+	// connection are no good on another, nor sent back on the same one.
+	dialNonce, listenNonce := nonce, remoteNonce
+	if !dialer {
+		dialNonce, listenNonce = remoteNonce, nonce
+	}
+	proof, err := exchange(c, p.proof(dialer, dialNonce, listenNonce))
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(proof, p.proof(!dialer, dialNonce, listenNonce)) {
+		return nil, ErrNotInNetwork
+	}
+
+	if err := c.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	// each connection, and each direction of it, has its own key, so no
+	// keystream is ever used twice
+	w, err := p.stream(dialer, dialNonce, listenNonce)
+	if err != nil {
+		return nil, err
+	}
+	r, err := p.stream(!dialer, dialNonce, listenNonce)
+	if err != nil {
+		return nil, err
+	}
+	return &protectedConn{
+		Conn: c,
+		r:    cipher.StreamReader{S: r, R: c},
+		w:    cipher.StreamWriter{S: w, W: c},
+	}, nil
+}
+
+// proof is what the dialer, or the listener, of the connection with those
+// nonces sends to prove it holds the key.
+func (p *Protector) proof(dialer bool, dialNonce, listenNonce []byte) []byte {
+	return connKey(p.macKey, dialer, dialNonce, listenNonce)
+}
+
+// stream returns the cipher of the traffic sent by the dialer, or the
+// listener, of the connection with those nonces.
+func (p *Protector) stream(dialer bool, dialNonce, listenNonce []byte) (cipher.Stream, error) {
+	block, err := aes.NewCipher(connKey(p.encKey, dialer, dialNonce, listenNonce))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewCTR(block, make([]byte, aes.BlockSize)), nil
+}
+
+// connKey derives from key a key for one direction of the connection with
+// those nonces.
+func connKey(key []byte, dialer bool, dialNonce, listenNonce []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	if dialer {
+		mac.Write([]byte("dialer"))
+	} else {
+		mac.Write([]byte("listener"))
+	}
+	mac.Write(dialNonce)
+	mac.Write(listenNonce)
+	return mac.Sum(nil)
+}
+
+// exchange sends msg on c, and returns the message of the same size the
+// remote end sends.
+func exchange(c manet.Conn, msg []byte) ([]byte, error) {
+	werr := make(chan error, 1)
+	go func() {
+		_, err := c.Write(msg)
+		werr <- err
+	}()
+
+	in := make([]byte, len(msg))
+	if _, err := io.ReadFull(c, in); err != nil {
+		return nil, err
+	}
+	if err := <-werr; err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("ipfs private network " + purpose))
+	return mac.Sum(nil)
+}
+
+// protectedConn encrypts all that is sent over the wrapped conn.
+type protectedConn struct {
+	manet.Conn
+
+	rlock sync.Mutex
+	r     cipher.StreamReader
+	wlock sync.Mutex
+	w     cipher.StreamWriter
+}
+
+func (c *protectedConn) Read(buf []byte) (int, error) {
+	c.rlock.Lock()
+	defer c.rlock.Unlock()
+	return c.r.Read(buf)
+}
+
+func (c *protectedConn) Write(buf []byte) (int, error) {
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	return c.w.Write(buf)
+}
@@ -0,0 +1,184 @@
+package pnet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	manet "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr-net"
+)
+
+func newProtector(t *testing.T) *Protector {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProtector(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// connPair returns the two ends of a tcp connection.
+func connPair(t *testing.T) (manet.Conn, manet.Conn) {
+	l, err := manet.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	accepted := make(chan manet.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- c
+	}()
+	var d manet.Dialer
+	c, err := d.Dial(l.Multiaddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, <-accepted
+}
+
+// protectPair protects both ends of a connection, with pa at the dialing
+// end and pb at the listening one.
+func protectPair(t *testing.T, pa, pb *Protector) (manet.Conn, manet.Conn, error, error) {
+	a, b := connPair(t)
+	type result struct {
+		c   manet.Conn
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := pb.Protect(b, false)
+		done <- result{c, err}
+	}()
+	ca, erra := pa.Protect(a, true)
+	res := <-done
+	if erra != nil {
+		a.Close()
+	}
+	if res.err != nil {
+		b.Close()
+	}
+	return ca, res.c, erra, res.err
+}
+
+func TestProtect(t *testing.T) {
+	p := newProtector(t)
+	a, b, erra, errb := protectPair(t, p, p)
+	if erra != nil || errb != nil {
+		t.Fatal(erra, errb)
+	}
+	defer a.Close()
+	defer b.Close()
+
+	for _, dir := range []struct{ from, to manet.Conn }{{a, b}, {b, a}} {
+		msg := []byte("hello private network")
+		go dir.from.Write(msg)
+		buf := make([]byte, len(msg))
+		if _, err := io.ReadFull(dir.to, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, msg) {
+			t.Fatalf("expected %q got %q", msg, buf)
+		}
+	}
+}
+
+func TestProtectEncrypts(t *testing.T) {
+	p := newProtector(t)
+	a, b, erra, errb := protectPair(t, p, p)
+	if erra != nil || errb != nil {
+		t.Fatal(erra, errb)
+	}
+	defer a.Close()
+	defer b.Close()
+
+	msg := []byte("plaintext")
+	go a.Write(msg)
+	raw := make([]byte, len(msg))
+	if _, err := io.ReadFull(b.(*protectedConn).Conn, raw); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(raw, msg) {
+		t.Fatal("sent in the clear")
+	}
+}
+
+func TestProtectRejectsOutsiders(t *testing.T) {
+	_, _, erra, errb := protectPair(t, newProtector(t), newProtector(t))
+	if erra != ErrNotInNetwork || errb != ErrNotInNetwork {
+		t.Fatalf("expected ErrNotInNetwork, got %v, %v", erra, errb)
+	}
+}
+
+func TestProtectKeysEachDirection(t *testing.T) {
+	p := newProtector(t)
+	a, b, erra, errb := protectPair(t, p, p)
+	if erra != nil || errb != nil {
+		t.Fatal(erra, errb)
+	}
+	defer a.Close()
+	defer b.Close()
+
+	msg := []byte("same plaintext")
+	var raw [2][]byte
+	for i, dir := range []struct{ from, to manet.Conn }{{a, b}, {b, a}} {
+		go dir.from.Write(msg)
+		raw[i] = make([]byte, len(msg))
+		if _, err := io.ReadFull(dir.to.(*protectedConn).Conn, raw[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if bytes.Equal(raw[0], raw[1]) {
+		t.Fatal("both directions encrypted with the same keystream")
+	}
+}
+
+func TestProtectRejectsReflection(t *testing.T) {
+	p := newProtector(t)
+
+	// an outsider connected to two peers of the network, forwarding what
+	// each sends to the other, so that both take the other for the remote
+	// end of their connection, in the same role
+	a, outA := connPair(t)
+	b, outB := connPair(t)
+	go io.Copy(outA, outB)
+	go io.Copy(outB, outA)
+	defer outA.Close()
+	defer outB.Close()
+
+	errs := make(chan error, 2)
+	for _, c := range []manet.Conn{a, b} {
+		go func(c manet.Conn) {
+			_, err := p.Protect(c, false)
+			c.Close()
+			errs <- err
+		}(c)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != ErrNotInNetwork {
+			t.Fatalf("expected ErrNotInNetwork, got %v", err)
+		}
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	key := make([]byte, KeySize)
+	if _, err := DecodeKey(base64.StdEncoding.EncodeToString(key)); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"", "not base64!", base64.StdEncoding.EncodeToString(key[1:])} {
+		if _, err := DecodeKey(s); err == nil {
+			t.Fatalf("no error for %q", s)
+		}
+	}
+}
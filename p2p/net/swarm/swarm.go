@@ -9,6 +9,7 @@ import (
 
 	metrics "github.com/ipfs/go-ipfs/metrics"
 	inet "github.com/ipfs/go-ipfs/p2p/net"
+	pnet "github.com/ipfs/go-ipfs/p2p/net/pnet"
 	addrutil "github.com/ipfs/go-ipfs/p2p/net/swarm/addr"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	eventlog "github.com/ipfs/go-ipfs/thirdparty/eventlog"
@@ -52,11 +53,20 @@ type Swarm struct {
 
 	cg  ctxgroup.ContextGroup
 	bwc metrics.Reporter
+
+	protec *pnet.Protector // protector of the private network, if any
 }
 
 // NewSwarm constructs a Swarm, with a Chan.
 func NewSwarm(ctx context.Context, listenAddrs []ma.Multiaddr,
 	local peer.ID, peers peer.Peerstore, bwc metrics.Reporter) (*Swarm, error) {
+	return NewPrivateSwarm(ctx, listenAddrs, local, peers, bwc, nil)
+}
+
+// NewPrivateSwarm constructs a Swarm that connects only to the peers of the
+// private network of protec. A nil protec means the public network.
+func NewPrivateSwarm(ctx context.Context, listenAddrs []ma.Multiaddr,
+	local peer.ID, peers peer.Peerstore, bwc metrics.Reporter, protec *pnet.Protector) (*Swarm, error) {
 
 	listenAddrs, err := filterAddrs(listenAddrs)
 	if err != nil {
@@ -71,6 +81,7 @@ func NewSwarm(ctx context.Context, listenAddrs []ma.Multiaddr,
 		dialT:  DialTimeout,
 		notifs: make(map[inet.Notifiee]ps.Notifiee),
 		bwc:    bwc,
+		protec: protec,
 	}
 
 	// configure Swarm
@@ -322,6 +322,7 @@ func (s *Swarm) dial(ctx context.Context, p peer.ID) (*Conn, error) {
 		Wrapper: func(c manet.Conn) manet.Conn {
 			return mconn.WrapConn(s.bwc, c)
 		},
+		Protector: s.protec,
 	}
 
 	// try to get a connection to any addr
@@ -75,6 +75,15 @@ func (s *Swarm) setupListener(maddr ma.Multiaddr) error {
 		})
 	}
 
+	if s.protec != nil {
+		lp, ok := list.(conn.ListenerProtector)
+		if !ok {
+			list.Close()
+			return fmt.Errorf("listener on %s cannot be kept to the private network", maddr)
+		}
+		lp.SetProtector(s.protec)
+	}
+
 	// AddListener to the peerstream Listener. this will begin accepting connections
 	// and streams!
 	sl, err := s.swarm.AddListener(list)
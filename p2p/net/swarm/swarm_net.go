@@ -7,6 +7,7 @@ import (
 
 	metrics "github.com/ipfs/go-ipfs/metrics"
 	inet "github.com/ipfs/go-ipfs/p2p/net"
+	pnet "github.com/ipfs/go-ipfs/p2p/net/pnet"
 
 	ctxgroup "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-ctxgroup"
 	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
@@ -21,8 +22,15 @@ type Network Swarm
 // NewNetwork constructs a new network and starts listening on given addresses.
 func NewNetwork(ctx context.Context, listen []ma.Multiaddr, local peer.ID,
 	peers peer.Peerstore, bwc metrics.Reporter) (*Network, error) {
+	return NewPrivateNetwork(ctx, listen, local, peers, bwc, nil)
+}
+
+// NewPrivateNetwork constructs a new network of the private network of
+// protec, and starts listening on given addresses.
+func NewPrivateNetwork(ctx context.Context, listen []ma.Multiaddr, local peer.ID,
+	peers peer.Peerstore, bwc metrics.Reporter, protec *pnet.Protector) (*Network, error) {
 
-	s, err := NewSwarm(ctx, listen, local, peers, bwc)
+	s, err := NewPrivateSwarm(ctx, listen, local, peers, bwc, protec)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,88 @@
+package swarm
+
+import (
+	"testing"
+
+	metrics "github.com/ipfs/go-ipfs/metrics"
+	pnet "github.com/ipfs/go-ipfs/p2p/net/pnet"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
+
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+func makePrivateSwarm(ctx context.Context, t *testing.T, protec *pnet.Protector) *Swarm {
+	localnp := testutil.RandPeerNetParamsOrFatal(t)
+
+	peerstore := peer.NewPeerstore()
+	peerstore.AddPubKey(localnp.ID, localnp.PubKey)
+	peerstore.AddPrivKey(localnp.ID, localnp.PrivKey)
+
+	addrs := []ma.Multiaddr{localnp.Addr}
+	swarm, err := NewPrivateSwarm(ctx, addrs, localnp.ID, peerstore, metrics.NewBandwidthCounter(), protec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swarm.SetStreamHandler(EchoStreamHandler)
+	return swarm
+}
+
+func TestPrivateNetwork(t *testing.T) {
+	ctx := context.Background()
+
+	key := make([]byte, pnet.KeySize)
+	copy(key, "the key of a test network")
+	protec, err := pnet.NewProtector(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := pnet.NewProtector(make([]byte, pnet.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := makePrivateSwarm(ctx, t, protec)
+	b := makePrivateSwarm(ctx, t, protec)
+	outsiders := []*Swarm{
+		makePrivateSwarm(ctx, t, nil),
+		makePrivateSwarm(ctx, t, other),
+	}
+	defer a.Close()
+	defer b.Close()
+
+	dial := func(from, to *Swarm) error {
+		from.peers.AddAddr(to.LocalPeer(), to.ListenAddresses()[0], peer.PermanentAddrTTL)
+		_, err := from.Dial(ctx, to.LocalPeer())
+		return err
+	}
+
+	if err := dial(a, b); err != nil {
+		t.Fatal(err)
+	}
+	s, err := a.NewStreamWithPeer(b.LocalPeer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := s.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "pong" {
+		t.Fatalf("expected 'pong' got '%s'", buf)
+	}
+
+	for _, o := range outsiders {
+		defer o.Close()
+		if err := dial(o, a); err == nil {
+			t.Fatal("an outsider connected to the private network")
+		}
+		if err := dial(b, o); err == nil {
+			t.Fatal("the private network connected to an outsider")
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package peer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	ic "github.com/ipfs/go-ipfs/p2p/crypto"
+)
+
+const (
+	// SavedPeerTTL is how long a peer saved by SavePeers is kept.
+	SavedPeerTTL = time.Hour * 24 * 7
+
+	// SavedAddrTTL is the longest ttl given to the addresses of a peer
+	// loaded by LoadPeers. They may be stale, so they are only used until
+	// fresh ones are learned.
+	SavedAddrTTL = AddressTTL
+
+	// MaxSavedPeers is the most peers SavePeers keeps in a datastore.
+	MaxSavedPeers = 1000
+)
+
+// savedPeersPrefix is where saved peers live in a datastore, at
+// /peers/<peer>.
+const savedPeersPrefix = "/peers/"
+
+// savedPeer is what SavePeers keeps of a peer.
+type savedPeer struct {
+	Addrs   [][]byte
+	PubKey  []byte        `json:",omitempty"`
+	Latency time.Duration `json:",omitempty"`
+
+	// Good is set for peers we were connected to when saving
+	Good    bool
+	Expires time.Time
+}
+
+// SavePeers writes the addresses, public keys and latencies of the peers
+// in ps to d, to be loaded again with LoadPeers. The peers in good are
+// marked as good ones to start from, and saved first. Peers whose private
+// key is in ps are not saved: those are our own. Peers without live
+// addresses are not saved again either, so that they expire.
+//
+// At most MaxSavedPeers are kept in d. Saved peers that have expired, and
+// older ones there is no room left for, are removed.
+func SavePeers(ps Peerstore, d ds.Datastore, good []ID) error {
+	isGood := make(map[ID]bool, len(good))
+	for _, p := range good {
+		isGood[p] = true
+	}
+
+	peers := append([]ID(nil), good...)
+	for _, p := range ps.Peers() {
+		if !isGood[p] {
+			peers = append(peers, p)
+		}
+	}
+
+	saved := make(map[ds.Key]bool)
+	expires := time.Now().Add(SavedPeerTTL)
+	for _, p := range peers {
+		if len(saved) == MaxSavedPeers {
+			break
+		}
+		if ps.PrivKey(p) != nil {
+			continue
+		}
+
+		addrs := ps.Addrs(p)
+		if len(addrs) == 0 && !isGood[p] {
+			continue // lost track of it, let it expire
+		}
+
+		sp := savedPeer{
+			Latency: ps.LatencyEWMA(p),
+			Good:    isGood[p],
+			Expires: expires,
+		}
+		for _, a := range addrs {
+			sp.Addrs = append(sp.Addrs, a.Bytes())
+		}
+		if pk := ps.PubKey(p); pk != nil {
+			b, err := pk.Bytes()
+			if err != nil {
+				return err
+			}
+			sp.PubKey = b
+		}
+		if len(sp.Addrs) == 0 && sp.PubKey == nil {
+			continue // nothing worth keeping
+		}
+
+		b, err := json.Marshal(sp)
+		if err != nil {
+			return err
+		}
+		k := ds.NewKey(savedPeersPrefix + p.Pretty())
+		if err := d.Put(k, b); err != nil {
+			return err
+		}
+		saved[k] = true
+	}
+	return pruneSavedPeers(d, saved)
+}
+
+// pruneSavedPeers removes the peers in d, other than the ones just saved,
+// that have expired or do not fit within MaxSavedPeers
+func pruneSavedPeers(d ds.Datastore, saved map[ds.Key]bool) error {
+	res, err := d.Query(dsq.Query{Prefix: savedPeersPrefix})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	var remove []ds.Key
+	kept := len(saved)
+	now := time.Now()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+		k := ds.NewKey(e.Key)
+		if saved[k] {
+			continue
+		}
+
+		var sp savedPeer
+		b, ok := e.Value.([]byte)
+		if ok && json.Unmarshal(b, &sp) == nil && now.Before(sp.Expires) && kept < MaxSavedPeers {
+			kept++
+			continue
+		}
+		remove = append(remove, k)
+	}
+
+	for _, k := range remove {
+		if err := d.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadPeers adds the peers saved in d by SavePeers to ps, and returns the
+// ones saved as good. Saved peers that have expired are removed from d.
+func LoadPeers(ps Peerstore, d ds.Datastore) ([]ID, error) {
+	res, err := d.Query(dsq.Query{Prefix: savedPeersPrefix})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var good []ID
+	now := time.Now()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return good, e.Error
+		}
+
+		p, err := IDB58Decode(e.Key[strings.LastIndex(e.Key, "/")+1:])
+		if err != nil {
+			log.Debugf("bad saved peer key %s: %s", e.Key, err)
+			continue
+		}
+
+		b, ok := e.Value.([]byte)
+		if !ok {
+			continue
+		}
+		var sp savedPeer
+		if err := json.Unmarshal(b, &sp); err != nil {
+			log.Debugf("bad saved peer %s: %s", p, err)
+			continue
+		}
+
+		if now.After(sp.Expires) {
+			if err := d.Delete(ds.NewKey(e.Key)); err != nil {
+				log.Debugf("failed to remove saved peer %s: %s", p, err)
+			}
+			continue
+		}
+
+		if sp.PubKey != nil {
+			pk, err := ic.UnmarshalPublicKey(sp.PubKey)
+			if err == nil {
+				err = ps.AddPubKey(p, pk)
+			}
+			if err != nil {
+				log.Debugf("bad public key for saved peer %s: %s", p, err)
+			}
+		}
+		if sp.Latency > 0 && ps.LatencyEWMA(p) == 0 {
+			ps.RecordLatency(p, sp.Latency)
+		}
+
+		var addrs []ma.Multiaddr
+		for _, ab := range sp.Addrs {
+			a, err := ma.NewMultiaddrBytes(ab)
+			if err != nil {
+				log.Debugf("bad address for saved peer %s: %s", p, err)
+				continue
+			}
+			addrs = append(addrs, a)
+		}
+		ttl := sp.Expires.Sub(now)
+		if ttl > SavedAddrTTL {
+			ttl = SavedAddrTTL
+		}
+		ps.AddAddrs(p, addrs, ttl)
+
+		if sp.Good && len(addrs) > 0 {
+			good = append(good, p)
+		}
+	}
+	return good, nil
+}
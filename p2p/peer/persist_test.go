@@ -0,0 +1,181 @@
+package peer
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	ic "github.com/ipfs/go-ipfs/p2p/crypto"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+func randPeer(t *testing.T) (ID, ic.PrivKey) {
+	sk, pk, err := ic.GenerateKeyPair(ic.RSA, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, sk
+}
+
+func TestSaveLoadPeers(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	ps := NewPeerstore()
+
+	self, sk := randPeer(t)
+	ps.AddPrivKey(self, sk)
+	ps.AddPubKey(self, sk.GetPublic())
+	ps.AddAddr(self, MA(t, "/ip4/127.0.0.1/tcp/4001"), PermanentAddrTTL)
+
+	good, gsk := randPeer(t)
+	ps.AddPubKey(good, gsk.GetPublic())
+	ps.AddAddr(good, MA(t, "/ip4/1.2.3.4/tcp/4001"), AddressTTL)
+	ps.RecordLatency(good, time.Millisecond*20)
+
+	other, _ := randPeer(t)
+	ps.AddAddr(other, MA(t, "/ip4/5.6.7.8/tcp/4001"), AddressTTL)
+
+	if err := SavePeers(ps, d, []ID{good}); err != nil {
+		t.Fatal(err)
+	}
+
+	// as after a restart
+	ps = NewPeerstore()
+	loaded, err := LoadPeers(ps, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || loaded[0] != good {
+		t.Fatalf("expected the good peer, got %v", loaded)
+	}
+
+	testHas(t, []ma.Multiaddr{MA(t, "/ip4/1.2.3.4/tcp/4001")}, ps.Addrs(good))
+	testHas(t, []ma.Multiaddr{MA(t, "/ip4/5.6.7.8/tcp/4001")}, ps.Addrs(other))
+	if len(ps.Addrs(self)) != 0 {
+		t.Fatal("saved our own peer")
+	}
+	if pk := ps.PubKey(good); pk == nil || !pk.Equals(gsk.GetPublic()) {
+		t.Fatal("public key was not loaded")
+	}
+	if lat := ps.LatencyEWMA(good); lat != time.Millisecond*20 {
+		t.Fatalf("bad latency: %s", lat)
+	}
+}
+
+func TestSavedPeersExpire(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	p, _ := randPeer(t)
+
+	sp := savedPeer{
+		Addrs:   [][]byte{MA(t, "/ip4/1.2.3.4/tcp/4001").Bytes()},
+		Good:    true,
+		Expires: time.Now().Add(-time.Minute),
+	}
+	b, err := json.Marshal(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := ds.NewKey(savedPeersPrefix + p.Pretty())
+	if err := d.Put(k, b); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := NewPeerstore()
+	loaded, err := LoadPeers(ps, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 0 || len(ps.Addrs(p)) != 0 {
+		t.Fatal("loaded an expired peer")
+	}
+	if has, _ := d.Has(k); has {
+		t.Fatal("expired peer still in the datastore")
+	}
+}
+
+func TestSavePeersLetsLostPeersExpire(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+
+	// saved by an earlier run, and loaded without its addresses since
+	lost, lsk := randPeer(t)
+	expires := time.Now().Add(time.Hour).Round(time.Second)
+	b, err := json.Marshal(savedPeer{PubKey: mustBytes(t, lsk.GetPublic()), Expires: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lostKey := ds.NewKey(savedPeersPrefix + lost.Pretty())
+	if err := d.Put(lostKey, b); err != nil {
+		t.Fatal(err)
+	}
+
+	gone, _ := randPeer(t)
+	b, err = json.Marshal(savedPeer{Expires: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	goneKey := ds.NewKey(savedPeersPrefix + gone.Pretty())
+	if err := d.Put(goneKey, b); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := NewPeerstore()
+	ps.AddPubKey(lost, lsk.GetPublic())
+	if err := SavePeers(ps, d, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := d.Get(lostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sp savedPeer
+	if err := json.Unmarshal(v.([]byte), &sp); err != nil {
+		t.Fatal(err)
+	}
+	if !sp.Expires.Equal(expires) {
+		t.Fatal("peer without addresses was saved again")
+	}
+	if has, _ := d.Has(goneKey); has {
+		t.Fatal("expired peer still in the datastore")
+	}
+}
+
+func TestSavePeersLimit(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	ps := NewPeerstore()
+	for i := 0; i < MaxSavedPeers+10; i++ {
+		p := ID(u.Hash([]byte(fmt.Sprintf("peer %d", i))))
+		ps.AddAddr(p, MA(t, "/ip4/1.2.3.4/tcp/4001"), AddressTTL)
+	}
+
+	if err := SavePeers(ps, d, nil); err != nil {
+		t.Fatal(err)
+	}
+	res, err := d.Query(dsq.Query{Prefix: savedPeersPrefix, KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != MaxSavedPeers {
+		t.Fatalf("expected %d saved peers, got %d", MaxSavedPeers, len(entries))
+	}
+}
+
+func mustBytes(t *testing.T, pk ic.PubKey) []byte {
+	b, err := pk.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
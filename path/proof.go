@@ -0,0 +1,96 @@
+package path
+
+import (
+	"errors"
+	"fmt"
+
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	blocks "github.com/ipfs/go-ipfs/blocks"
+	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	blockservice "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+var ErrEmptyProof = errors.New("proof contains no blocks")
+
+// Proof shows that the object named by a path is reachable from the root of
+// the path. It holds the encoded blocks read while resolving the path, root
+// first: every object along the path, the shards of any sharded directory
+// passed through, and the target object itself.
+type Proof [][]byte
+
+// Prove resolves fpath and returns the blocks that prove it.
+func (s *Resolver) Prove(fpath Path) (Proof, error) {
+	rec := &recordingDAG{
+		DAGService: s.DAG,
+		seen:       make(map[u.Key]struct{}),
+	}
+
+	r := &Resolver{DAG: rec}
+	_, err := r.ResolvePathComponents(fpath)
+	if err != nil {
+		return nil, err
+	}
+	return rec.blocks, nil
+}
+
+// VerifyProof checks, without fetching anything, that proof shows the object
+// named by fpath to be reachable from its root. It returns that object.
+func VerifyProof(fpath Path, proof Proof) (*merkledag.Node, error) {
+	if len(proof) == 0 {
+		return nil, ErrEmptyProof
+	}
+
+	// blocks are keyed by the hash of their contents, so a block that does
+	// not match the link pointing to it will simply not be found
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore()))
+	for _, b := range proof {
+		err := bstore.Put(blocks.NewBlock(b))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bserv, err := blockservice.New(bstore, offline.Exchange(bstore))
+	if err != nil {
+		return nil, err
+	}
+	defer bserv.Close()
+
+	r := &Resolver{DAG: merkledag.NewDAGService(bserv)}
+	nd, err := r.ResolvePath(fpath)
+	if err != nil {
+		return nil, fmt.Errorf("proof does not show %s: %s", fpath, err)
+	}
+	return nd, nil
+}
+
+// recordingDAG keeps the encoded form of every node read through it
+type recordingDAG struct {
+	merkledag.DAGService
+
+	blocks Proof
+	seen   map[u.Key]struct{}
+}
+
+func (r *recordingDAG) Get(ctx context.Context, k u.Key) (*merkledag.Node, error) {
+	nd, err := r.DAGService.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := r.seen[k]; !ok {
+		b, err := nd.Encoded(false)
+		if err != nil {
+			return nil, err
+		}
+		r.seen[k] = struct{}{}
+		r.blocks = append(r.blocks, b)
+	}
+	return nd, nil
+}
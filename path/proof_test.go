@@ -0,0 +1,102 @@
+package path
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	dagutils "github.com/ipfs/go-ipfs/merkledag/utils"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	uio "github.com/ipfs/go-ipfs/unixfs/io"
+)
+
+func buildProofTree(t *testing.T, dserv merkledag.DAGService) Path {
+	old := uio.ShardSplitThreshold
+	uio.ShardSplitThreshold = 8
+	defer func() { uio.ShardSplitThreshold = old }()
+
+	mkdir := func() *merkledag.Node {
+		return &merkledag.Node{Data: ft.FolderPBData()}
+	}
+
+	e := dagutils.NewDagEditor(dserv, mkdir())
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("a/big/file-%d", i)
+		err := e.InsertNodeAtPath(context.Background(), name, &merkledag.Node{Data: ft.WrapData([]byte(name))}, mkdir)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := e.GetNode()
+	_, err := dserv.Add(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := root.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return FromKey(k)
+}
+
+func TestProof(t *testing.T) {
+	dserv := mdtest.Mock(t)
+	root := buildProofTree(t, dserv)
+	fpath := FromSegments(root.String(), "a", "big", "file-23")
+
+	r := &Resolver{DAG: dserv}
+	proof, err := r.Prove(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// root, a, the shards of big, and the file
+	if len(proof) < 4 {
+		t.Fatalf("proof has only %d blocks", len(proof))
+	}
+
+	nd, err := VerifyProof(fpath, proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := r.ResolvePath(fpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k1, _ := nd.Key()
+	k2, _ := expected.Key()
+	if k1 != k2 {
+		t.Fatal("verified node is not the target")
+	}
+
+	// a proof is only good for its own path
+	_, err = VerifyProof(FromSegments(root.String(), "a", "big", "file-24"), proof)
+	if err == nil {
+		t.Fatal("proof verified for another path")
+	}
+
+	// every block is needed
+	for i := range proof {
+		partial := append(append(Proof{}, proof[:i]...), proof[i+1:]...)
+		_, err := VerifyProof(fpath, partial)
+		if err == nil {
+			t.Fatalf("proof verified without block %d", i)
+		}
+	}
+
+	// and must not be tampered with
+	tampered := append(Proof{}, proof...)
+	last := len(tampered) - 1
+	tampered[last] = append(append([]byte{}, tampered[last]...), 0)
+	_, err = VerifyProof(fpath, tampered)
+	if err == nil {
+		t.Fatal("tampered proof verified")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	mh "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multihash"
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	merkledag "github.com/ipfs/go-ipfs/merkledag"
+	hamt "github.com/ipfs/go-ipfs/unixfs/hamt"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
@@ -103,21 +104,15 @@ func (s *Resolver) ResolveLinks(ndd *merkledag.Node, names []string) (
 	// for each of the path components
 	for _, name := range names {
 
-		var next u.Key
-		var nlink *merkledag.Link
-		// for each of the links in nd, the current object
-		for _, link := range nd.Links {
-			if link.Name == name {
-				next = u.Key(link.Hash)
-				nlink = link
-				break
-			}
-		}
-
-		if next == "" {
+		nlink, err := s.findLink(nd, name)
+		if err == merkledag.ErrNotFound {
 			n, _ := nd.Multihash()
 			return result, ErrNoLink{name: name, node: n}
 		}
+		if err != nil {
+			return result, err
+		}
+		next := u.Key(nlink.Hash)
 
 		if nlink.Node == nil {
 			// fetch object for link and assign to nd
@@ -136,3 +131,26 @@ func (s *Resolver) ResolveLinks(ndd *merkledag.Node, names []string) (
 	}
 	return
 }
+
+// findLink returns the link with the given name under nd, looking it up
+// through the trie if nd is the root of a sharded directory.
+func (s *Resolver) findLink(nd *merkledag.Node, name string) (*merkledag.Link, error) {
+	if hamt.IsShard(nd) {
+		shard, err := hamt.NewHamtFromDag(s.DAG, nd)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Minute)
+		defer cancel()
+		return shard.Find(ctx, name)
+	}
+
+	// for each of the links in nd, the current object
+	for _, link := range nd.Links {
+		if link.Name == name {
+			return link, nil
+		}
+	}
+	return nil, merkledag.ErrNotFound
+}
@@ -25,7 +25,11 @@ type Config struct {
 	Bootstrap        []string              // local nodes's bootstrap peer addresses
 	Tour             Tour                  // local node's tour position
 	Gateway          Gateway               // local node's gateway server options
+	Ipns             Ipns                  // local node's ipns name options
+	Routing          Routing               // local node's routing options
+	Reprovider       Reprovider            // local node's reprovider options
 	SupernodeRouting SupernodeClientConfig // local node's routing servers (if SupernodeRouting enabled)
+	Swarm            Swarm                 // local node's swarm options
 	Log              Log
 }
 
@@ -4,4 +4,8 @@ package config
 type Gateway struct {
 	RootRedirect string
 	Writable     bool
+
+	// DelegatedRouting serves routing queries of other nodes, which use
+	// it as a "delegated" router.
+	DelegatedRouting bool
 }
@@ -71,6 +71,22 @@ func Init(out io.Writer, nBitsForKeypair int) (*Config, error) {
 			RootRedirect: "",
 			Writable:     false,
 		},
+
+		Ipns: Ipns{
+			RepublishPeriod: "12h",
+			RecordLifetime:  "24h",
+		},
+
+		Routing: Routing{
+			Type: "dht",
+		},
+
+		Reprovider: Reprovider{
+			Interval:  "12h",
+			Strategy:  "all",
+			BatchSize: 16,
+			RateLimit: 32,
+		},
 	}
 
 	return conf, nil
@@ -0,0 +1,12 @@
+package config
+
+// Ipns contains options for the names published by this node. Durations
+// are strings such as "12h"; empty ones take the defaults.
+type Ipns struct {
+	// How often the daemon publishes the names of this node again, so
+	// they do not expire. "0s" turns republishing off.
+	RepublishPeriod string
+
+	// How long published records stay valid
+	RecordLifetime string
+}
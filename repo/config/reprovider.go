@@ -0,0 +1,24 @@
+package config
+
+// Reprovider contains options for how the node announces the blocks it
+// can provide to the network.
+type Reprovider struct {
+	// How often the blocks are announced, such as "12h". "0s" turns
+	// periodic announcing off.
+	Interval string
+
+	// Strategy chooses the blocks to announce: "all", "pinned" (pinned
+	// blocks and the blocks under recursive pins), "roots" (the pins
+	// only), or "list" (the blocks in Keys).
+	Strategy string
+
+	// Keys lists the blocks to announce with the "list" strategy
+	Keys []string `json:",omitempty"`
+
+	// BatchSize is how many blocks are announced at once
+	BatchSize int
+
+	// RateLimit is the most blocks announced per second. 0 means no
+	// limit.
+	RateLimit int
+}
@@ -0,0 +1,34 @@
+package config
+
+// Routing defines configuration options for libp2p routing
+type Routing struct {
+	// Type sets default daemon routing mode: "dht", "dhtclient",
+	// "supernode", or "parallel" or "tiered" to use the routers in
+	// Routers. It is overridden by the daemon's --routing option.
+	Type string
+
+	// Routers are the routing systems used by the "parallel" and "tiered"
+	// types
+	Routers []Router `json:",omitempty"`
+}
+
+// Router describes one routing system of a composed one.
+type Router struct {
+	// Type is "dht", "dhtclient", "supernode", "offline" (records in the
+	// local datastore only), "delegated" (queries sent over HTTP to the
+	// node at Endpoint), or "parallel" or "tiered" to compose the
+	// routing systems in Routers. "parallel" asks all of them at once,
+	// "tiered" asks them in order until one finds what is looked for.
+	Type string
+
+	// Servers are the servers of a "supernode" router. Empty means the
+	// ones in SupernodeRouting.
+	Servers []string `json:",omitempty"`
+
+	// Endpoint is the HTTP address of the node serving a "delegated"
+	// router, such as "http://10.0.0.1:8080".
+	Endpoint string `json:",omitempty"`
+
+	// Routers are the routing systems of a "parallel" or "tiered" router
+	Routers []Router `json:",omitempty"`
+}
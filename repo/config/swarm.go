@@ -0,0 +1,9 @@
+package config
+
+// Swarm contains options for the connections to other peers.
+type Swarm struct {
+	// PrivateNetworkKey is the base64 encoded 32 byte key of the private
+	// network to join. The node then connects only to the peers that have
+	// the same key. Empty means the public network.
+	PrivateNetworkKey string `json:",omitempty"`
+}
@@ -0,0 +1,310 @@
+// package compose implements routing systems made of several others.
+package compose
+
+import (
+	"errors"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	eventlog "github.com/ipfs/go-ipfs/thirdparty/eventlog"
+	u "github.com/ipfs/go-ipfs/util"
+	pset "github.com/ipfs/go-ipfs/util/peerset"
+)
+
+var log = eventlog.Logger("routing/compose")
+
+// ErrNoRouters is returned when a composed routing system is made of none.
+var ErrNoRouters = errors.New("no routing systems to use")
+
+// Parallel is a routing system made of several others, which are all
+// asked at once. Values and peers come from the first one to find them,
+// and the providers found by all of them are merged. Values and provider
+// records are written to all of them.
+type Parallel []routing.IpfsRouting
+
+// Tiered is a routing system made of several others, which are asked in
+// order until one of them finds what is looked for, such as a local cache,
+// then a supernode, then the DHT. Values and provider records are written
+// to all of them.
+type Tiered []routing.IpfsRouting
+
+// Routers returns r and, if r is made of other routing systems, all of
+// those too.
+func Routers(r routing.IpfsRouting) []routing.IpfsRouting {
+	out := []routing.IpfsRouting{r}
+	var parts []routing.IpfsRouting
+	switch r := r.(type) {
+	case Parallel:
+		parts = r
+	case Tiered:
+		parts = r
+	}
+	for _, p := range parts {
+		out = append(out, Routers(p)...)
+	}
+	return out
+}
+
+// getFunc asks one routing system for something.
+type getFunc func(context.Context, routing.IpfsRouting) (interface{}, error)
+
+// notFound returns the error of a search that all routers failed.
+func notFound(ctx context.Context, errs []error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return ErrNoRouters
+	}
+	log.Debugf("all routers failed: %s", errs)
+	return routing.ErrNotFound
+}
+
+type getResult struct {
+	val interface{}
+	err error
+}
+
+// first returns what the first of routers to find it returns.
+func (p Parallel) first(ctx context.Context, get getFunc) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan getResult, len(p))
+	for _, r := range p {
+		go func(r routing.IpfsRouting) {
+			v, err := get(ctx, r)
+			results <- getResult{v, err}
+		}(r)
+	}
+
+	var errs []error
+	for _ = range p {
+		res := <-results
+		if res.err == nil {
+			return res.val, nil
+		}
+		errs = append(errs, res.err)
+	}
+	return nil, notFound(ctx, errs)
+}
+
+// first returns what the first of routers to find it returns.
+func (t Tiered) first(ctx context.Context, get getFunc) (interface{}, error) {
+	var errs []error
+	for _, r := range t {
+		v, err := get(ctx, r)
+		if err == nil {
+			return v, nil
+		}
+		errs = append(errs, err)
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, notFound(ctx, errs)
+}
+
+// putAll runs put on all of routers at once. It fails only if put fails
+// for every one of them.
+func putAll(ctx context.Context, routers []routing.IpfsRouting, put func(routing.IpfsRouting) error) error {
+	if len(routers) == 0 {
+		return ErrNoRouters
+	}
+
+	errs := make(chan error, len(routers))
+	for _, r := range routers {
+		go func(r routing.IpfsRouting) {
+			errs <- put(r)
+		}(r)
+	}
+
+	var firstErr error
+	ok := false
+	for _ = range routers {
+		if err := <-errs; err != nil {
+			log.Debugf("router failed: %s", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			ok = true
+		}
+	}
+	if ok {
+		return nil
+	}
+	return firstErr
+}
+
+// bootstrapAll bootstraps all of routers, and returns the first error.
+func bootstrapAll(ctx context.Context, routers []routing.IpfsRouting) error {
+	var firstErr error
+	for _, r := range routers {
+		if err := r.Bootstrap(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// sendProviders sends the providers in in that are not in ps yet on out,
+// until ps holds count of them. It returns false once it does, or ctx is
+// done.
+func sendProviders(ctx context.Context, in <-chan peer.PeerInfo, out chan<- peer.PeerInfo, ps *pset.PeerSet, count int) bool {
+	for pi := range in {
+		if !ps.TryAdd(pi.ID) {
+			continue
+		}
+		select {
+		case out <- pi:
+		case <-ctx.Done():
+			return false
+		}
+		if ps.Size() >= count {
+			return false
+		}
+	}
+	return ctx.Err() == nil
+}
+
+func (p Parallel) PutValue(ctx context.Context, k u.Key, val []byte) error {
+	return putAll(ctx, p, func(r routing.IpfsRouting) error {
+		return r.PutValue(ctx, k, val)
+	})
+}
+
+func (p Parallel) GetValue(ctx context.Context, k u.Key) ([]byte, error) {
+	v, err := p.first(ctx, func(ctx context.Context, r routing.IpfsRouting) (interface{}, error) {
+		return r.GetValue(ctx, k)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+func (p Parallel) Provide(ctx context.Context, k u.Key) error {
+	return putAll(ctx, p, func(r routing.IpfsRouting) error {
+		return r.Provide(ctx, k)
+	})
+}
+
+func (p Parallel) FindProvidersAsync(ctx context.Context, k u.Key, count int) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo)
+	ctx, cancel := context.WithCancel(ctx)
+	ps := pset.NewLimited(count)
+
+	done := make(chan struct{}, len(p))
+	for _, r := range p {
+		go func(r routing.IpfsRouting) {
+			if !sendProviders(ctx, r.FindProvidersAsync(ctx, k, count), out, ps, count) {
+				cancel()
+			}
+			done <- struct{}{}
+		}(r)
+	}
+
+	go func() {
+		defer close(out)
+		defer cancel()
+		for _ = range p {
+			<-done
+		}
+	}()
+	return out
+}
+
+func (p Parallel) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	v, err := p.first(ctx, func(ctx context.Context, r routing.IpfsRouting) (interface{}, error) {
+		return r.FindPeer(ctx, id)
+	})
+	if err != nil {
+		return peer.PeerInfo{}, err
+	}
+	return v.(peer.PeerInfo), nil
+}
+
+func (p Parallel) Ping(ctx context.Context, id peer.ID) (time.Duration, error) {
+	v, err := p.first(ctx, func(ctx context.Context, r routing.IpfsRouting) (interface{}, error) {
+		return r.Ping(ctx, id)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(time.Duration), nil
+}
+
+func (p Parallel) Bootstrap(ctx context.Context) error {
+	return bootstrapAll(ctx, p)
+}
+
+func (t Tiered) PutValue(ctx context.Context, k u.Key, val []byte) error {
+	return putAll(ctx, t, func(r routing.IpfsRouting) error {
+		return r.PutValue(ctx, k, val)
+	})
+}
+
+func (t Tiered) GetValue(ctx context.Context, k u.Key) ([]byte, error) {
+	v, err := t.first(ctx, func(ctx context.Context, r routing.IpfsRouting) (interface{}, error) {
+		return r.GetValue(ctx, k)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
+
+func (t Tiered) Provide(ctx context.Context, k u.Key) error {
+	return putAll(ctx, t, func(r routing.IpfsRouting) error {
+		return r.Provide(ctx, k)
+	})
+}
+
+func (t Tiered) FindProvidersAsync(ctx context.Context, k u.Key, count int) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo)
+	go func() {
+		defer close(out)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ps := pset.NewLimited(count)
+		for _, r := range t {
+			if !sendProviders(ctx, r.FindProvidersAsync(ctx, k, count), out, ps, count) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (t Tiered) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	v, err := t.first(ctx, func(ctx context.Context, r routing.IpfsRouting) (interface{}, error) {
+		return r.FindPeer(ctx, id)
+	})
+	if err != nil {
+		return peer.PeerInfo{}, err
+	}
+	return v.(peer.PeerInfo), nil
+}
+
+func (t Tiered) Ping(ctx context.Context, id peer.ID) (time.Duration, error) {
+	v, err := t.first(ctx, func(ctx context.Context, r routing.IpfsRouting) (interface{}, error) {
+		return r.Ping(ctx, id)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(time.Duration), nil
+}
+
+func (t Tiered) Bootstrap(ctx context.Context) error {
+	return bootstrapAll(ctx, t)
+}
+
+// ensure the composed routers match the IpfsRouting interface
+var _ routing.IpfsRouting = Parallel{}
+var _ routing.IpfsRouting = Tiered{}
@@ -0,0 +1,169 @@
+package compose
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	mockrouting "github.com/ipfs/go-ipfs/routing/mock"
+	u "github.com/ipfs/go-ipfs/util"
+	testutil "github.com/ipfs/go-ipfs/util/testutil"
+)
+
+var errBroken = errors.New("broken router")
+
+// broken is a routing system that fails everything.
+type broken struct{}
+
+func (broken) PutValue(context.Context, u.Key, []byte) error        { return errBroken }
+func (broken) GetValue(context.Context, u.Key) ([]byte, error)      { return nil, errBroken }
+func (broken) Provide(context.Context, u.Key) error                 { return errBroken }
+func (broken) Ping(context.Context, peer.ID) (time.Duration, error) { return 0, errBroken }
+func (broken) Bootstrap(context.Context) error                      { return nil }
+
+func (broken) FindProvidersAsync(context.Context, u.Key, int) <-chan peer.PeerInfo {
+	out := make(chan peer.PeerInfo)
+	close(out)
+	return out
+}
+
+func (broken) FindPeer(context.Context, peer.ID) (peer.PeerInfo, error) {
+	return peer.PeerInfo{}, errBroken
+}
+
+func TestGetValue(t *testing.T) {
+	ctx := context.Background()
+	id := testutil.RandIdentityOrFatal(t)
+	a := mockrouting.NewServer().Client(id)
+	b := mockrouting.NewServer().Client(id)
+
+	if err := a.PutValue(ctx, "/v/one", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []routing.IpfsRouting{a, b} {
+		if err := r.PutValue(ctx, "/v/two", []byte("b")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, r := range []routing.IpfsRouting{
+		Tiered{broken{}, b, a},
+		Parallel{broken{}, b, a},
+	} {
+		val, err := r.GetValue(ctx, "/v/one")
+		if err != nil {
+			t.Fatalf("%T: %s", r, err)
+		}
+		if string(val) != "a" {
+			t.Fatalf("%T: expected 'a' got '%s'", r, val)
+		}
+
+		if _, err := r.GetValue(ctx, "/v/none"); err != routing.ErrNotFound {
+			t.Fatalf("%T: expected ErrNotFound, got %v", r, err)
+		}
+	}
+
+	// the first tier to have it wins
+	val, err := Tiered{b, a}.GetValue(ctx, "/v/two")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "b" {
+		t.Fatalf("expected 'b' got '%s'", val)
+	}
+}
+
+func TestPutValue(t *testing.T) {
+	ctx := context.Background()
+	id := testutil.RandIdentityOrFatal(t)
+	a := mockrouting.NewServer().Client(id)
+	b := mockrouting.NewServer().Client(id)
+
+	// written to all, even when some fail
+	for _, r := range []routing.IpfsRouting{
+		Tiered{a, broken{}, b},
+		Parallel{a, broken{}, b},
+	} {
+		if err := r.PutValue(ctx, "/v/hello", []byte("world")); err != nil {
+			t.Fatalf("%T: %s", r, err)
+		}
+		for _, c := range []routing.IpfsRouting{a, b} {
+			val, err := c.GetValue(ctx, "/v/hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(val) != "world" {
+				t.Fatalf("expected 'world' got '%s'", val)
+			}
+		}
+	}
+
+	if err := (Parallel{broken{}, broken{}}).PutValue(ctx, "/v/hello", nil); err != errBroken {
+		t.Fatalf("expected errBroken, got %v", err)
+	}
+	if err := (Tiered{}).Provide(ctx, "foo"); err != ErrNoRouters {
+		t.Fatalf("expected ErrNoRouters, got %v", err)
+	}
+}
+
+func TestFindProviders(t *testing.T) {
+	ctx := context.Background()
+	sa := mockrouting.NewServer()
+	sb := mockrouting.NewServer()
+
+	k := u.Key("foo")
+	idA := testutil.RandIdentityOrFatal(t)
+	idB := testutil.RandIdentityOrFatal(t)
+	idC := testutil.RandIdentityOrFatal(t)
+
+	// A is known to both, B and C to one each
+	for _, p := range []struct {
+		s  mockrouting.Server
+		id testutil.Identity
+	}{{sa, idA}, {sb, idA}, {sa, idB}, {sb, idC}} {
+		if err := p.s.Client(p.id).Provide(ctx, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	me := testutil.RandIdentityOrFatal(t)
+	a := sa.Client(me)
+	b := sb.Client(me)
+
+	for _, r := range []routing.IpfsRouting{
+		Tiered{a, broken{}, b},
+		Parallel{a, broken{}, b},
+	} {
+		found := make(map[peer.ID]int)
+		for pi := range r.FindProvidersAsync(ctx, k, 10) {
+			found[pi.ID]++
+		}
+		if len(found) != 3 {
+			t.Fatalf("%T: expected 3 providers, got %d", r, len(found))
+		}
+		for _, id := range []testutil.Identity{idA, idB, idC} {
+			if found[id.ID()] != 1 {
+				t.Fatalf("%T: %s found %d times", r, id.ID(), found[id.ID()])
+			}
+		}
+
+		n := 0
+		for _ = range r.FindProvidersAsync(ctx, k, 2) {
+			n++
+		}
+		if n != 2 {
+			t.Fatalf("%T: expected 2 providers, got %d", r, n)
+		}
+	}
+}
+
+func TestRouters(t *testing.T) {
+	a := mockrouting.NewServer().Client(testutil.RandIdentityOrFatal(t))
+	r := Tiered{broken{}, Parallel{a, broken{}}}
+	if n := len(Routers(r)); n != 5 {
+		t.Fatalf("expected 5 routers, got %d", n)
+	}
+}
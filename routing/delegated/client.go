@@ -0,0 +1,284 @@
+// package delegated implements a routing system that hands all queries to
+// a remote node over plain HTTP, for nodes that cannot reach the network
+// themselves, such as ones behind an HTTP proxy. The remote node serves
+// them with corehttp.DelegatedRoutingOption.
+//
+// Values returned by the remote node are checked with the Validator of the
+// Client, as the DHT checks the values it finds, and the remote node checks
+// the values it is given to store in the same way. The peers it returns
+// cannot be checked, and are trusted.
+package delegated
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
+	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	routing "github.com/ipfs/go-ipfs/routing"
+	pb "github.com/ipfs/go-ipfs/routing/dht/pb"
+	record "github.com/ipfs/go-ipfs/routing/record"
+	eventlog "github.com/ipfs/go-ipfs/thirdparty/eventlog"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+var log = eventlog.Logger("routing/delegated")
+
+// HTTPPath is where a node serves delegated routing requests. Below it
+// are:
+//
+//	GET providers/<key>?count=<n>  the providers of key, as a stream of
+//	                               JSON peer infos
+//	GET peers/<peer>               the JSON peer info of peer
+//	GET values/<key>               the value of key
+//	PUT values/<key>               stores the request body as the value
+//	                               of key
+//
+// Keys are base58 encoded. Lookups that find nothing answer 404.
+const HTTPPath = "/routing/v0/"
+
+// MaxValueSize bounds the size of the values stored and returned.
+const MaxValueSize = 1 << 20
+
+// ErrNotSupported is returned for the queries a remote node cannot run on
+// behalf of another.
+var ErrNotSupported = errors.New("not supported by delegated routing")
+
+// Client is a routing system asking a remote node over HTTP.
+type Client struct {
+	endpoint string
+	http     *http.Client
+
+	// Validator checks the values GetValue returns. Values of keys in
+	// namespaces it has no checker for are rejected.
+	Validator record.Validator
+}
+
+// NewClient returns a Client asking the node serving HTTP at endpoint, such
+// as "http://10.0.0.1:8080". Requests go through the proxy set in the
+// environment, if any.
+func NewClient(endpoint string) (*Client, error) {
+	ep, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if ep.Scheme != "http" && ep.Scheme != "https" {
+		return nil, fmt.Errorf("not an http endpoint: %s", endpoint)
+	}
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/") + HTTPPath,
+		http:     &http.Client{},
+		Validator: record.Validator{
+			"pk": record.PublicKeyValidator,
+		},
+	}, nil
+}
+
+// PeerInfo is the JSON form of a peer.PeerInfo in requests and responses.
+type PeerInfo struct {
+	ID    string
+	Addrs []string
+}
+
+// NewPeerInfo returns the JSON form of pi.
+func NewPeerInfo(pi peer.PeerInfo) PeerInfo {
+	out := PeerInfo{ID: pi.ID.Pretty()}
+	for _, a := range pi.Addrs {
+		out.Addrs = append(out.Addrs, a.String())
+	}
+	return out
+}
+
+// PeerInfo returns the peer.PeerInfo of pi.
+func (pi PeerInfo) PeerInfo() (peer.PeerInfo, error) {
+	id, err := peer.IDB58Decode(pi.ID)
+	if err != nil {
+		return peer.PeerInfo{}, err
+	}
+	out := peer.PeerInfo{ID: id}
+	for _, s := range pi.Addrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return peer.PeerInfo{}, err
+		}
+		out.Addrs = append(out.Addrs, a)
+	}
+	return out, nil
+}
+
+// do sends a request to path below the endpoint, and returns the response
+// if its status is 200.
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		res *http.Response
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := c.http.Do(req)
+		done <- result{res, err}
+	}()
+
+	var res *http.Response
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		res = r.res
+	case <-ctx.Done():
+		go func() {
+			// close the response when it comes
+			if r := <-done; r.err == nil {
+				r.res.Body.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, routing.ErrNotFound
+	default:
+		defer res.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("delegated routing: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+}
+
+// closeOnDone closes res once ctx is done, to stop reading it. The returned
+// func stops waiting.
+func closeOnDone(ctx context.Context, res *http.Response) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			res.Body.Close()
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+func (c *Client) FindProvidersAsync(ctx context.Context, k u.Key, max int) <-chan peer.PeerInfo {
+	defer log.EventBegin(ctx, "findProviders", &k).Done()
+	ch := make(chan peer.PeerInfo)
+	go func() {
+		defer close(ch)
+		path := "providers/" + k.B58String() + "?count=" + strconv.Itoa(max)
+		res, err := c.do(ctx, "GET", path, nil)
+		if err != nil {
+			log.Debug(err)
+			return
+		}
+		defer res.Body.Close()
+		defer closeOnDone(ctx, res)()
+
+		dec := json.NewDecoder(res.Body)
+		for {
+			var jpi PeerInfo
+			if err := dec.Decode(&jpi); err != nil {
+				if err != io.EOF {
+					log.Debug(err)
+				}
+				return
+			}
+			pi, err := jpi.PeerInfo()
+			if err != nil {
+				log.Debugf("bad provider: %s", err)
+				continue
+			}
+
+			select {
+			case ch <- pi:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (c *Client) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, error) {
+	defer log.EventBegin(ctx, "findPeer", id).Done()
+	res, err := c.do(ctx, "GET", "peers/"+id.Pretty(), nil)
+	if err != nil {
+		return peer.PeerInfo{}, err
+	}
+	defer res.Body.Close()
+	defer closeOnDone(ctx, res)()
+
+	var jpi PeerInfo
+	if err := json.NewDecoder(res.Body).Decode(&jpi); err != nil {
+		return peer.PeerInfo{}, err
+	}
+	return jpi.PeerInfo()
+}
+
+func (c *Client) GetValue(ctx context.Context, k u.Key) ([]byte, error) {
+	defer log.EventBegin(ctx, "getValue", &k).Done()
+	res, err := c.do(ctx, "GET", "values/"+k.B58String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	defer closeOnDone(ctx, res)()
+
+	val, err := ioutil.ReadAll(io.LimitReader(res.Body, MaxValueSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(val) > MaxValueSize {
+		return nil, fmt.Errorf("value for %s is larger than %d bytes", k, MaxValueSize)
+	}
+
+	rec := &pb.Record{Key: proto.String(string(k)), Value: val}
+	if err := c.Validator.VerifyRecord(rec); err != nil {
+		log.Debugf("bad value for %s from %s: %s", k, c.endpoint, err)
+		return nil, err
+	}
+	return val, nil
+}
+
+func (c *Client) PutValue(ctx context.Context, k u.Key, v []byte) error {
+	defer log.EventBegin(ctx, "putValue", &k).Done()
+	res, err := c.do(ctx, "PUT", "values/"+k.B58String(), strings.NewReader(string(v)))
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Provide is not supported: the remote node can only announce itself.
+func (c *Client) Provide(context.Context, u.Key) error {
+	return ErrNotSupported
+}
+
+// Ping is not supported.
+func (c *Client) Ping(context.Context, peer.ID) (time.Duration, error) {
+	return 0, ErrNotSupported
+}
+
+func (c *Client) Bootstrap(context.Context) error {
+	return nil
+}
+
+var _ routing.IpfsRouting = &Client{}
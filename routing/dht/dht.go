@@ -56,11 +56,28 @@ type IpfsDHT struct {
 
 	Validator record.Validator // record validator funcs
 
+	clientOnly bool // queries the network, but does not answer
+
 	ctxgroup.ContextGroup
 }
 
 // NewDHT creates a new DHT object with the given peer as the 'local' host
 func NewDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *IpfsDHT {
+	dht := newDHT(ctx, h, dstore)
+	h.SetStreamHandler(ProtocolDHT, dht.handleNewStream)
+	return dht
+}
+
+// NewDHTClient creates a DHT that only issues queries. It does not serve
+// the DHT protocol, so peers learn through identify that it is a client,
+// and keep it out of their routing tables.
+func NewDHTClient(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *IpfsDHT {
+	dht := newDHT(ctx, h, dstore)
+	dht.clientOnly = true
+	return dht
+}
+
+func newDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *IpfsDHT {
 	dht := new(IpfsDHT)
 	dht.datastore = dstore
 	dht.self = h.ID()
@@ -76,8 +93,7 @@ func NewDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *Ip
 		return nil
 	})
 
-	h.SetStreamHandler(ProtocolDHT, dht.handleNewStream)
-	dht.providers = NewProviderManager(dht.Context(), dht.self)
+	dht.providers = NewProviderManager(dht.Context(), dht.self, dstore)
 	dht.AddChild(dht.providers)
 
 	dht.routingTable = kb.NewRoutingTable(20, kb.ConvertPeerID(dht.self), time.Minute, dht.peerstore)
@@ -93,6 +109,11 @@ func NewDHT(ctx context.Context, h host.Host, dstore ds.ThreadSafeDatastore) *Ip
 	return dht
 }
 
+// ClientOnly returns whether the dht only issues queries.
+func (dht *IpfsDHT) ClientOnly() bool {
+	return dht.clientOnly
+}
+
 // LocalPeer returns the peer.Peer of the dht.
 func (dht *IpfsDHT) LocalPeer() peer.ID {
 	return dht.self
@@ -166,11 +187,11 @@ func (dht *IpfsDHT) putProvider(ctx context.Context, p peer.ID, key string) erro
 }
 
 // getValueOrPeers queries a particular peer p for the value for
-// key. It returns either the value or a list of closer peers.
+// key. It returns either the record or a list of closer peers.
 // NOTE: it will update the dht's peerstore with any new addresses
 // it finds for the given peer.
 func (dht *IpfsDHT) getValueOrPeers(ctx context.Context, p peer.ID,
-	key u.Key) ([]byte, []peer.PeerInfo, error) {
+	key u.Key) (*pb.Record, []peer.PeerInfo, error) {
 
 	pmes, err := dht.getValueSingle(ctx, p, key)
 	if err != nil {
@@ -187,7 +208,10 @@ func (dht *IpfsDHT) getValueOrPeers(ctx context.Context, p peer.ID,
 			log.Info("Received invalid record! (discarded)")
 			return nil, nil, err
 		}
-		return record.GetValue(), nil, nil
+
+		// pass on closer peers too, for queries after more than one value
+		peers := pb.PBPeersToPeerInfos(pmes.GetCloserPeers())
+		return record, peers, nil
 	}
 
 	// Perhaps we were given closer peers
@@ -212,6 +236,15 @@ func (dht *IpfsDHT) getValueSingle(ctx context.Context, p peer.ID,
 
 // getLocal attempts to retrieve the value from the datastore
 func (dht *IpfsDHT) getLocal(key u.Key) ([]byte, error) {
+	rec, err := dht.getLocalRecord(key)
+	if err != nil {
+		return nil, err
+	}
+	return rec.GetValue(), nil
+}
+
+// getLocalRecord attempts to retrieve the record from the datastore
+func (dht *IpfsDHT) getLocalRecord(key u.Key) (*pb.Record, error) {
 
 	log.Debug("getLocal %s", key)
 	v, err := dht.datastore.Get(key.DsKey())
@@ -239,7 +272,7 @@ func (dht *IpfsDHT) getLocal(key u.Key) ([]byte, error) {
 		}
 	}
 
-	return rec.GetValue(), nil
+	return rec, nil
 }
 
 // getOwnPrivateKey attempts to load the local peers private
@@ -266,10 +299,34 @@ func (dht *IpfsDHT) putLocal(key u.Key, rec *pb.Record) error {
 // Update signals the routingTable to Update its last-seen status
 // on the given peer.
 func (dht *IpfsDHT) Update(ctx context.Context, p peer.ID) {
+	if dht.isClient(p) {
+		// nobody should ask it anything
+		dht.routingTable.Remove(p)
+		return
+	}
 	log.Event(ctx, "updatePeer", p)
 	dht.routingTable.Update(p)
 }
 
+// isClient returns whether p told us through identify that it does not
+// serve the dht. Peers not identified yet are taken to serve it.
+func (dht *IpfsDHT) isClient(p peer.ID) bool {
+	v, err := dht.peerstore.Get(p, "Protocols")
+	if err != nil {
+		return false
+	}
+	protos, ok := v.([]string)
+	if !ok || len(protos) == 0 {
+		return false
+	}
+	for _, proto := range protos {
+		if proto == string(ProtocolDHT) {
+			return false
+		}
+	}
+	return true
+}
+
 // FindLocal looks for a peer with a given ID connected to this dht and returns the peer and the table it was found in.
 func (dht *IpfsDHT) FindLocal(id peer.ID) peer.PeerInfo {
 	p := dht.routingTable.Find(id)
@@ -92,6 +92,10 @@ func (dht *IpfsDHT) sendRequest(ctx context.Context, p peer.ID, pmes *pb.Message
 
 	rpmes := new(pb.Message)
 	if err := r.ReadMsg(rpmes); err != nil {
+		if dht.isClient(p) {
+			// it got in before it was identified
+			dht.routingTable.Remove(p)
+		}
 		return nil, err
 	}
 	if rpmes == nil {
@@ -2,9 +2,11 @@ package dht
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math/rand"
 	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -14,9 +16,11 @@ import (
 	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
+	notif "github.com/ipfs/go-ipfs/notifications"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	netutil "github.com/ipfs/go-ipfs/p2p/test/util"
 	routing "github.com/ipfs/go-ipfs/routing"
+	pb "github.com/ipfs/go-ipfs/routing/dht/pb"
 	record "github.com/ipfs/go-ipfs/routing/record"
 	u "github.com/ipfs/go-ipfs/util"
 
@@ -177,6 +181,390 @@ func TestValueGetSet(t *testing.T) {
 	}
 }
 
+func TestGetValueSelects(t *testing.T) {
+	ctx := context.Background()
+
+	_, _, dhts := setupDHTS(ctx, 3, t)
+	defer func() {
+		for i := 0; i < 3; i++ {
+			dhts[i].Close()
+			defer dhts[i].host.Close()
+		}
+	}()
+
+	// the greatest value is the best
+	vf := &record.ValidChecker{
+		Func: func(u.Key, []byte) error {
+			return nil
+		},
+		Sign: false,
+		Selector: record.SelectorFunc(func(_ u.Key, vals [][]byte) (int, error) {
+			best := 0
+			for i, v := range vals {
+				if string(v) > string(vals[best]) {
+					best = i
+				}
+			}
+			return best, nil
+		}),
+	}
+	for _, d := range dhts {
+		d.Validator["s"] = vf
+	}
+
+	connect(t, ctx, dhts[0], dhts[1])
+	connect(t, ctx, dhts[0], dhts[2])
+
+	k := u.Key("/s/hello")
+	for i, v := range []string{"2", "1", "3"} {
+		sk := dhts[i].peerstore.PrivKey(dhts[i].self)
+		rec, err := record.MakePutRecord(sk, k, []byte(v), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dhts[i].putLocal(k, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctxT, _ := context.WithTimeout(ctx, time.Second*2)
+	val, err := dhts[0].GetValue(ctxT, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "3" {
+		t.Fatalf("Expected '3' got '%s'", string(val))
+	}
+
+	// peers that had worse records are given the best one
+	for _, d := range dhts[:2] {
+		for i := 0; ; i++ {
+			val, err := d.getLocal(k)
+			if err == nil && string(val) == "3" {
+				break
+			}
+			if i > 100 {
+				t.Fatalf("%s still has '%s'", d.self, val)
+			}
+			time.Sleep(time.Millisecond * 10)
+		}
+	}
+
+	// a worse record does not replace a better one
+	sk := dhts[0].peerstore.PrivKey(dhts[0].self)
+	rec, err := record.MakePutRecord(sk, k, []byte("0"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxT, _ = context.WithTimeout(ctx, time.Second*2)
+	if err := dhts[0].putValueToPeer(ctxT, dhts[2].self, k, rec); err != nil {
+		t.Fatal(err)
+	}
+	val, err = dhts[2].getLocal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "3" {
+		t.Fatalf("Expected '3' to be kept, got '%s'", string(val))
+	}
+}
+
+func TestCorrectStaleNeedsVerifiedRecord(t *testing.T) {
+	ctx := context.Background()
+
+	_, _, dhts := setupDHTS(ctx, 2, t)
+	defer func() {
+		for i := 0; i < 2; i++ {
+			dhts[i].Close()
+			defer dhts[i].host.Close()
+		}
+	}()
+
+	vf := &record.ValidChecker{
+		Func: func(u.Key, []byte) error {
+			return nil
+		},
+		Sign: false,
+		Selector: record.SelectorFunc(func(_ u.Key, vals [][]byte) (int, error) {
+			best := 0
+			for i, v := range vals {
+				if string(v) > string(vals[best]) {
+					best = i
+				}
+			}
+			return best, nil
+		}),
+	}
+	for _, d := range dhts {
+		d.Validator["s"] = vf
+	}
+
+	connect(t, ctx, dhts[0], dhts[1])
+
+	k := u.Key("/s/hello")
+	var recvd []recvdRecord
+	for _, d := range dhts {
+		sk := d.peerstore.PrivKey(d.self)
+		rec, err := record.MakePutRecord(sk, k, []byte("1"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.putLocal(k, rec); err != nil {
+			t.Fatal(err)
+		}
+		recvd = append(recvd, recvdRecord{rec: rec, from: d.self})
+	}
+
+	// a better record that was tampered with, as could be read from the
+	// local datastore, which is not checked on reads
+	sk := dhts[0].peerstore.PrivKey(dhts[0].self)
+	best, err := record.MakePutRecord(sk, k, []byte("2"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	best.Signature[0] ^= 0xff
+	dhts[0].correctStale(k, best, recvd)
+
+	for _, d := range dhts {
+		val, err := d.getLocal(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != "1" {
+			t.Fatalf("Expected '1' to be kept at %s, got '%s'", d.self, string(val))
+		}
+	}
+}
+
+func TestGetValueSkipsForgedValues(t *testing.T) {
+	ctx := context.Background()
+
+	_, _, dhts := setupDHTS(ctx, 3, t)
+	defer func() {
+		for i := 0; i < 3; i++ {
+			dhts[i].Close()
+			defer dhts[i].host.Close()
+		}
+	}()
+
+	// values are "seq|sig", where sig signs seq with the key of the peer
+	// named in the key, and the greatest seq is the best
+	vf := &record.ValidChecker{
+		Func: func(u.Key, []byte) error {
+			return nil
+		},
+		Sign: false,
+		Selector: record.SelectorFunc(func(_ u.Key, vals [][]byte) (int, error) {
+			best := 0
+			for i, v := range vals {
+				if string(v) > string(vals[best]) {
+					best = i
+				}
+			}
+			return best, nil
+		}),
+		SignedValue: func(k u.Key, val []byte) (peer.ID, []byte, []byte, error) {
+			parts := bytes.SplitN(val, []byte("|"), 2)
+			if len(parts) != 2 {
+				return "", nil, nil, errors.New("bad value")
+			}
+			return peer.ID(strings.TrimPrefix(string(k), "/s/")), parts[0], parts[1], nil
+		},
+	}
+	for _, d := range dhts {
+		d.Validator["s"] = vf
+	}
+
+	connect(t, ctx, dhts[0], dhts[1])
+	connect(t, ctx, dhts[0], dhts[2])
+	connect(t, ctx, dhts[1], dhts[2])
+
+	// dhts[1] owns the key, dhts[2] forges a value with a higher seq
+	owner := dhts[1]
+	k := u.Key("/s/" + string(owner.self))
+	makeRec := func(d *IpfsDHT, seq string) *pb.Record {
+		sk := d.peerstore.PrivKey(d.self)
+		sig, err := sk.Sign([]byte(seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec, err := record.MakePutRecord(sk, k, append([]byte(seq+"|"), sig...), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rec
+	}
+	real := makeRec(owner, "1")
+	forged := makeRec(dhts[2], "9")
+	if err := owner.putLocal(k, real); err != nil {
+		t.Fatal(err)
+	}
+	if err := dhts[2].putLocal(k, forged); err != nil {
+		t.Fatal(err)
+	}
+
+	ctxT, _ := context.WithTimeout(ctx, time.Second*2)
+	val, err := dhts[0].GetValue(ctxT, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val, real.GetValue()) {
+		t.Fatalf("Expected the owner's value, got '%s'", val)
+	}
+
+	// a forged value does not replace the owner's one
+	ctxT, _ = context.WithTimeout(ctx, time.Second*2)
+	if err := dhts[2].putValueToPeer(ctxT, owner.self, k, forged); err != nil {
+		t.Fatal(err)
+	}
+	val, err = owner.getLocal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(val, real.GetValue()) {
+		t.Fatalf("Expected the owner's value to be kept, got '%s'", val)
+	}
+}
+
+func TestQueryEvents(t *testing.T) {
+	ctx := context.Background()
+
+	dhtA := setupDHT(ctx, t)
+	dhtB := setupDHT(ctx, t)
+
+	defer dhtA.Close()
+	defer dhtB.Close()
+	defer dhtA.host.Close()
+	defer dhtB.host.Close()
+
+	vf := &record.ValidChecker{
+		Func: func(u.Key, []byte) error {
+			return nil
+		},
+		Sign: false,
+	}
+	dhtA.Validator["v"] = vf
+	dhtB.Validator["v"] = vf
+
+	k := u.Key("/v/hello")
+	sk := dhtB.peerstore.PrivKey(dhtB.self)
+	rec, err := record.MakePutRecord(sk, k, []byte("world"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dhtB.putLocal(k, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	// dhtA knows dhtB, but has to dial it again
+	connect(t, ctx, dhtA, dhtB)
+	if err := dhtA.host.Network().ClosePeer(dhtB.self); err != nil {
+		t.Fatal(err)
+	}
+	for dhtA.routingTable.Find(dhtB.self) != "" {
+		time.Sleep(time.Millisecond * 10)
+	}
+	dhtA.routingTable.Update(dhtB.self)
+
+	events := make(chan *notif.QueryEvent)
+	done := make(chan struct{})
+	var got []*notif.QueryEvent
+	go func() {
+		defer close(done)
+		for e := range events {
+			got = append(got, e)
+		}
+	}()
+
+	start := time.Now()
+	ctxT, _ := context.WithTimeout(ctx, time.Second*2)
+	val, err := dhtA.GetValue(notif.RegisterForQueryEvents(ctxT, events), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "world" {
+		t.Fatalf("Expected 'world' got '%s'", string(val))
+	}
+	close(events)
+	<-done
+
+	var types []notif.QueryEventType
+	for _, e := range got {
+		if e.ID != dhtB.self {
+			t.Fatalf("event %d about the wrong peer: %s", e.Type, e.ID)
+		}
+		if e.Time.Before(start) {
+			t.Fatalf("event %d has a bad time: %s", e.Type, e.Time)
+		}
+		if e.Type == notif.FoundValue && e.Duration <= 0 {
+			t.Fatalf("event %d has a bad duration: %s", e.Type, e.Duration)
+		}
+		types = append(types, e.Type)
+	}
+
+	expect := []notif.QueryEventType{
+		notif.DialingPeer,
+		notif.SendingQuery,
+		notif.FoundValue,
+		notif.PeerResponse,
+	}
+	if fmt.Sprint(types) != fmt.Sprint(expect) {
+		t.Fatalf("expected events %v, got %v", expect, types)
+	}
+}
+
+func TestClientOnly(t *testing.T) {
+	ctx := context.Background()
+
+	server := setupDHT(ctx, t)
+	h := netutil.GenHostSwarm(t, ctx)
+	client := NewDHTClient(ctx, h, dssync.MutexWrap(ds.NewMapDatastore()))
+	client.Validator["v"] = server.Validator["v"]
+	defer func() {
+		for _, d := range []*IpfsDHT{server, client} {
+			d.Close()
+			defer d.host.Close()
+		}
+	}()
+
+	connect(t, ctx, client, server)
+
+	// the client is dropped from the server's table once identified
+	for i := 0; server.routingTable.Find(client.self) != ""; i++ {
+		if i > 100 {
+			t.Fatal("client still in the server's routing table")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if client.routingTable.Find(server.self) == "" {
+		t.Fatal("server not in the client's routing table")
+	}
+
+	// the client can still query
+	ctxT, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	sk := server.peerstore.PrivKey(server.self)
+	rec, err := record.MakePutRecord(sk, u.Key("/v/hello"), []byte("world"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.putLocal(u.Key("/v/hello"), rec); err != nil {
+		t.Fatal(err)
+	}
+	val, err := client.GetValue(ctxT, u.Key("/v/hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(val) != "world" {
+		t.Fatalf("expected world, got %q", val)
+	}
+
+	// and nobody can ask it anything
+	if _, err := server.Ping(ctxT, client.self); err == nil {
+		t.Fatal("client answered a ping")
+	}
+}
+
 func TestProvides(t *testing.T) {
 	// t.Skip("skipping test to debug another")
 	ctx := context.Background()
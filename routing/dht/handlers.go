@@ -112,6 +112,20 @@ func (dht *IpfsDHT) handlePutValue(ctx context.Context, p peer.ID, pmes *pb.Mess
 		return nil, err
 	}
 
+	// do not replace a better record with a worse one, or with one not
+	// signed by the owner of the key. Public keys are not searched for here:
+	// puts could make us search the network for keys at will.
+	key := u.Key(pmes.GetKey())
+	if dht.Validator.Selects(key) {
+		if old, err := dht.getLocal(key); err == nil {
+			vals := [][]byte{pmes.GetRecord().GetValue(), old}
+			if i, err := dht.Validator.Select(key, vals, dht.localPublicKey); err == nil && i != 0 {
+				log.Debugf("%s handlePutValue %v: kept the record we have", dht.self, dskey)
+				return pmes, nil
+			}
+		}
+	}
+
 	data, err := proto.Marshal(pmes.GetRecord())
 	if err != nil {
 		return nil, err
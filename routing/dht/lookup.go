@@ -2,7 +2,6 @@ package dht
 
 import (
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
-	notif "github.com/ipfs/go-ipfs/notifications"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	kb "github.com/ipfs/go-ipfs/routing/kbucket"
 	u "github.com/ipfs/go-ipfs/util"
@@ -41,12 +40,6 @@ func (dht *IpfsDHT) GetClosestPeers(ctx context.Context, key u.Key) (<-chan peer
 	}
 
 	query := dht.newQuery(key, func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		// For DHT query command
-		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-			Type: notif.SendingQuery,
-			ID:   p,
-		})
-
 		closer, err := dht.closerPeersSingle(ctx, key, p)
 		if err != nil {
 			log.Debugf("error getting closer peers: %s", err)
@@ -65,13 +58,6 @@ func (dht *IpfsDHT) GetClosestPeers(ctx context.Context, key u.Key) (<-chan peer
 			}
 		}
 
-		// For DHT query command
-		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-			Type:      notif.PeerResponse,
-			ID:        p,
-			Responses: pointerizePeerInfos(filtered),
-		})
-
 		return &dhtQueryResult{closerPeers: filtered}, nil
 	})
 
@@ -4,6 +4,7 @@ import (
 	ma "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-multiaddr"
 
 	inet "github.com/ipfs/go-ipfs/p2p/net"
+	identify "github.com/ipfs/go-ipfs/p2p/protocol/identify"
 )
 
 // netNotifiee defines methods to be used with the IpfsDHT
@@ -21,6 +22,25 @@ func (nn *netNotifiee) Connected(n inet.Network, v inet.Conn) {
 	default:
 	}
 	dht.Update(dht.Context(), v.RemotePeer())
+
+	// clients are only known once identified
+	if ider, ok := dht.host.(identifier); ok {
+		go func() {
+			select {
+			case <-ider.IDService().IdentifyWait(v):
+			case <-dht.Closing():
+				return
+			}
+			if dht.isClient(v.RemotePeer()) {
+				dht.routingTable.Remove(v.RemotePeer())
+			}
+		}()
+	}
+}
+
+// identifier is implemented by hosts running the identify service.
+type identifier interface {
+	IDService() *identify.IDService
 }
 
 func (nn *netNotifiee) Disconnected(n inet.Network, v inet.Conn) {
@@ -1,29 +1,60 @@
 package dht
 
 import (
+	"encoding/binary"
+	"fmt"
+	"strings"
 	"time"
 
+	lru "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/hashicorp/golang-lru"
+	b58 "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-base58"
 	ctxgroup "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-ctxgroup"
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dsq "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/query"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	u "github.com/ipfs/go-ipfs/util"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 )
 
-type providerInfo struct {
-	Creation time.Time
-	Value    peer.ID
-}
+// ProvideValidity is how long a provider record is kept after it was
+// last added.
+var ProvideValidity = time.Hour * 24
+
+// ProviderCacheSize is the number of keys whose providers are kept in
+// memory. The rest are read from the datastore when asked for.
+var ProviderCacheSize = 256
+
+var defaultCleanupInterval = time.Hour
 
+// providersKeyPrefix is where provider records live in the datastore, at
+// /providers/<key>/<peer>.
+const providersKeyPrefix = "/providers/"
+
+// ProviderManager stores the provider records this node knows of in its
+// datastore, so they survive a restart. The providers of recently used
+// keys are cached in memory.
 type ProviderManager struct {
-	providers map[u.Key][]*providerInfo
+	// all non channel fields are meant to be accessed only within
+	// the run method
+	providers *lru.Cache
 	local     map[u.Key]struct{}
 	lpeer     peer.ID
-	getlocal  chan chan []u.Key
-	newprovs  chan *addProv
-	getprovs  chan *getProv
-	period    time.Duration
+	dstore    ds.Datastore
+
+	getlocal chan chan []u.Key
+	newprovs chan *addProv
+	getprovs chan *getProv
+	period   time.Duration
 	ctxgroup.ContextGroup
+
+	cleanupInterval time.Duration
+}
+
+// providerSet holds the providers of a key, and when each was added.
+type providerSet struct {
+	providers []peer.ID
+	set       map[peer.ID]time.Time
 }
 
 type addProv struct {
@@ -36,14 +67,21 @@ type getProv struct {
 	resp chan []peer.ID
 }
 
-func NewProviderManager(ctx context.Context, local peer.ID) *ProviderManager {
+func NewProviderManager(ctx context.Context, local peer.ID, dstore ds.Datastore) *ProviderManager {
 	pm := new(ProviderManager)
 	pm.getprovs = make(chan *getProv)
 	pm.newprovs = make(chan *addProv)
-	pm.providers = make(map[u.Key][]*providerInfo)
+	pm.dstore = dstore
+	cache, err := lru.New(ProviderCacheSize)
+	if err != nil {
+		panic(err) // only fails for a bad size
+	}
+	pm.providers = cache
 	pm.getlocal = make(chan chan []u.Key)
 	pm.local = make(map[u.Key]struct{})
+	pm.lpeer = local
 	pm.ContextGroup = ctxgroup.WithContext(ctx)
+	pm.cleanupInterval = defaultCleanupInterval
 
 	pm.Children().Add(1)
 	go pm.run()
@@ -51,29 +89,32 @@ func NewProviderManager(ctx context.Context, local peer.ID) *ProviderManager {
 	return pm
 }
 
+func mkProvKey(k u.Key) string {
+	return providersKeyPrefix + b58.Encode([]byte(k))
+}
+
 func (pm *ProviderManager) run() {
 	defer pm.Children().Done()
 
-	tick := time.NewTicker(time.Hour)
+	tick := time.NewTicker(pm.cleanupInterval)
+	defer tick.Stop()
 	for {
 		select {
 		case np := <-pm.newprovs:
 			if np.val == pm.lpeer {
 				pm.local[np.k] = struct{}{}
 			}
-			pi := new(providerInfo)
-			pi.Creation = time.Now()
-			pi.Value = np.val
-			arr := pm.providers[np.k]
-			pm.providers[np.k] = append(arr, pi)
+			err := pm.addProv(np.k, np.val)
+			if err != nil {
+				log.Error("error adding new providers: ", err)
+			}
 
 		case gp := <-pm.getprovs:
-			var parr []peer.ID
-			provs := pm.providers[gp.k]
-			for _, p := range provs {
-				parr = append(parr, p.Value)
+			provs, err := pm.providersForKey(gp.k)
+			if err != nil {
+				log.Error("error reading providers: ", err)
 			}
-			gp.resp <- parr
+			gp.resp <- provs
 
 		case lc := <-pm.getlocal:
 			var keys []u.Key
@@ -83,14 +124,8 @@ func (pm *ProviderManager) run() {
 			lc <- keys
 
 		case <-tick.C:
-			for k, provs := range pm.providers {
-				var filtered []*providerInfo
-				for _, p := range provs {
-					if time.Now().Sub(p.Creation) < time.Hour*24 {
-						filtered = append(filtered, p)
-					}
-				}
-				pm.providers[k] = filtered
+			if err := pm.removeExpired(); err != nil {
+				log.Error("error removing expired providers: ", err)
 			}
 
 		case <-pm.Closing():
@@ -99,6 +134,158 @@ func (pm *ProviderManager) run() {
 	}
 }
 
+func (pm *ProviderManager) addProv(k u.Key, p peer.ID) error {
+	now := time.Now()
+	if provs, ok := pm.providers.Get(k); ok {
+		provs.(*providerSet).setVal(p, now)
+	} // else not cached, just write through
+
+	return writeProviderEntry(pm.dstore, k, p, now)
+}
+
+func writeProviderEntry(dstore ds.Datastore, k u.Key, p peer.ID, t time.Time) error {
+	dsk := mkProvKey(k) + "/" + b58.Encode([]byte(p))
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutVarint(buf, t.UnixNano())
+	return dstore.Put(ds.NewKey(dsk), buf[:n])
+}
+
+func (pm *ProviderManager) providersForKey(k u.Key) ([]peer.ID, error) {
+	pset, err := pm.getProvSet(k)
+	if err != nil {
+		return nil, err
+	}
+
+	// records may have expired since they were cached
+	var out []peer.ID
+	now := time.Now()
+	for _, p := range pset.providers {
+		if now.Sub(pset.set[p]) < ProvideValidity {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func (pm *ProviderManager) getProvSet(k u.Key) (*providerSet, error) {
+	cached, ok := pm.providers.Get(k)
+	if ok {
+		return cached.(*providerSet), nil
+	}
+
+	pset, err := loadProvSet(pm.dstore, k)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pset.providers) > 0 {
+		pm.providers.Add(k, pset)
+	}
+	return pset, nil
+}
+
+// loadProvSet reads the providers of k from the datastore, and removes
+// the records that have expired.
+func loadProvSet(dstore ds.Datastore, k u.Key) (*providerSet, error) {
+	prefix := mkProvKey(k)
+	res, err := dstore.Query(dsq.Query{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	out := newProviderSet()
+	now := time.Now()
+	for e := range res.Next() {
+		if e.Error != nil {
+			log.Error("got an error: ", e.Error)
+			continue
+		}
+
+		// the prefix also matches longer keys starting the same way
+		i := strings.LastIndex(e.Key, "/")
+		if e.Key[:i] != prefix {
+			continue
+		}
+
+		t, err := readTimeValue(e.Value)
+		if err != nil {
+			log.Warning("parsing providers record from disk: ", err)
+			continue
+		}
+		if now.Sub(t) >= ProvideValidity {
+			if err := dstore.Delete(ds.NewKey(e.Key)); err != nil {
+				log.Warning("failed to remove provider record from disk: ", err)
+			}
+			continue
+		}
+
+		pid := peer.ID(b58.Decode(e.Key[i+1:]))
+		if pid == "" {
+			log.Warning("bad peer id in providers record: ", e.Key)
+			continue
+		}
+		out.setVal(pid, t)
+	}
+	return out, nil
+}
+
+func readTimeValue(v interface{}) (time.Time, error) {
+	data, ok := v.([]byte)
+	if !ok {
+		return time.Time{}, fmt.Errorf("data was not a []byte")
+	}
+
+	nsec, n := binary.Varint(data)
+	if n <= 0 {
+		return time.Time{}, fmt.Errorf("failed to parse time")
+	}
+	return time.Unix(0, nsec), nil
+}
+
+// removeExpired deletes every provider record that has expired from the
+// datastore, and empties the cache.
+func (pm *ProviderManager) removeExpired() error {
+	res, err := pm.dstore.Query(dsq.Query{Prefix: providersKeyPrefix})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	now := time.Now()
+	for e := range res.Next() {
+		if e.Error != nil {
+			return e.Error
+		}
+
+		t, err := readTimeValue(e.Value)
+		if err != nil || now.Sub(t) >= ProvideValidity {
+			if err := pm.dstore.Delete(ds.NewKey(e.Key)); err != nil {
+				log.Warning("failed to remove provider record from disk: ", err)
+			}
+		}
+	}
+
+	// cached sets are loaded again from what is left
+	pm.providers.Purge()
+	return nil
+}
+
+func newProviderSet() *providerSet {
+	return &providerSet{
+		set: make(map[peer.ID]time.Time),
+	}
+}
+
+func (ps *providerSet) setVal(p peer.ID, t time.Time) {
+	_, found := ps.set[p]
+	if !found {
+		ps.providers = append(ps.providers, p)
+	}
+	ps.set[p] = t
+}
+
 func (pm *ProviderManager) AddProvider(ctx context.Context, k u.Key, val peer.ID) {
 	prov := &addProv{
 		k:   k,
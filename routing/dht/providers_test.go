@@ -1,8 +1,12 @@
 package dht
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	u "github.com/ipfs/go-ipfs/util"
 
@@ -12,7 +16,7 @@ import (
 func TestProviderManager(t *testing.T) {
 	ctx := context.Background()
 	mid := peer.ID("testing")
-	p := NewProviderManager(ctx, mid)
+	p := NewProviderManager(ctx, mid, dssync.MutexWrap(ds.NewMapDatastore()))
 	a := u.Key("test")
 	p.AddProvider(ctx, a, peer.ID("testingprovider"))
 	resp := p.GetProviders(ctx, a)
@@ -21,3 +25,81 @@ func TestProviderManager(t *testing.T) {
 	}
 	p.Close()
 }
+
+func TestProvidersDatastore(t *testing.T) {
+	old := ProviderCacheSize
+	ProviderCacheSize = 10
+	defer func() { ProviderCacheSize = old }()
+
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	p := NewProviderManager(ctx, peer.ID("testing"), dstore)
+
+	friend := peer.ID("friend")
+	var keys []u.Key
+	for i := 0; i < 100; i++ {
+		k := u.Key(fmt.Sprint(i))
+		keys = append(keys, k)
+		p.AddProvider(ctx, k, friend)
+	}
+
+	// most keys are no longer cached
+	for _, k := range keys {
+		resp := p.GetProviders(ctx, k)
+		if len(resp) != 1 || resp[0] != friend {
+			t.Fatalf("wrong providers for %q: %v", k, resp)
+		}
+	}
+	if p.providers.Len() > 10 {
+		t.Fatalf("cache holds %d keys", p.providers.Len())
+	}
+	p.Close()
+
+	// a new manager on the same datastore still knows them
+	p = NewProviderManager(ctx, peer.ID("testing"), dstore)
+	defer p.Close()
+	for _, k := range keys {
+		resp := p.GetProviders(ctx, k)
+		if len(resp) != 1 || resp[0] != friend {
+			t.Fatalf("wrong providers for %q after restart: %v", k, resp)
+		}
+	}
+}
+
+func TestProvidersExpire(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+
+	a := u.Key("a")
+	b := u.Key("b")
+	then := time.Now().Add(-ProvideValidity - time.Minute)
+	for _, k := range []u.Key{a, b} {
+		if err := writeProviderEntry(dstore, k, peer.ID("stale"), then); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewProviderManager(ctx, peer.ID("testing"), dstore)
+	defer p.Close()
+	p.AddProvider(ctx, a, peer.ID("fresh"))
+
+	resp := p.GetProviders(ctx, a)
+	if len(resp) != 1 || resp[0] != peer.ID("fresh") {
+		t.Fatalf("expected only the fresh provider, got %v", resp)
+	}
+
+	// expired records are removed as they are loaded
+	staleA := ds.NewKey(mkProvKey(a) + "/" + peer.ID("stale").Pretty())
+	if has, _ := dstore.Has(staleA); has {
+		t.Fatal("expired record still in the datastore")
+	}
+
+	// and by the periodic cleanup
+	if err := p.removeExpired(); err != nil {
+		t.Fatal(err)
+	}
+	staleB := ds.NewKey(mkProvKey(b) + "/" + peer.ID("stale").Pretty())
+	if has, _ := dstore.Has(staleB); has {
+		t.Fatal("expired record still in the datastore")
+	}
+}
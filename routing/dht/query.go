@@ -2,6 +2,7 @@ package dht
 
 import (
 	"sync"
+	"time"
 
 	notif "github.com/ipfs/go-ipfs/notifications"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
@@ -78,6 +79,9 @@ type dhtQueryRunner struct {
 	rateLimit chan struct{} // processing semaphore
 	log       eventlog.EventLogger
 
+	// ctx carries the caller's values, such as where to send query events
+	ctx context.Context
+
 	proc process.Process
 	sync.RWMutex
 }
@@ -97,6 +101,7 @@ func newQueryRunner(q *dhtQuery) *dhtQueryRunner {
 
 func (r *dhtQueryRunner) Run(ctx context.Context, peers []peer.ID) (*dhtQueryResult, error) {
 	r.log = log
+	r.ctx = ctx
 
 	if len(peers) == 0 {
 		log.Warning("Running query with no peers!")
@@ -211,8 +216,8 @@ func (r *dhtQueryRunner) queryPeer(proc process.Process, p peer.ID) {
 
 	// ok let's do this!
 
-	// create a context from our proc.
-	ctx := ctxproc.WithProcessClosing(context.Background(), proc)
+	// create a context from our proc, keeping the values of the caller's.
+	ctx := ctxproc.WithProcessClosing(r.ctx, proc)
 
 	// make sure we do this when we exit
 	defer func() {
@@ -231,12 +236,19 @@ func (r *dhtQueryRunner) queryPeer(proc process.Process, p peer.ID) {
 
 		pi := peer.PeerInfo{ID: p}
 
+		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+			Type: notif.DialingPeer,
+			ID:   p,
+		})
+		start := time.Now()
 		if err := r.query.dht.host.Connect(ctx, pi); err != nil {
 			log.Debugf("Error connecting: %s", err)
 
 			notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-				Type:  notif.QueryError,
-				Extra: err.Error(),
+				Type:     notif.QueryError,
+				ID:       p,
+				Extra:    err.Error(),
+				Duration: time.Since(start),
 			})
 
 			r.Lock()
@@ -250,7 +262,13 @@ func (r *dhtQueryRunner) queryPeer(proc process.Process, p peer.ID) {
 	}
 
 	// finally, run the query against this peer
+	notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+		Type: notif.SendingQuery,
+		ID:   p,
+	})
+	start := time.Now()
 	res, err := r.query.qfunc(ctx, p)
+	r.publishResult(ctx, p, res, err, time.Since(start))
 
 	if err != nil {
 		log.Debugf("ERROR worker for: %v %v", p, err)
@@ -283,3 +301,31 @@ func (r *dhtQueryRunner) queryPeer(proc process.Process, p peer.ID) {
 		log.Debugf("QUERY worker for: %v - not found, and no closer peers.", p)
 	}
 }
+
+// publishResult sends the query events for the answer of peer p, which
+// took d to come.
+func (r *dhtQueryRunner) publishResult(ctx context.Context, p peer.ID, res *dhtQueryResult, err error, d time.Duration) {
+	if err != nil {
+		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+			Type:     notif.QueryError,
+			ID:       p,
+			Extra:    err.Error(),
+			Duration: d,
+		})
+		return
+	}
+
+	if res.value != nil {
+		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+			Type:     notif.FoundValue,
+			ID:       p,
+			Duration: d,
+		})
+	}
+	notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+		Type:      notif.PeerResponse,
+		ID:        p,
+		Responses: pointerizePeerInfos(res.closerPeers),
+		Duration:  d,
+	})
+}
@@ -51,6 +51,31 @@ func (dht *IpfsDHT) GetPublicKey(ctx context.Context, p peer.ID) (ci.PubKey, err
 	return pk, dht.peerstore.AddPubKey(p, pk)
 }
 
+// localPublicKey finds the public key of p without searching the network:
+// in the peerstore, or among the records stored here.
+func (dht *IpfsDHT) localPublicKey(p peer.ID) (ci.PubKey, error) {
+	if pk := dht.peerstore.PubKey(p); pk != nil {
+		return pk, nil
+	}
+
+	val, err := dht.getLocal(routing.KeyForPublicKey(p))
+	if err != nil {
+		return nil, err
+	}
+	pk, err := ci.UnmarshalPublicKey(val)
+	if err != nil {
+		return nil, err
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return nil, err
+	}
+	if id != p {
+		return nil, fmt.Errorf("public key does not match id: %s", p)
+	}
+	return pk, nil
+}
+
 func (dht *IpfsDHT) getPublicKeyFromNode(ctx context.Context, p peer.ID) (ci.PubKey, error) {
 
 	// check locally, just in case...
@@ -1,11 +1,13 @@
 package dht
 
 import (
+	"bytes"
 	"sync"
 	"time"
 
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 	notif "github.com/ipfs/go-ipfs/notifications"
+	ci "github.com/ipfs/go-ipfs/p2p/crypto"
 	inet "github.com/ipfs/go-ipfs/p2p/net"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	"github.com/ipfs/go-ipfs/routing"
@@ -66,9 +68,16 @@ func (dht *IpfsDHT) PutValue(ctx context.Context, key u.Key, value []byte) error
 				ID:   p,
 			})
 
+			start := time.Now()
 			err := dht.putValueToPeer(ctx, p, key, rec)
 			if err != nil {
 				log.Debugf("failed putting value to peer: %s", err)
+				notif.PublishQueryEvent(ctx, &notif.QueryEvent{
+					Type:     notif.QueryError,
+					ID:       p,
+					Extra:    err.Error(),
+					Duration: time.Since(start),
+				})
 			}
 		}(p)
 	}
@@ -79,16 +88,112 @@ func (dht *IpfsDHT) PutValue(ctx context.Context, key u.Key, value []byte) error
 // GetValue searches for the value corresponding to given Key.
 // If the search does not succeed, a multiaddr string of a closer peer is
 // returned along with util.ErrSearchIncomplete
+//
+// Records whose validator has a Selector are not taken from the first
+// peer that has one: up to NumSelectValues of them are collected, the local
+// one included, and the best one is returned. Peers found with a worse
+// record are then sent the best one.
 func (dht *IpfsDHT) GetValue(ctx context.Context, key u.Key) ([]byte, error) {
+	selects := dht.Validator.Selects(key)
+
+	var recvd []recvdRecord
+
 	// If we have it local, dont bother doing an RPC!
-	val, err := dht.getLocal(key)
+	rec, err := dht.getLocalRecord(key)
 	if err == nil {
 		log.Debug("have it locally")
-		return val, nil
+		if !selects {
+			return rec.GetValue(), nil
+		}
+		recvd = append(recvd, recvdRecord{rec: rec, from: dht.self})
 	} else {
 		log.Debug("failed to get value locally: %s", err)
 	}
 
+	nvals := 1
+	if selects {
+		nvals = NumSelectValues
+	}
+
+	found, err := dht.getValues(ctx, key, nvals)
+	recvd = append(recvd, found...)
+	if len(recvd) == 0 {
+		if err == nil {
+			err = routing.ErrNotFound
+		}
+		return nil, err
+	}
+
+	vals := make([][]byte, len(recvd))
+	for i, r := range recvd {
+		vals[i] = r.rec.GetValue()
+	}
+	i, err := dht.Validator.Select(key, vals, func(p peer.ID) (ci.PubKey, error) {
+		return dht.GetPublicKey(ctx, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if selects {
+		go dht.correctStale(key, recvd[i].rec, recvd)
+	}
+	return vals[i], nil
+}
+
+// recvdRecord is a record found for a key, and the peer that had it.
+type recvdRecord struct {
+	rec  *pb.Record
+	from peer.ID
+}
+
+// correctStale sends best to the peers in recvd that had another record
+// for key. Peers that already have a better one keep theirs. Nothing is
+// sent unless best verifies in full, as records stored locally are not
+// checked when read.
+func (dht *IpfsDHT) correctStale(key u.Key, best *pb.Record, recvd []recvdRecord) {
+	ctx, cancel := context.WithTimeout(dht.Context(), time.Second*30)
+	defer cancel()
+
+	if err := dht.verifyRecordOnline(ctx, best); err != nil {
+		log.Debugf("not correcting stale records of %s: %s", key, err)
+		return
+	}
+	err := dht.Validator.VerifyValueSig(key, best.GetValue(), func(p peer.ID) (ci.PubKey, error) {
+		return dht.GetPublicKey(ctx, p)
+	})
+	if err != nil {
+		log.Debugf("not correcting stale records of %s: %s", key, err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, r := range recvd {
+		if bytes.Equal(r.rec.GetValue(), best.GetValue()) {
+			continue
+		}
+
+		if r.from == dht.self {
+			if err := dht.putLocal(key, best); err != nil {
+				log.Debugf("failed correcting local record: %s", err)
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			if err := dht.putValueToPeer(ctx, p, key, best); err != nil {
+				log.Debugf("failed correcting stale record at %s: %s", p, err)
+			}
+		}(r.from)
+	}
+	wg.Wait()
+}
+
+// getValues queries the network for the record of key, until nvals records
+// are found or the peers run out, and returns the records found.
+func (dht *IpfsDHT) getValues(ctx context.Context, key u.Key, nvals int) ([]recvdRecord, error) {
 	// get closest peers in the routing table
 	rtp := dht.routingTable.NearestPeers(kb.ConvertKey(key), AlphaValue)
 	log.Debugf("peers in rt: %s", len(rtp), rtp)
@@ -97,44 +202,41 @@ func (dht *IpfsDHT) GetValue(ctx context.Context, key u.Key) ([]byte, error) {
 		return nil, kb.ErrLookupFailure
 	}
 
+	var recvd []recvdRecord
+	var recvdlk sync.Mutex
+
 	// setup the Query
 	query := dht.newQuery(key, func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-			Type: notif.SendingQuery,
-			ID:   p,
-		})
-
-		val, peers, err := dht.getValueOrPeers(ctx, p, key)
+		rec, peers, err := dht.getValueOrPeers(ctx, p, key)
 		if err != nil {
 			return nil, err
 		}
 
-		res := &dhtQueryResult{value: val, closerPeers: peers}
-		if val != nil {
-			res.success = true
+		res := &dhtQueryResult{closerPeers: peers}
+		if rec != nil {
+			res.value = rec.GetValue()
+			recvdlk.Lock()
+			recvd = append(recvd, recvdRecord{rec: rec, from: p})
+			res.success = len(recvd) >= nvals
+			recvdlk.Unlock()
 		}
 
-		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-			Type:      notif.PeerResponse,
-			ID:        p,
-			Responses: pointerizePeerInfos(peers),
-		})
-
 		return res, nil
 	})
 
-	// run it!
-	result, err := query.Run(ctx, rtp)
-	if err != nil {
-		return nil, err
-	}
+	// run it! not finding as many values as asked for is fine.
+	_, err := query.Run(ctx, rtp)
 
-	log.Debugf("GetValue %v %v", key, result.value)
-	if result.value == nil {
-		return nil, routing.ErrNotFound
+	recvdlk.Lock()
+	defer recvdlk.Unlock()
+	log.Debugf("GetValue %v: %d values", key, len(recvd))
+	if len(recvd) == 0 {
+		if err == nil {
+			err = routing.ErrNotFound
+		}
+		return nil, err
 	}
-
-	return result.value, nil
+	return recvd, nil
 }
 
 // Value provider layer of indirection.
@@ -211,10 +313,6 @@ func (dht *IpfsDHT) findProvidersAsyncRoutine(ctx context.Context, key u.Key, co
 
 	// setup the Query
 	query := dht.newQuery(key, func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-			Type: notif.SendingQuery,
-			ID:   p,
-		})
 		pmes, err := dht.findProvidersSingle(ctx, p, key)
 		if err != nil {
 			return nil, err
@@ -247,11 +345,6 @@ func (dht *IpfsDHT) findProvidersAsyncRoutine(ctx context.Context, key u.Key, co
 		clpeers := pb.PBPeersToPeerInfos(closer)
 		log.Debugf("got closer peers: %d %s", len(clpeers), clpeers)
 
-		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-			Type:      notif.PeerResponse,
-			ID:        p,
-			Responses: pointerizePeerInfos(clpeers),
-		})
 		return &dhtQueryResult{closerPeers: clpeers}, nil
 	})
 
@@ -290,11 +383,6 @@ func (dht *IpfsDHT) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, er
 
 	// setup the Query
 	query := dht.newQuery(u.Key(id), func(ctx context.Context, p peer.ID) (*dhtQueryResult, error) {
-		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-			Type: notif.SendingQuery,
-			ID:   p,
-		})
-
 		pmes, err := dht.findPeerSingle(ctx, p, id)
 		if err != nil {
 			return nil, err
@@ -313,11 +401,6 @@ func (dht *IpfsDHT) FindPeer(ctx context.Context, id peer.ID) (peer.PeerInfo, er
 			}
 		}
 
-		notif.PublishQueryEvent(ctx, &notif.QueryEvent{
-			Type:      notif.PeerResponse,
-			Responses: pointerizePeerInfos(clpeerInfos),
-		})
-
 		return &dhtQueryResult{closerPeers: clpeerInfos}, nil
 	})
 
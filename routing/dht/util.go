@@ -13,6 +13,10 @@ var KValue = 10
 // Alpha is the concurrency factor for asynchronous requests.
 var AlphaValue = 3
 
+// NumSelectValues is the number of records GetValue collects to select the
+// best one from, for keys whose records are selected from.
+var NumSelectValues = 16
+
 // A counter for incrementing a variable across multiple threads
 type counter struct {
 	n   int
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	ci "github.com/ipfs/go-ipfs/p2p/crypto"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	pb "github.com/ipfs/go-ipfs/routing/dht/pb"
 	u "github.com/ipfs/go-ipfs/util"
 )
@@ -27,9 +28,47 @@ var ErrInvalidRecordType = errors.New("invalid record keytype")
 // its own notion of validity.
 type Validator map[string]*ValidChecker
 
+// Selector picks the best of several valid records for a key, and
+// returns its index.
+type Selector interface {
+	Select(u.Key, [][]byte) (int, error)
+}
+
+// SelectorFunc is a function that implements Selector.
+type SelectorFunc func(u.Key, [][]byte) (int, error)
+
+// Select implements Selector
+func (f SelectorFunc) Select(k u.Key, vals [][]byte) (int, error) {
+	return f(k, vals)
+}
+
+// ErrNoRecords is returned when there are no records to select from.
+var ErrNoRecords = errors.New("no records to select from")
+
+// SignedValueFunc returns the peer whose key val, a value for k, must be
+// signed with, along with the data signed and the signature.
+type SignedValueFunc func(k u.Key, val []byte) (signer peer.ID, data, sig []byte, err error)
+
+// PubKeyFunc returns the public key of a peer.
+type PubKeyFunc func(peer.ID) (ci.PubKey, error)
+
+// ErrBadValueSig is returned for values not signed by the owner of their
+// key.
+var ErrBadValueSig = errors.New("value not signed by the owner of its key")
+
 type ValidChecker struct {
 	Func ValidatorFunc
 	Sign bool
+
+	// Selector picks the best of several records. Records of keys
+	// without one never change, so any one of them will do.
+	Selector Selector
+
+	// SignedValue is set for keys whose values must be signed by the
+	// owner of the key, rather than only by whoever put the record. Their
+	// values cannot be trusted until that signature was checked with
+	// VerifyValueSig.
+	SignedValue SignedValueFunc
 }
 
 // VerifyRecord checks a record and ensures it is still valid.
@@ -51,6 +90,76 @@ func (v Validator) VerifyRecord(r *pb.Record) error {
 	return val.Func(u.Key(r.GetKey()), r.GetValue())
 }
 
+// Selects reports whether records for k are selected from, rather than
+// the first one found being taken.
+func (v Validator) Selects(k u.Key) bool {
+	parts := strings.Split(string(k), "/")
+	if len(parts) < 3 {
+		return false
+	}
+
+	val, ok := v[parts[1]]
+	return ok && val.Selector != nil
+}
+
+// Select returns the index of the best of the records vals for k. Without a
+// SelectorFunc for k, that is the first one. Values that fail
+// VerifyValueSig, with the public keys found by getKey, are never selected.
+func (v Validator) Select(k u.Key, vals [][]byte, getKey PubKeyFunc) (int, error) {
+	var signed [][]byte
+	var index []int
+	for i, val := range vals {
+		if err := v.VerifyValueSig(k, val, getKey); err != nil {
+			log.Debugf("skipping value for %s: %s", k, err)
+			continue
+		}
+		signed = append(signed, val)
+		index = append(index, i)
+	}
+
+	if len(signed) == 0 {
+		return 0, ErrNoRecords
+	}
+	if !v.Selects(k) {
+		return index[0], nil
+	}
+
+	parts := strings.Split(string(k), "/")
+	i, err := v[parts[1]].Selector.Select(k, signed)
+	if err != nil {
+		return 0, err
+	}
+	return index[i], nil
+}
+
+// VerifyValueSig checks that val, a value for k, is signed by the owner of
+// k, whose public key is found by getKey. Values of keys without a
+// SignedValueFunc need no such signature.
+func (v Validator) VerifyValueSig(k u.Key, val []byte, getKey PubKeyFunc) error {
+	parts := strings.Split(string(k), "/")
+	if len(parts) < 3 {
+		return nil
+	}
+	vc, ok := v[parts[1]]
+	if !ok || vc.SignedValue == nil {
+		return nil
+	}
+
+	p, data, sig, err := vc.SignedValue(k, val)
+	if err != nil {
+		return err
+	}
+	pk, err := getKey(p)
+	if err != nil {
+		return err
+	}
+	good, err := pk.Verify(data, sig)
+	if err != nil || !good {
+		return ErrBadValueSig
+	}
+	return nil
+}
+
 func (v Validator) IsSigned(k u.Key) (bool, error) {
 	// Now, check validity func
 	parts := strings.Split(string(k), "/")
@@ -93,7 +202,7 @@ func CheckRecordSig(r *pb.Record, pk ci.PubKey) error {
 	blob := RecordBlobForSig(r)
 	good, err := pk.Verify(blob, r.Signature)
 	if err != nil {
-		return nil
+		return err
 	}
 	if !good {
 		return errors.New("invalid record signature")
@@ -71,7 +71,7 @@ func (px *standard) SendMessage(ctx context.Context, m *dhtpb.Message) error {
 	var err error
 	var numSuccesses int
 	for _, remote := range sortedByKey(px.remoteIDs, m.GetKey()) {
-		if err = px.sendMessage(ctx, m, remote); err != nil { // careful don't re-declare err!
+		if err = SendMessageTo(ctx, px.Host, remote, m); err != nil { // careful don't re-declare err!
 			continue
 		}
 		numSuccesses++
@@ -86,18 +86,20 @@ func (px *standard) SendMessage(ctx context.Context, m *dhtpb.Message) error {
 	return err // NB: returns the last error
 }
 
-func (px *standard) sendMessage(ctx context.Context, m *dhtpb.Message, remote peer.ID) (err error) {
-	e := log.EventBegin(ctx, "sendRoutingMessage", px.Host.ID(), remote, m)
+// SendMessageTo sends m to the routing server remote, without waiting for
+// a response.
+func SendMessageTo(ctx context.Context, h host.Host, remote peer.ID, m *dhtpb.Message) (err error) {
+	e := log.EventBegin(ctx, "sendRoutingMessage", h.ID(), remote, m)
 	defer func() {
 		if err != nil {
 			e.SetError(err)
 		}
 		e.Done()
 	}()
-	if err = px.Host.Connect(ctx, peer.PeerInfo{ID: remote}); err != nil {
+	if err = h.Connect(ctx, peer.PeerInfo{ID: remote}); err != nil {
 		return err
 	}
-	s, err := px.Host.NewStream(ProtocolSNR, remote)
+	s, err := h.NewStream(ProtocolSNR, remote)
 	if err != nil {
 		return err
 	}
@@ -116,7 +118,7 @@ func (px *standard) SendRequest(ctx context.Context, m *dhtpb.Message) (*dhtpb.M
 	var err error
 	for _, remote := range sortedByKey(px.remoteIDs, m.GetKey()) {
 		var reply *dhtpb.Message
-		reply, err = px.sendRequest(ctx, m, remote) // careful don't redeclare err!
+		reply, err = SendRequestTo(ctx, px.Host, remote, m) // careful don't redeclare err!
 		if err != nil {
 			continue
 		}
@@ -125,14 +127,16 @@ func (px *standard) SendRequest(ctx context.Context, m *dhtpb.Message) (*dhtpb.M
 	return nil, err // NB: returns the last error
 }
 
-func (px *standard) sendRequest(ctx context.Context, m *dhtpb.Message, remote peer.ID) (*dhtpb.Message, error) {
-	e := log.EventBegin(ctx, "sendRoutingRequest", px.Host.ID(), remote, eventlog.Pair("request", m))
+// SendRequestTo sends the request m to the routing server remote, and
+// returns its response.
+func SendRequestTo(ctx context.Context, h host.Host, remote peer.ID, m *dhtpb.Message) (*dhtpb.Message, error) {
+	e := log.EventBegin(ctx, "sendRoutingRequest", h.ID(), remote, eventlog.Pair("request", m))
 	defer e.Done()
-	if err := px.Host.Connect(ctx, peer.PeerInfo{ID: remote}); err != nil {
+	if err := h.Connect(ctx, peer.PeerInfo{ID: remote}); err != nil {
 		e.SetError(err)
 		return nil, err
 	}
-	s, err := px.Host.NewStream(ProtocolSNR, remote)
+	s, err := h.NewStream(ProtocolSNR, remote)
 	if err != nil {
 		e.SetError(err)
 		return nil, err
@@ -0,0 +1,83 @@
+package supernode
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	util "github.com/ipfs/go-ipfs/util"
+)
+
+// ringPointsPerPeer is how many points each peer has on a Ring. More points
+// spread the keys more evenly.
+const ringPointsPerPeer = 64
+
+// Ring assigns keys to a set of peers by consistent hashing: every peer
+// owns the keys that hash close after its points on a ring, so adding or
+// removing a peer moves only the keys it owns.
+type Ring struct {
+	points []uint64 // sorted
+	owners map[uint64]peer.ID
+	size   int
+}
+
+// NewRing returns a Ring of peers.
+func NewRing(peers []peer.ID) *Ring {
+	r := &Ring{owners: make(map[uint64]peer.ID)}
+	seen := make(map[peer.ID]bool)
+	for _, p := range peers {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		r.size++
+		for i := 0; i < ringPointsPerPeer; i++ {
+			pt := ringHash(string(p) + "/" + strconv.Itoa(i))
+			if _, taken := r.owners[pt]; taken {
+				continue
+			}
+			r.owners[pt] = p
+			r.points = append(r.points, pt)
+		}
+	}
+	sort.Sort(uint64s(r.points))
+	return r
+}
+
+// Owners returns the n peers that own k, the first owner first. It
+// returns all peers of the ring if it has fewer than n.
+func (r *Ring) Owners(k util.Key, n int) []peer.ID {
+	if n > r.size {
+		n = r.size
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	h := ringHash(string(k))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+
+	var owners []peer.ID
+	seen := make(map[peer.ID]bool)
+	for j := 0; len(owners) < n; j++ {
+		p := r.owners[r.points[(i+j)%len(r.points)]]
+		if !seen[p] {
+			seen[p] = true
+			owners = append(owners, p)
+		}
+	}
+	return owners
+}
+
+func ringHash(s string) uint64 {
+	sum := sha256.Sum256([]byte(s))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+type uint64s []uint64
+
+func (s uint64s) Len() int           { return len(s) }
+func (s uint64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,53 @@
+package supernode
+
+import (
+	"fmt"
+	"testing"
+
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
+	"github.com/ipfs/go-ipfs/util"
+)
+
+func TestRingOwners(t *testing.T) {
+	peers := []peer.ID{"a", "b", "c", "d"}
+	r := NewRing(peers)
+	reversed := NewRing([]peer.ID{"d", "c", "b", "a", "a"})
+
+	counts := make(map[peer.ID]int)
+	for i := 0; i < 1000; i++ {
+		k := util.Key(fmt.Sprintf("key%d", i))
+		owners := r.Owners(k, 2)
+		if len(owners) != 2 || owners[0] == owners[1] {
+			t.Fatalf("bad owners of %s: %v", k, owners)
+		}
+		if o := reversed.Owners(k, 2); o[0] != owners[0] || o[1] != owners[1] {
+			t.Fatalf("owners of %s depend on the order of peers: %v, %v", k, owners, o)
+		}
+		counts[owners[0]]++
+	}
+	for _, p := range peers {
+		if counts[p] < 150 || counts[p] > 350 {
+			t.Fatalf("keys badly spread: %v", counts)
+		}
+	}
+
+	if n := len(r.Owners("foo", 10)); n != len(peers) {
+		t.Fatalf("expected %d owners, got %d", len(peers), n)
+	}
+	if n := len(NewRing(nil).Owners("foo", 2)); n != 0 {
+		t.Fatalf("expected no owners, got %d", n)
+	}
+}
+
+func TestRingRemovePeer(t *testing.T) {
+	r := NewRing([]peer.ID{"a", "b", "c", "d"})
+	without := NewRing([]peer.ID{"a", "b", "c"})
+	for i := 0; i < 1000; i++ {
+		k := util.Key(fmt.Sprintf("key%d", i))
+		before := r.Owners(k, 1)[0]
+		after := without.Owners(k, 1)[0]
+		if before != "d" && before != after {
+			t.Fatalf("%s moved from %s to %s", k, before, after)
+		}
+	}
+}
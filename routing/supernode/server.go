@@ -3,10 +3,12 @@ package supernode
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
 	datastore "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
 	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	host "github.com/ipfs/go-ipfs/p2p/host"
 	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	dhtpb "github.com/ipfs/go-ipfs/routing/dht/pb"
 	record "github.com/ipfs/go-ipfs/routing/record"
@@ -14,17 +16,31 @@ import (
 	util "github.com/ipfs/go-ipfs/util"
 )
 
+// replicationTimeout bounds how long a server waits for the others of its
+// set when sharing a query with them.
+const replicationTimeout = 30 * time.Second
+
 // Server handles routing queries using a database backend
 type Server struct {
 	local           peer.ID
 	routingBackend  datastore.ThreadSafeDatastore
 	peerstore       peer.Peerstore
 	*proxy.Loopback // so server can be injected into client
+
+	// set by Replicate
+	host     host.Host
+	ring     *Ring
+	replicas int
+	servers  map[peer.ID]bool // the other servers of the set
 }
 
 // NewServer creates a new Supernode routing Server
 func NewServer(ds datastore.ThreadSafeDatastore, ps peer.Peerstore, local peer.ID) (*Server, error) {
-	s := &Server{local, ds, ps, nil}
+	s := &Server{
+		local:          local,
+		routingBackend: ds,
+		peerstore:      ps,
+	}
 	s.Loopback = &proxy.Loopback{
 		Handler: s,
 		Local:   local,
@@ -32,6 +48,32 @@ func NewServer(ds datastore.ThreadSafeDatastore, ps peer.Peerstore, local peer.I
 	return s, nil
 }
 
+// Replicate makes s one of a set of servers sharing their records, so that
+// clients may use any of them, and records outlive the loss of a server.
+// Each record is held by replicas servers of the set, chosen by consistent
+// hashing of its key. Every server of the set must be given the same
+// servers, which may include s, and replicas. It must be called before s
+// handles requests.
+func (s *Server) Replicate(h host.Host, servers []peer.PeerInfo, replicas int) error {
+	if replicas < 1 {
+		return fmt.Errorf("invalid number of replicas: %d", replicas)
+	}
+	ids := []peer.ID{s.local}
+	s.servers = make(map[peer.ID]bool)
+	for _, pi := range servers {
+		if pi.ID == s.local {
+			continue
+		}
+		s.peerstore.AddAddrs(pi.ID, pi.Addrs, peer.PermanentAddrTTL)
+		s.servers[pi.ID] = true
+		ids = append(ids, pi.ID)
+	}
+	s.host = h
+	s.ring = NewRing(ids)
+	s.replicas = replicas
+	return nil
+}
+
 func (_ *Server) Bootstrap(ctx context.Context) error {
 	return nil
 }
@@ -53,6 +95,14 @@ func (s *Server) handleMessage(
 
 	case dhtpb.Message_GET_VALUE:
 		rawRecord, err := getRoutingRecord(s.routingBackend, util.Key(req.GetKey()))
+		if err != nil {
+			for _, res := range s.fetch(ctx, p, req) {
+				if res.GetRecord() != nil {
+					rawRecord, err = res.GetRecord(), nil
+					break
+				}
+			}
+		}
 		if err != nil {
 			return "", nil
 		}
@@ -66,7 +116,10 @@ func (s *Server) handleMessage(
 		// 	log.Event(ctx, "validationFailed", req, p)
 		// 	return "", nil
 		// }
-		putRoutingRecord(s.routingBackend, util.Key(req.GetKey()), req.GetRecord())
+		k := util.Key(req.GetKey())
+		s.store(ctx, p, req, func() error {
+			return putRoutingRecord(s.routingBackend, k, req.GetRecord())
+		})
 		return p, req
 
 	case dhtpb.Message_FIND_NODE:
@@ -81,18 +134,26 @@ func (s *Server) handleMessage(
 		return p.ID, response
 
 	case dhtpb.Message_ADD_PROVIDER:
+		var store []*dhtpb.Message_Peer
 		for _, provider := range req.GetProviderPeers() {
 			providerID := peer.ID(provider.GetId())
-			if providerID == p {
-				store := []*dhtpb.Message_Peer{provider}
-				storeProvidersToPeerstore(s.peerstore, p, store)
-				if err := putRoutingProviders(s.routingBackend, util.Key(req.GetKey()), store); err != nil {
-					return "", nil
-				}
+			// other servers pass on the providers of their clients
+			if providerID == p || s.servers[p] {
+				storeProvidersToPeerstore(s.peerstore, providerID, []*dhtpb.Message_Peer{provider})
+				store = append(store, provider)
 			} else {
 				log.Event(ctx, "addProviderBadRequest", p, req)
 			}
 		}
+		if len(store) == 0 {
+			return "", nil
+		}
+		k := util.Key(req.GetKey())
+		msg := dhtpb.NewMessage(dhtpb.Message_ADD_PROVIDER, req.GetKey(), 0)
+		msg.ProviderPeers = store
+		s.store(ctx, p, msg, func() error {
+			return putRoutingProviders(s.routingBackend, k, store)
+		})
 		return "", nil
 
 	case dhtpb.Message_GET_PROVIDERS:
@@ -100,6 +161,9 @@ func (s *Server) handleMessage(
 		if err != nil {
 			return "", nil
 		}
+		for _, res := range s.fetch(ctx, p, req) {
+			providers = mergeProviders(providers, res.GetProviderPeers())
+		}
 		response.ProviderPeers = providers
 		return p, response
 
@@ -110,6 +174,89 @@ func (s *Server) handleMessage(
 	return "", nil
 }
 
+// replicating reports whether the queries of p are shared with the other
+// servers of the set: those of clients are, those of other servers are
+// already.
+func (s *Server) replicating(p peer.ID) bool {
+	return s.ring != nil && !s.servers[p]
+}
+
+// store runs put if s is one of the servers holding the key of m, and sends
+// m to the others when it comes from a client. If none of those takes it,
+// s keeps it anyway.
+func (s *Server) store(ctx context.Context, p peer.ID, m *dhtpb.Message, put func() error) error {
+	if !s.replicating(p) {
+		return put()
+	}
+	ctx, cancel := context.WithTimeout(ctx, replicationTimeout)
+	defer cancel()
+
+	var owner bool
+	var remotes []peer.ID
+	for _, id := range s.ring.Owners(util.Key(m.GetKey()), s.replicas) {
+		if id == s.local {
+			owner = true
+		} else {
+			remotes = append(remotes, id)
+		}
+	}
+
+	errs := make(chan error, len(remotes))
+	for _, id := range remotes {
+		go func(id peer.ID) {
+			errs <- proxy.SendMessageTo(ctx, s.host, id, m)
+		}(id)
+	}
+	stored := 0
+	for _ = range remotes {
+		if err := <-errs; err != nil {
+			log.Debugf("replication failed: %s", err)
+		} else {
+			stored++
+		}
+	}
+
+	if owner || stored == 0 {
+		return put()
+	}
+	return nil
+}
+
+// fetch sends the request m to the other servers holding its key when it
+// comes from a client, and returns their responses.
+func (s *Server) fetch(ctx context.Context, p peer.ID, m *dhtpb.Message) []*dhtpb.Message {
+	if !s.replicating(p) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, replicationTimeout)
+	defer cancel()
+
+	var remotes []peer.ID
+	for _, id := range s.ring.Owners(util.Key(m.GetKey()), s.replicas) {
+		if id != s.local {
+			remotes = append(remotes, id)
+		}
+	}
+
+	results := make(chan *dhtpb.Message, len(remotes))
+	for _, id := range remotes {
+		go func(id peer.ID) {
+			res, err := proxy.SendRequestTo(ctx, s.host, id, m)
+			if err != nil {
+				log.Debugf("replica request failed: %s", err)
+			}
+			results <- res
+		}(id)
+	}
+	var responses []*dhtpb.Message
+	for _ = range remotes {
+		if res := <-results; res != nil {
+			responses = append(responses, res)
+		}
+	}
+	return responses
+}
+
 var _ proxy.RequestHandler = &Server{}
 var _ proxy.Proxy = &Server{}
 
@@ -149,6 +296,18 @@ func putRoutingProviders(ds datastore.Datastore, k util.Key, newRecords []*dhtpb
 	if err != nil {
 		return err
 	}
+	var protomsg dhtpb.Message
+	protomsg.ProviderPeers = mergeProviders(oldRecords, newRecords)
+	data, err := proto.Marshal(&protomsg)
+	if err != nil {
+		return err
+	}
+	return ds.Put(providerKey(k), data)
+}
+
+// mergeProviders returns the providers in oldRecords and newRecords, with
+// the new record of those in both.
+func mergeProviders(oldRecords, newRecords []*dhtpb.Message_Peer) []*dhtpb.Message_Peer {
 	mergedRecords := make(map[string]*dhtpb.Message_Peer)
 	for _, provider := range oldRecords {
 		mergedRecords[provider.GetId()] = provider // add original records
@@ -156,16 +315,11 @@ func putRoutingProviders(ds datastore.Datastore, k util.Key, newRecords []*dhtpb
 	for _, provider := range newRecords {
 		mergedRecords[provider.GetId()] = provider // overwrite old record if new exists
 	}
-	var protomsg dhtpb.Message
-	protomsg.ProviderPeers = make([]*dhtpb.Message_Peer, 0, len(mergedRecords))
+	merged := make([]*dhtpb.Message_Peer, 0, len(mergedRecords))
 	for _, provider := range mergedRecords {
-		protomsg.ProviderPeers = append(protomsg.ProviderPeers, provider)
+		merged = append(merged, provider)
 	}
-	data, err := proto.Marshal(&protomsg)
-	if err != nil {
-		return err
-	}
-	return ds.Put(providerKey(k), data)
+	return merged
 }
 
 func storeProvidersToPeerstore(ps peer.Peerstore, p peer.ID, providers []*dhtpb.Message_Peer) {
@@ -1,10 +1,18 @@
 package supernode
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	datastore "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
+	context "github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	host "github.com/ipfs/go-ipfs/p2p/host"
+	mocknet "github.com/ipfs/go-ipfs/p2p/net/mock"
+	peer "github.com/ipfs/go-ipfs/p2p/peer"
 	dhtpb "github.com/ipfs/go-ipfs/routing/dht/pb"
+	proxy "github.com/ipfs/go-ipfs/routing/supernode/proxy"
 	"github.com/ipfs/go-ipfs/util"
 )
 
@@ -31,6 +39,114 @@ func TestPutProviderDoesntResultInDuplicates(t *testing.T) {
 	}
 }
 
+func TestReplication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mn, err := mocknet.FullMeshConnected(ctx, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := mn.Hosts()
+	serverHosts, clientHosts := hosts[:3], hosts[3:]
+
+	var infos []peer.PeerInfo
+	for _, h := range serverHosts {
+		infos = append(infos, h.Peerstore().PeerInfo(h.ID()))
+	}
+	stores := make(map[peer.ID]datastore.Datastore)
+	for _, h := range serverHosts {
+		d := dssync.MutexWrap(datastore.NewMapDatastore())
+		s, err := NewServer(d, h.Peerstore(), h.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Replicate(h, infos, 2); err != nil {
+			t.Fatal(err)
+		}
+		h.SetStreamHandler(proxy.ProtocolSNR, s.HandleStream)
+		stores[h.ID()] = d
+	}
+
+	var ids []peer.ID
+	for _, pi := range infos {
+		ids = append(ids, pi.ID)
+	}
+	k := util.Key("/v/hello")
+	owners := NewRing(ids).Owners(k, 2)
+
+	// a client of the server that does not hold k
+	var other host.Host
+	for _, h := range serverHosts {
+		if h.ID() != owners[0] && h.ID() != owners[1] {
+			other = h
+		}
+	}
+	newClient := func(h host.Host, server host.Host) *Client {
+		px := proxy.Standard(h, []peer.PeerInfo{server.Peerstore().PeerInfo(server.ID())})
+		c, err := NewClient(px, h, h.Peerstore(), h.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	writer := newClient(clientHosts[0], other)
+	if err := writer.PutValue(ctx, k, []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Provide(ctx, k); err != nil {
+		t.Fatal(err)
+	}
+
+	// messages are not acknowledged, so wait for the records to arrive
+	held := func() error {
+		for id, d := range stores {
+			owner := id == owners[0] || id == owners[1]
+			for _, dskey := range []datastore.Key{k.DsKey(), providerKey(k)} {
+				if has, _ := d.Has(dskey); has != owner {
+					return fmt.Errorf("%s holds %s: %t, expected %t", id, dskey, has, owner)
+				}
+			}
+		}
+		return nil
+	}
+	for deadline := time.Now().Add(time.Second); held() != nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := held(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first owner goes down; the value is still found through any of
+	// the others
+	for _, h := range hosts {
+		if h.ID() != owners[0] {
+			mn.UnlinkPeers(owners[0], h.ID())
+			mn.DisconnectPeers(owners[0], h.ID())
+		}
+	}
+	for _, h := range serverHosts {
+		if h.ID() == owners[0] {
+			continue
+		}
+		reader := newClient(clientHosts[1], h)
+		val, err := reader.GetValue(ctx, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(val) != "world" {
+			t.Fatalf("expected 'world' got '%s'", val)
+		}
+
+		var found []peer.ID
+		for pi := range reader.FindProvidersAsync(ctx, k, 10) {
+			found = append(found, pi.ID)
+		}
+		if len(found) != 1 || found[0] != clientHosts[0].ID() {
+			t.Fatalf("wrong providers: %v", found)
+		}
+	}
+}
+
 func convPeer(name string, addrs ...string) *dhtpb.Message_Peer {
 	var rawAddrs [][]byte
 	for _, addr := range addrs {
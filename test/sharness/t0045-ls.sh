@@ -88,6 +88,36 @@ test_ls_cmd() {
 		EOF
 		test_cmp expected_ls_headers actual_ls_headers
 	'
+
+	test_expect_success "'ipfs ls -r <dir hash>' succeeds" '
+		ipfs ls -r QmfNy183bXiRVyrhyWtq3TwHn79yHEkiAGFr18P7YNzESj >actual_ls_recursive
+	'
+
+	test_expect_success "'ipfs ls -r <dir hash>' output looks good" '
+		cat <<-\EOF >expected_ls_recursive &&
+			QmSix55yz8CzWXf5ZVM9vgEvijnEeeXiTSarVtsqiiCJss 246  d1/     
+			QmQNd6ubRXaNG6Prov8o6vk3bn6eWsj9FxLGrAVDUAGkGe 139  d1/128  
+			QmZULkCELmmk5XNfCgTnCyFgAVxBRBXyDHGGMVoLFLiXEN 14   d1/a    
+			QmR3jhV4XpxxPjPT3Y8vNnWvWNvakdcT3H6vqpRBsX1MLy 1143 d2/     
+			QmbQBUSRL9raZtNXfpTDeaxQapibJEG6qEY8WqAN22aUzd 1035 d2/1024 
+			QmaRGe7bVmVaLmxbrMiVNXqW4pRNNp3xq7hFtyRKA3mtJL 14   d2/a    
+			QmeomffUNfmQy76CQGy9NdmqEnnHU9soCexBnGU3ezPHVH 13   f1      
+			QmNtocSs7MoDkJMc1RkyisCSKvLadujPsfJfSdJ3e1eA1M 13   f2      
+		EOF
+		test_cmp expected_ls_recursive actual_ls_recursive
+	'
+
+	test_expect_success "'ipfs ls --offset --limit <dir hash>' succeeds" '
+		ipfs ls --offset=1 --limit=2 QmfNy183bXiRVyrhyWtq3TwHn79yHEkiAGFr18P7YNzESj >actual_ls_page
+	'
+
+	test_expect_success "'ipfs ls --offset --limit <dir hash>' output looks good" '
+		cat <<-\EOF >expected_ls_page &&
+			QmR3jhV4XpxxPjPT3Y8vNnWvWNvakdcT3H6vqpRBsX1MLy 1143 d2/ 
+			QmeomffUNfmQy76CQGy9NdmqEnnHU9soCexBnGU3ezPHVH 13   f1  
+		EOF
+		test_cmp expected_ls_page actual_ls_page
+	'
 }
 
 # should work offline
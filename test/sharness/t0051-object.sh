@@ -94,6 +94,147 @@ test_object_cmd() {
 		test_cmp expected_putBroken actual_putBroken &&
 		test_cmp expected_putBrokenErr actual_putBrokenErr
 	'
+
+	test_expect_success "'ipfs object get --enc=protobuf' matches the block" '
+		printf "\000\001\377binary" >binary_in &&
+		BINHASH=$(ipfs add -q binary_in) &&
+		ipfs object get --enc=protobuf $BINHASH >actual_pb &&
+		ipfs block get $BINHASH >expected_pb &&
+		test_cmp expected_pb actual_pb
+	'
+
+	test_expect_success "'ipfs object get/put' roundtrips through cbor" '
+		ipfs object get --enc=cbor $BINHASH >binary.cbor &&
+		ipfs object put --inputenc=cbor binary.cbor >actual_cbor &&
+		printf "added $BINHASH" >expected_cbor &&
+		test_cmp expected_cbor actual_cbor
+	'
+
+	test_expect_success "'ipfs object get/put' roundtrips through base64 json" '
+		ipfs object get --datafieldenc=base64 $BINHASH >binary.json &&
+		ipfs object put --datafieldenc=base64 binary.json >actual_b64 &&
+		printf "added $BINHASH" >expected_b64 &&
+		test_cmp expected_b64 actual_b64
+	'
+
+	test_expect_success "'ipfs object get' with an unknown data field encoding fails" '
+		test_must_fail ipfs object get --datafieldenc=hex $BINHASH
+	'
+
+	test_expect_success "'ipfs object new' succeeds" '
+		ipfs object new >actual_new
+	'
+
+	test_expect_success "'ipfs object new' output looks good" '
+		echo "QmdfTbBqBPQ7VNxZEYEj14VmRuZBkqFbiwReogJgS1zR1n" >expected_new &&
+		test_cmp expected_new actual_new
+	'
+
+	test_expect_success "'ipfs object new unixfs-dir' succeeds" '
+		EMPTY_DIR=$(ipfs object new unixfs-dir) &&
+		test "$EMPTY_DIR" = "QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn"
+	'
+
+	test_expect_success "'ipfs object patch add-link' fails without --create" '
+		printf "bar" >bar &&
+		BAR=$(ipfs add -q bar) &&
+		test_must_fail ipfs object patch $EMPTY_DIR add-link foo/bar $BAR
+	'
+
+	test_expect_success "'ipfs object patch add-link -p' succeeds" '
+		PATCHED=$(ipfs object patch $EMPTY_DIR add-link -p foo/bar $BAR)
+	'
+
+	test_expect_success "'ipfs object patch add-link -p' output looks good" '
+		ipfs cat $PATCHED/foo/bar >actual_patched &&
+		test_cmp bar actual_patched
+	'
+
+	test_expect_success "'ipfs object patch set-data' and 'append-data' succeed" '
+		DATA=$(ipfs object patch $PATCHED set-data foo/bar hello) &&
+		DATA=$(ipfs object patch $DATA append-data foo/bar ", world") &&
+		ipfs object data $DATA/foo/bar >actual_data &&
+		printf "hello, world" >expected_data &&
+		test_cmp expected_data actual_data
+	'
+
+	test_expect_success "'ipfs object patch rm-link' succeeds" '
+		REMOVED=$(ipfs object patch $PATCHED rm-link foo/bar) &&
+		ipfs object patch $EMPTY_DIR add-link foo $EMPTY_DIR >expected_rm &&
+		echo $REMOVED >actual_rm &&
+		test_cmp expected_rm actual_rm
+	'
+
+	test_expect_success "'ipfs object patch' with an unknown command fails" '
+		test_must_fail ipfs object patch $PATCHED frob foo
+	'
+
+	test_expect_success "'ipfs object diff' succeeds" '
+		ipfs object diff $EMPTY_DIR $PATCHED >actual_diff
+	'
+
+	test_expect_success "'ipfs object diff' output looks good" '
+		FOO=$(ipfs object patch $EMPTY_DIR add-link bar $BAR) &&
+		echo "+ foo $FOO" >expected_diff &&
+		test_cmp expected_diff actual_diff
+	'
+
+	test_expect_success "'ipfs object diff --unixfs' output looks good" '
+		ipfs object diff --unixfs $PATCHED $EMPTY_DIR >actual_diff &&
+		echo "- foo/bar $BAR" >expected_diff &&
+		test_cmp expected_diff actual_diff
+	'
+
+	test_expect_success "'ipfs object diff' of the same object is empty" '
+		ipfs object diff $PATCHED $PATCHED >actual_diff &&
+		test_must_be_empty actual_diff
+	'
+
+	test_expect_success "'ipfs object proof' succeeds" '
+		ipfs object proof $PATCHED/foo/bar >actual_proof
+	'
+
+	test_expect_success "'ipfs object proof' output looks good" '
+		FOO=$(ipfs object patch $EMPTY_DIR add-link bar $BAR) &&
+		cut -d" " -f1 actual_proof >actual_keys &&
+		printf "%s\n" $PATCHED $FOO $BAR >expected_keys &&
+		test_cmp expected_keys actual_keys
+	'
+
+	test_expect_success "'ipfs object proof' of a missing path fails" '
+		test_must_fail ipfs object proof $PATCHED/foo/nope
+	'
+
+	test_expect_success "'ipfs object new symlink' succeeds" '
+		ABS=$(ipfs object new symlink /ipfs/$PATCHED/foo) &&
+		REL=$(ipfs object new symlink ../foo/bar) &&
+		LINKS=$(ipfs object patch $PATCHED add-link -p abs $ABS) &&
+		LINKS=$(ipfs object patch $LINKS add-link -p dir/rel $REL)
+	'
+
+	test_expect_success "'ipfs cat' follows symlinks" '
+		ipfs cat $LINKS/abs/bar >actual_abs &&
+		ipfs cat /ipfs/$LINKS/dir/rel >actual_rel &&
+		printf "bar" >expected_link &&
+		test_cmp expected_link actual_abs &&
+		test_cmp expected_link actual_rel
+	'
+
+	test_expect_success "'ipfs object data' does not follow a symlink at the end" '
+		ipfs object data $LINKS/dir/rel >actual_link_data &&
+		grep -q "../foo/bar" actual_link_data
+	'
+
+	test_expect_success "'ipfs cat' fails on a symlink loop" '
+		LOOP=$(ipfs object new symlink loop) &&
+		LOOPS=$(ipfs object patch $EMPTY_DIR add-link loop $LOOP) &&
+		test_must_fail ipfs cat $LOOPS/loop 2>loop_err &&
+		grep "depth limit" loop_err
+	'
+
+	test_expect_success "'ipfs object new symlink' needs a target" '
+		test_must_fail ipfs object new symlink
+	'
 }
 
 # should work offline
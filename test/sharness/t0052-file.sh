@@ -0,0 +1,68 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test file write command"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_file_cmd() {
+
+	test_expect_success "'ipfs file write' setup succeeds" '
+		printf "0123456789" >file &&
+		printf "abc" >data &&
+		FILE=$(ipfs add -q file) &&
+		EMPTY_DIR=$(ipfs object new unixfs-dir) &&
+		ROOT=$(ipfs object patch $EMPTY_DIR add-link -p dir/file $FILE)
+	'
+
+	test_expect_success "'ipfs file write' succeeds" '
+		ipfs file write /ipfs/$ROOT/dir/file --offset=4 data >actual_hash
+	'
+
+	test_expect_success "'ipfs file write' output looks good" '
+		NEWROOT=$(cat actual_hash) &&
+		ipfs cat $NEWROOT/dir/file >actual &&
+		printf "0123abc789" >expected &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs file write --truncate' cuts the file off" '
+		NEWROOT=$(ipfs file write $ROOT/dir/file --offset=4 --truncate data) &&
+		ipfs cat $NEWROOT/dir/file >actual &&
+		printf "0123abc" >expected &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs file write' past the end appends" '
+		NEWROOT=$(ipfs file write $FILE --offset=10 data) &&
+		ipfs cat $NEWROOT >actual &&
+		printf "0123456789abc" >expected &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs file write' reads data from stdin" '
+		NEWROOT=$(cat data | ipfs file write $FILE) &&
+		ipfs cat $NEWROOT >actual &&
+		printf "abc3456789" >expected &&
+		test_cmp expected actual
+	'
+
+	test_expect_success "'ipfs file write' to a directory fails" '
+		test_must_fail ipfs file write $ROOT/dir data 2>actual_err &&
+		grep "path does not name a file" actual_err
+	'
+}
+
+# should work offline
+test_file_cmd
+
+# should work online
+test_launch_ipfs_daemon
+test_file_cmd
+test_kill_ipfs_daemon
+
+test_done
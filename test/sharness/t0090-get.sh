@@ -39,8 +39,39 @@ test_get_cmd() {
 	
 	test_expect_success "ipfs get errors when trying to overwrite a file" '
 	  test_must_fail ipfs get "$HASH" >actual &&
+	  test_cmp "$HASH" data
+	'
+
+	test_expect_success "ipfs get --continue skips a file that is already there" '
+	  ipfs get --continue "$HASH" >actual &&
+	  test_cmp "$HASH" data
+	'
+
+	test_expect_success "ipfs get --continue continues a partial file" '
+	  head -c 5 data >"$HASH" &&
+	  ipfs get --continue "$HASH" >actual &&
+	  test_cmp "$HASH" data
+	'
+
+	test_expect_success "ipfs get --continue errors on a different file" '
+	  echo "Hello" >"$HASH" &&
+	  test_must_fail ipfs get --continue "$HASH" >actual &&
+	  echo "Hello" >expected &&
+	  test_cmp expected "$HASH"
+	'
+
+	test_expect_success "ipfs get --force overwrites a file" '
+	  ipfs get --force "$HASH" >actual &&
+	  test_cmp "$HASH" data &&
 	  rm "$HASH"
 	'
+
+	test_expect_success "ipfs get stores the output inside an existing directory" '
+	  mkdir "$HASH" &&
+	  ipfs get "$HASH" >actual &&
+	  test_cmp "$HASH"/"$HASH" data &&
+	  rm -r "$HASH"
+	'
 	
 	test_expect_success "ipfs get -a succeeds" '
 	  ipfs get "$HASH" -a >actual
@@ -76,6 +107,20 @@ test_get_cmd() {
 	  rm "$HASH"
 	'
 	
+	test_expect_success "ipfs get -a=zip succeeds" '
+	  ipfs get "$HASH" -a=zip >actual
+	'
+
+	test_expect_success "ipfs get -a=zip output looks good" '
+	  printf "%s\n\n" "Saving archive to $HASH.zip" >expected &&
+	  test_cmp expected actual &&
+	  rm "$HASH".zip
+	'
+
+	test_expect_success "ipfs get errors on unknown archive formats" '
+	  test_must_fail ipfs get "$HASH" -a=rar
+	'
+
 	test_expect_success "ipfs get succeeds (directory)" '
 	  mkdir -p dir &&
 	  touch dir/a &&
@@ -96,6 +141,16 @@ test_get_cmd() {
 	  rm -r "$HASH2"
 	'
 	
+	test_expect_success "ipfs get --include succeeds (directory)" '
+	  ipfs get "$HASH2" --include=b >actual
+	'
+
+	test_expect_success "ipfs get --include output is valid (directory)" '
+	  test_cmp dir/b/c "$HASH2"/b/c &&
+	  test ! -e "$HASH2"/a &&
+	  rm -r "$HASH2"
+	'
+
 	test_expect_success "ipfs get -a -C succeeds (directory)" '
 	  ipfs get "$HASH2" -a -C >actual
 	'
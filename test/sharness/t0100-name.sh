@@ -52,4 +52,50 @@ test_expect_success "resolve output looks good" '
 	test_cmp output expected4
 '
 
+# test record lifetimes
+
+test_expect_success "'ipfs name publish --lifetime --ttl' succeeds" '
+	ipfs name publish --lifetime=1h --ttl=30s "$HASH_WELCOME_DOCS" >publish_out &&
+	test_cmp expected1 publish_out
+'
+
+test_expect_success "'ipfs name resolve' finds it" '
+	ipfs name resolve "$PEERID" >output &&
+	test_cmp expected2 output
+'
+
+test_expect_success "'ipfs name publish' fails with a bad lifetime" '
+	test_must_fail ipfs name publish --lifetime=forever "$HASH_WELCOME_DOCS" 2>publish_err &&
+	grep "invalid lifetime" publish_err
+'
+
+test_expect_success "'ipfs name publish' takes the lifetime from the config" '
+	ipfs config Ipns.RecordLifetime "1h" &&
+	ipfs name publish "$HASH_HELP_PAGE" >publish_out &&
+	test_cmp expected3 publish_out
+'
+
+# test following names
+
+test_expect_success "'ipfs name resolve -r' prints every step" '
+	ipfs name resolve -r "$PEERID" >output &&
+	echo "/ipfs/$HASH_HELP_PAGE" >expected5 &&
+	test_cmp expected5 output
+'
+
+test_expect_success "'ipfs name resolve --depth=0' fails" '
+	test_must_fail ipfs name resolve --depth=0 "$PEERID"
+'
+
+test_expect_success "'ipfs name publish' of a name succeeds" '
+	ipfs name publish "/ipns/$PEERID" >publish_out &&
+	echo "Published name $PEERID to /ipns/$PEERID" >expected6 &&
+	test_cmp expected6 publish_out
+'
+
+test_expect_success "'ipfs name resolve' finds the loop" '
+	test_must_fail ipfs name resolve "$PEERID" 2>resolve_err &&
+	grep "loop detected" resolve_err
+'
+
 test_done
@@ -49,6 +49,30 @@ test_expect_success "GET IPFS directory file output looks good" '
   test_cmp dir/test actual
 '
 
+test_expect_success "GET IPFS directory as tar succeeds" '
+  curl -sf -D headers -o dir.tar "http://127.0.0.1:$port/ipfs/$HASH2?download=tar"
+'
+
+test_expect_success "GET IPFS directory as tar output looks good" '
+  grep "Content-Disposition: attachment; filename=$HASH2.tar" headers &&
+  tar -xf dir.tar &&
+  test_cmp dir/test "$HASH2"/test &&
+  rm -r dir.tar "$HASH2"
+'
+
+test_expect_success "GET IPFS directory as zip succeeds" '
+  curl -sf -D headers -o dir.zip "http://127.0.0.1:$port/ipfs/$HASH2?download=zip"
+'
+
+test_expect_success "GET IPFS directory as zip has the right headers" '
+  grep "Content-Type: application/zip" headers &&
+  grep "Content-Disposition: attachment; filename=$HASH2.zip" headers
+'
+
+test_expect_success "GET with an unknown download format errors" '
+  test_must_fail curl -sf "http://127.0.0.1:$port/ipfs/$HASH2?download=rar"
+'
+
 test_expect_failure "GET IPNS path succeeds" '
   ipfs name publish "$HASH" &&
   NAME=$(ipfs config Identity.PeerID) &&
@@ -97,6 +97,42 @@ test_expect_success "We can HTTP GET file just created" '
   test_cmp infile outfile
 '
 
+test_expect_success "HTTP PUT with Content-Range writes into the file" '
+  printf "XYZ" >range &&
+  URL="http://localhost:$port/ipfs/$HASH/test.txt" &&
+  echo "PUT $URL" &&
+  curl -svX PUT -H "Content-Range: bytes 2-4/*" --data-binary @range "$URL" 2>curl.out &&
+  grep "HTTP/1.1 201 Created" curl.out &&
+  LOCATION=$(grep Location curl.out) &&
+  HASH=$(expr "$LOCATION" : "< Location: /ipfs/\(.*\)/test.txt")
+'
+
+test_expect_success "We can HTTP GET the file just written to" '
+  URL="http://localhost:$port/ipfs/$HASH/test.txt" &&
+  echo "GET $URL" &&
+  curl -so outfile "$URL" &&
+  head -c 2 infile >expected &&
+  cat range >>expected &&
+  tail -c +6 infile >>expected &&
+  test_cmp expected outfile
+'
+
+test_expect_success "HTTP PUT with a Content-Range size resizes the file" '
+  URL="http://localhost:$port/ipfs/$HASH/test.txt" &&
+  curl -svX PUT -H "Content-Range: bytes 0-2/3" --data-binary @range "$URL" 2>curl.out &&
+  grep "HTTP/1.1 201 Created" curl.out &&
+  LOCATION=$(grep Location curl.out) &&
+  HASH=$(expr "$LOCATION" : "< Location: /ipfs/\(.*\)/test.txt") &&
+  curl -so outfile "http://localhost:$port/ipfs/$HASH/test.txt" &&
+  test_cmp range outfile
+'
+
+test_expect_success "HTTP PUT with a short body for its Content-Range fails" '
+  URL="http://localhost:$port/ipfs/$HASH/test.txt" &&
+  curl -svX PUT -H "Content-Range: bytes 0-9/*" --data-binary @range "$URL" 2>curl.out &&
+  grep "HTTP/1.1 400 Bad Request" curl.out
+'
+
 test_kill_ipfs_daemon
 
 test_done
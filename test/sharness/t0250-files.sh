@@ -0,0 +1,95 @@
+#!/bin/sh
+#
+# MIT Licensed; see the LICENSE file in this repository.
+#
+
+test_description="Test the mutable filesystem commands"
+
+. lib/test-lib.sh
+
+test_init_ipfs
+
+test_expect_success "'ipfs files' fails offline" '
+	test_must_fail ipfs files ls 2>actual_err &&
+	grep "must be run in online mode" actual_err
+'
+
+test_launch_ipfs_daemon
+
+test_expect_success "'ipfs files mkdir' succeeds" '
+	ipfs files mkdir /dir &&
+	ipfs files mkdir -p /dir/a/b
+'
+
+test_expect_success "'ipfs files mkdir' of an existing directory fails" '
+	test_must_fail ipfs files mkdir /dir
+'
+
+test_expect_success "'ipfs files ls' output looks good" '
+	ipfs files ls /dir >actual &&
+	echo a >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs files cp' from /ipfs/ succeeds" '
+	echo "some content" >file &&
+	FILE=$(ipfs add -q file) &&
+	ipfs files cp /ipfs/$FILE /dir/file &&
+	ipfs files read /dir/file >actual &&
+	test_cmp file actual
+'
+
+test_expect_success "'ipfs files stat' output looks good" '
+	ipfs files stat /dir/file >actual &&
+	head -1 actual >actual_hash &&
+	echo $FILE >expected_hash &&
+	test_cmp expected_hash actual_hash &&
+	grep "Type: file" actual &&
+	grep "Size: 13" actual
+'
+
+test_expect_success "'ipfs files write' succeeds" '
+	printf "SOME" >data &&
+	ipfs files write /dir/file data &&
+	ipfs files write --offset=13 /dir/file data &&
+	ipfs files read /dir/file >actual &&
+	printf "SOME content\\nSOME" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs files write --create --truncate' succeeds" '
+	ipfs files write --create /dir/new data &&
+	ipfs files write --truncate --offset=2 /dir/new data &&
+	ipfs files read /dir/new >actual &&
+	printf "SOSOME" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs files read --offset --count' succeeds" '
+	ipfs files read --offset=2 --count=3 /dir/new >actual &&
+	printf "SOM" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs files mv' succeeds" '
+	ipfs files mv /dir/new /dir/a &&
+	ipfs files mv /dir/file /moved &&
+	ipfs files ls /dir/a >actual &&
+	printf "b\\nnew\\n" >expected &&
+	test_cmp expected actual &&
+	ipfs files ls / >actual &&
+	printf "dir\\nmoved\\n" >expected &&
+	test_cmp expected actual
+'
+
+test_expect_success "'ipfs files rm' of a directory needs -r" '
+	test_must_fail ipfs files rm /dir &&
+	ipfs files rm -r /dir &&
+	ipfs files ls / >actual &&
+	echo moved >expected &&
+	test_cmp expected actual
+'
+
+test_kill_ipfs_daemon
+
+test_done
@@ -2,33 +2,51 @@ package tar
 
 import (
 	"archive/tar"
+	"fmt"
 	"io"
 	"os"
 	fp "path/filepath"
+	"strconv"
 	"strings"
 )
 
+// OffsetRecord is the PAX record marking an entry that continues a file
+// which was partially written before. Its value is the offset, in bytes, the
+// entry's data starts at.
+const OffsetRecord = "IPFS.offset"
+
+// Extractor writes the contents of a tar archive to Path. The first entry of
+// the archive is the root. It is written to Path itself, or inside of it
+// under its own name if Path is an existing directory. A root file already
+// present is an error unless Overwrite is set, files below the root are
+// overwritten.
+//
+// With Continue set, the root is always written to Path itself. Entries
+// with an OffsetRecord continue the file already present, and other files
+// already present are an error unless Overwrite is set.
 type Extractor struct {
-	Path string
+	Path      string
+	Continue  bool
+	Overwrite bool
 }
 
 func (te *Extractor) Extract(reader io.Reader) error {
 	tarReader := tar.NewReader(reader)
 
-	// Check if the output path already exists, so we know whether we should
-	// create our output with that name, or if we should put the output inside
-	// a preexisting directory
-	exists := true
-	pathIsDir := false
-	if stat, err := os.Stat(te.Path); err != nil && os.IsNotExist(err) {
-		exists = false
-	} else if err != nil {
-		return err
-	} else if stat.IsDir() {
-		pathIsDir = true
+	// Check if the output path is a preexisting directory, so we know
+	// whether we should put the output inside of it
+	inside := false
+	if !te.Continue {
+		stat, err := os.Stat(te.Path)
+		switch {
+		case err == nil:
+			inside = stat.IsDir()
+		case !os.IsNotExist(err):
+			return err
+		}
 	}
 
-	// files come recursively in order (i == 0 is root directory)
+	// files come recursively in order (i == 0 is the root)
 	for i := 0; ; i++ {
 		header, err := tarReader.Next()
 		if err != nil && err != io.EOF {
@@ -38,15 +56,21 @@ func (te *Extractor) Extract(reader io.Reader) error {
 			break
 		}
 
+		path, err := te.outputPath(header.Name, inside)
+		if err != nil {
+			return err
+		}
+
 		if header.Typeflag == tar.TypeDir {
-			err = te.extractDir(header, i, exists)
+			err = os.MkdirAll(path, 0755)
 			if err != nil {
 				return err
 			}
 			continue
 		}
 
-		err = te.extractFile(header, tarReader, i, exists, pathIsDir)
+		excl := !te.Overwrite && (te.Continue || i == 0 && !inside)
+		err = te.extractFile(header, tarReader, path, excl)
 		if err != nil {
 			return err
 		}
@@ -54,55 +78,73 @@ func (te *Extractor) Extract(reader io.Reader) error {
 	return nil
 }
 
-func (te *Extractor) extractDir(h *tar.Header, depth int, exists bool) error {
-	pathElements := strings.Split(h.Name, "/")
-	if !exists {
+// outputPath maps the name of an entry to the path it is written to, which
+// must not be outside of te.Path. The name of the root is kept if the output
+// goes inside te.Path.
+func (te *Extractor) outputPath(name string, inside bool) (string, error) {
+	pathElements := strings.Split(name, "/")
+	if !inside {
 		pathElements = pathElements[1:]
 	}
-	path := fp.Join(pathElements...)
-	path = fp.Join(te.Path, path)
-	if depth == 0 {
-		// if this is the root root directory, use it as the output path for remaining files
-		te.Path = path
+	rel := fp.Join(pathElements...)
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(fp.Separator)) {
+		return "", fmt.Errorf("tar entry %q points outside of the output path", name)
 	}
+	return fp.Join(te.Path, rel), nil
+}
 
-	err := os.MkdirAll(path, 0755)
-	if err != nil {
-		return err
+// extractFile writes the file of h to path. With excl, path must not exist
+// yet, unless the file is continued.
+func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, path string, excl bool) error {
+	var offset int64
+	if v, ok := h.PAXRecords[OffsetRecord]; ok {
+		var err error
+		offset, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || offset < 0 {
+			return fmt.Errorf("invalid offset for tar entry %q: %s", h.Name, v)
+		}
 	}
 
-	return nil
-}
-
-func (te *Extractor) extractFile(h *tar.Header, r *tar.Reader, depth int, exists bool, pathIsDir bool) error {
-	var path string
-	if depth == 0 {
-		// if depth is 0, this is the only file (we aren't 'ipfs get'ing a directory)
-		switch {
-		case exists && !pathIsDir:
-			return os.ErrExist
-		case exists && pathIsDir:
-			path = fp.Join(te.Path, h.Name)
-		case !exists:
-			path = te.Path
+	if offset == 0 {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if excl {
+			flags |= os.O_EXCL
 		}
-	} else {
-		// we are outputting a directory, this file is inside of it
-		pathElements := strings.Split(h.Name, "/")[1:]
-		path = fp.Join(pathElements...)
-		path = fp.Join(te.Path, path)
+		file, err := os.OpenFile(path, flags, 0666)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(file, r)
+		return err
 	}
 
-	file, err := os.Create(path)
+	file, err := os.OpenFile(path, os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, r)
+	info, err := file.Stat()
 	if err != nil {
 		return err
 	}
+	if info.Size() < offset {
+		return fmt.Errorf("%s changed while it was being continued", path)
+	}
 
-	return nil
+	// anything past the offset was not confirmed by the sender, drop it
+	err = file.Truncate(offset)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Seek(offset, os.SEEK_SET)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	return err
 }
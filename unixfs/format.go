@@ -14,6 +14,8 @@ const (
 	TFile      = pb.Data_File
 	TDirectory = pb.Data_Directory
 	TMetadata  = pb.Data_Metadata
+	THAMTShard = pb.Data_HAMTShard
+	TSymlink   = pb.Data_Symlink
 )
 
 var ErrMalformedFileFormat = errors.New("malformed data in file format")
@@ -62,6 +64,26 @@ func FolderPBData() []byte {
 	return data
 }
 
+// SymlinkData returns the Bytes of a symlink to target
+func SymlinkData(target string) ([]byte, error) {
+	pbfile := new(pb.Data)
+	typ := pb.Data_Symlink
+	pbfile.Type = &typ
+	pbfile.Data = []byte(target)
+
+	return proto.Marshal(pbfile)
+}
+
+// SymlinkTarget returns the target of the symlink in data, and false if
+// data is not a symlink
+func SymlinkTarget(data []byte) (string, bool) {
+	pbdata, err := FromBytes(data)
+	if err != nil || pbdata.GetType() != pb.Data_Symlink {
+		return "", false
+	}
+	return string(pbdata.GetData()), true
+}
+
 func WrapData(b []byte) []byte {
 	pbdata := new(pb.Data)
 	typ := pb.Data_Raw
@@ -94,7 +116,7 @@ func DataSize(data []byte) (uint64, error) {
 	}
 
 	switch pbdata.GetType() {
-	case pb.Data_Directory:
+	case pb.Data_Directory, pb.Data_HAMTShard:
 		return 0, errors.New("Cant get data size of directory!")
 	case pb.Data_File:
 		return pbdata.GetFilesize(), nil
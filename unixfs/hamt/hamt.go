@@ -0,0 +1,448 @@
+// package hamt implements a Hash Array Mapped Trie over merkledag nodes.
+//
+// It is used to represent unixfs directories whose entries would not fit in a
+// single block. Every node of the trie (a shard) is a unixfs node of type
+// HAMTShard. Its Data field holds a bitfield of the occupied slots, and its
+// links are stored in slot order. The name of each link starts with the slot
+// index, as an upper case hex string padded to a fixed width. Links to
+// directory entries carry the entry name after that prefix. Links to
+// sub-shards consist of the prefix alone.
+package hamt
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"sort"
+	"strconv"
+
+	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	upb "github.com/ipfs/go-ipfs/unixfs/pb"
+)
+
+const (
+	// HashFnv1a64 identifies the 64 bit FNV-1a hash, used to place names
+	// in the trie.
+	HashFnv1a64 = 0x1
+
+	// DefaultShardWidth is the number of slots in each shard.
+	DefaultShardWidth = 256
+)
+
+var ErrNotShard = errors.New("hamt: node is not a HAMT shard")
+var ErrHashExhausted = errors.New("hamt: ran out of hash bits, too many collisions")
+var ErrInvalidShard = errors.New("hamt: malformed shard node")
+
+// Shard is a single node of the trie. It holds a sorted list of its occupied
+// slots, each of which is either a directory entry or a sub-shard.
+type Shard struct {
+	children []*child
+
+	tableSize    int
+	tableSizeLg2 int
+	prefixLen    int
+
+	dserv dag.DAGService
+}
+
+// child is an occupied slot of a shard. Sub-shards read from the dag are
+// only loaded when they are first needed.
+type child struct {
+	idx int
+
+	// set for directory entries
+	name string
+
+	// the link to the entry, or to the sub-shard as it was last read
+	lnk *dag.Link
+
+	isShard bool
+	shard   *Shard
+}
+
+// NewShard creates an empty shard with the given number of slots, which must
+// be a power of two.
+func NewShard(dserv dag.DAGService, size int) (*Shard, error) {
+	lg2 := 0
+	for 1<<uint(lg2) < size {
+		lg2++
+	}
+	if size < 2 || size > 1<<16 || 1<<uint(lg2) != size {
+		return nil, fmt.Errorf("hamt: invalid shard width %d, must be a power of two", size)
+	}
+
+	return &Shard{
+		tableSize:    size,
+		tableSizeLg2: lg2,
+		prefixLen:    len(fmt.Sprintf("%X", size-1)),
+		dserv:        dserv,
+	}, nil
+}
+
+// IsShard returns whether the given node is a HAMT shard.
+func IsShard(nd *dag.Node) bool {
+	pbd, err := ft.FromBytes(nd.Data)
+	if err != nil {
+		return false
+	}
+	return pbd.GetType() == upb.Data_HAMTShard
+}
+
+// NewHamtFromDag reads the shard stored in the given dag node.
+func NewHamtFromDag(dserv dag.DAGService, nd *dag.Node) (*Shard, error) {
+	pbd, err := ft.FromBytes(nd.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if pbd.GetType() != upb.Data_HAMTShard {
+		return nil, ErrNotShard
+	}
+
+	if pbd.GetHashType() != HashFnv1a64 {
+		return nil, fmt.Errorf("hamt: unsupported hash function %d", pbd.GetHashType())
+	}
+
+	s, err := NewShard(dserv, int(pbd.GetFanout()))
+	if err != nil {
+		return nil, err
+	}
+
+	bitfield := new(big.Int).SetBytes(pbd.GetData())
+	last := -1
+	for _, lnk := range nd.Links {
+		if len(lnk.Name) < s.prefixLen {
+			return nil, ErrInvalidShard
+		}
+
+		idx, err := strconv.ParseUint(lnk.Name[:s.prefixLen], 16, 32)
+		if err != nil {
+			return nil, ErrInvalidShard
+		}
+
+		if int(idx) <= last || int(idx) >= s.tableSize || bitfield.Bit(int(idx)) != 1 {
+			return nil, ErrInvalidShard
+		}
+		last = int(idx)
+
+		c := &child{
+			idx: int(idx),
+			lnk: &dag.Link{Size: lnk.Size, Hash: lnk.Hash},
+		}
+		if len(lnk.Name) == s.prefixLen {
+			c.isShard = true
+		} else {
+			c.name = lnk.Name[s.prefixLen:]
+			c.lnk.Name = c.name
+		}
+		s.children = append(s.children, c)
+	}
+
+	return s, nil
+}
+
+// Node serializes the shard into a dag node. Modified sub-shards are added to
+// the DAGService, the returned node itself is not.
+func (s *Shard) Node() (*dag.Node, error) {
+	nd := new(dag.Node)
+	bitfield := new(big.Int)
+
+	for _, c := range s.children {
+		bitfield.SetBit(bitfield, c.idx, 1)
+		prefix := s.linkPrefix(c.idx)
+
+		if !c.isShard {
+			err := nd.AddRawLink(prefix+c.name, c.lnk)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if c.shard == nil {
+			// never loaded, so it cannot have changed
+			err := nd.AddRawLink(prefix, c.lnk)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		cnd, err := c.shard.Node()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = s.dserv.Add(cnd)
+		if err != nil {
+			return nil, err
+		}
+
+		err = nd.AddNodeLinkClean(prefix, cnd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	typ := upb.Data_HAMTShard
+	pbd := &upb.Data{
+		Type:     &typ,
+		Data:     bitfield.Bytes(),
+		HashType: proto.Uint64(HashFnv1a64),
+		Fanout:   proto.Uint64(uint64(s.tableSize)),
+	}
+
+	data, err := proto.Marshal(pbd)
+	if err != nil {
+		return nil, err
+	}
+	nd.Data = data
+
+	return nd, nil
+}
+
+// Set adds the node under the given name, replacing any previous entry
+func (s *Shard) Set(ctx context.Context, name string, nd *dag.Node) error {
+	lnk, err := dag.MakeLink(nd)
+	if err != nil {
+		return err
+	}
+	return s.SetLink(ctx, name, lnk)
+}
+
+// SetLink adds a copy of the link under the given name, replacing any
+// previous entry
+func (s *Shard) SetLink(ctx context.Context, name string, lnk *dag.Link) error {
+	nlnk := &dag.Link{
+		Name: name,
+		Size: lnk.Size,
+		Hash: lnk.Hash,
+	}
+	return s.set(ctx, hashName(name), 0, name, nlnk)
+}
+
+func (s *Shard) set(ctx context.Context, hv uint64, depth int, name string, lnk *dag.Link) error {
+	idx, err := s.index(hv, depth)
+	if err != nil {
+		return err
+	}
+
+	i, found := s.slot(idx)
+	if !found {
+		c := &child{idx: idx, name: name, lnk: lnk}
+		s.children = append(s.children, nil)
+		copy(s.children[i+1:], s.children[i:])
+		s.children[i] = c
+		return nil
+	}
+
+	c := s.children[i]
+	if c.isShard {
+		sub, err := s.loadChild(ctx, c)
+		if err != nil {
+			return err
+		}
+		return sub.set(ctx, hv, depth+1, name, lnk)
+	}
+
+	if c.name == name {
+		c.lnk = lnk
+		return nil
+	}
+
+	// two names share this slot, push both of them down into a new shard
+	sub, err := NewShard(s.dserv, s.tableSize)
+	if err != nil {
+		return err
+	}
+
+	err = sub.set(ctx, hashName(c.name), depth+1, c.name, c.lnk)
+	if err != nil {
+		return err
+	}
+
+	err = sub.set(ctx, hv, depth+1, name, lnk)
+	if err != nil {
+		return err
+	}
+
+	s.children[i] = &child{idx: idx, isShard: true, shard: sub}
+	return nil
+}
+
+// Find returns a copy of the link for the entry with the given name, or
+// merkledag.ErrNotFound.
+func (s *Shard) Find(ctx context.Context, name string) (*dag.Link, error) {
+	return s.find(ctx, hashName(name), 0, name)
+}
+
+func (s *Shard) find(ctx context.Context, hv uint64, depth int, name string) (*dag.Link, error) {
+	idx, err := s.index(hv, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	i, found := s.slot(idx)
+	if !found {
+		return nil, dag.ErrNotFound
+	}
+
+	c := s.children[i]
+	if c.isShard {
+		sub, err := s.loadChild(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return sub.find(ctx, hv, depth+1, name)
+	}
+
+	if c.name != name {
+		return nil, dag.ErrNotFound
+	}
+
+	return &dag.Link{
+		Name: c.name,
+		Size: c.lnk.Size,
+		Hash: c.lnk.Hash,
+	}, nil
+}
+
+// Remove deletes the entry with the given name, returning
+// merkledag.ErrNotFound if there is none.
+func (s *Shard) Remove(ctx context.Context, name string) error {
+	return s.remove(ctx, hashName(name), 0, name)
+}
+
+func (s *Shard) remove(ctx context.Context, hv uint64, depth int, name string) error {
+	idx, err := s.index(hv, depth)
+	if err != nil {
+		return err
+	}
+
+	i, found := s.slot(idx)
+	if !found {
+		return dag.ErrNotFound
+	}
+
+	c := s.children[i]
+	if !c.isShard {
+		if c.name != name {
+			return dag.ErrNotFound
+		}
+		s.children = append(s.children[:i], s.children[i+1:]...)
+		return nil
+	}
+
+	sub, err := s.loadChild(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	err = sub.remove(ctx, hv, depth+1, name)
+	if err != nil {
+		return err
+	}
+
+	// a sub-shard left with a single entry is folded back into this slot
+	if len(sub.children) == 1 && !sub.children[0].isShard {
+		last := sub.children[0]
+		s.children[i] = &child{idx: idx, name: last.name, lnk: last.lnk}
+	}
+	return nil
+}
+
+// ForEachLink calls f with a copy of the link of every entry in the trie,
+// in trie order.
+func (s *Shard) ForEachLink(ctx context.Context, f func(*dag.Link) error) error {
+	for _, c := range s.children {
+		if c.isShard {
+			sub, err := s.loadChild(ctx, c)
+			if err != nil {
+				return err
+			}
+
+			err = sub.ForEachLink(ctx, f)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := f(&dag.Link{
+			Name: c.name,
+			Size: c.lnk.Size,
+			Hash: c.lnk.Hash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnumLinks returns copies of the links of every entry in the trie
+func (s *Shard) EnumLinks(ctx context.Context) ([]*dag.Link, error) {
+	var links []*dag.Link
+	err := s.ForEachLink(ctx, func(l *dag.Link) error {
+		links = append(links, l)
+		return nil
+	})
+	return links, err
+}
+
+// loadChild returns the sub-shard in the given slot, fetching it from the
+// DAGService if it has not been read yet
+func (s *Shard) loadChild(ctx context.Context, c *child) (*Shard, error) {
+	if c.shard != nil {
+		return c.shard, nil
+	}
+
+	nd, err := c.lnk.GetNode(ctx, s.dserv)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := NewHamtFromDag(s.dserv, nd)
+	if err != nil {
+		return nil, err
+	}
+
+	c.shard = sub
+	return sub, nil
+}
+
+// slot returns the position of the child with the given index, or the
+// position it should be inserted at.
+func (s *Shard) slot(idx int) (int, bool) {
+	i := sort.Search(len(s.children), func(i int) bool {
+		return s.children[i].idx >= idx
+	})
+	return i, i < len(s.children) && s.children[i].idx == idx
+}
+
+// index returns the slot that the given hash value falls into at the given
+// depth of the trie
+func (s *Shard) index(hv uint64, depth int) (int, error) {
+	off := uint(depth * s.tableSizeLg2)
+	if off+uint(s.tableSizeLg2) > 64 {
+		return 0, ErrHashExhausted
+	}
+
+	shift := 64 - off - uint(s.tableSizeLg2)
+	return int((hv >> shift) & uint64(s.tableSize-1)), nil
+}
+
+func (s *Shard) linkPrefix(idx int) string {
+	return fmt.Sprintf("%0*X", s.prefixLen, idx)
+}
+
+func hashName(name string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return h.Sum64()
+}
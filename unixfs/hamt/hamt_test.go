@@ -0,0 +1,197 @@
+package hamt
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	dag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+func makeShard(t *testing.T, ds dag.DAGService, size, count int) (*Shard, []string) {
+	ctx := context.Background()
+	s, err := NewShard(ds, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("entry-%d", i)
+		nd := &dag.Node{Data: ft.WrapData([]byte(name))}
+		_, err := ds.Add(nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.Set(ctx, name, nd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return s, names
+}
+
+func assertLinks(t *testing.T, s *Shard, names []string) {
+	links, err := s.EnumLinks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found []string
+	for _, l := range links {
+		found = append(found, l.Name)
+	}
+
+	exp := append([]string{}, names...)
+	sort.Strings(exp)
+	sort.Strings(found)
+	if len(exp) != len(found) {
+		t.Fatalf("expected %d entries, got %d", len(exp), len(found))
+	}
+	for i := range exp {
+		if exp[i] != found[i] {
+			t.Fatalf("expected entry %s, got %s", exp[i], found[i])
+		}
+	}
+}
+
+func TestShardSetAndFind(t *testing.T) {
+	ds := mdtest.Mock(t)
+	s, names := makeShard(t, ds, 16, 500)
+
+	for _, name := range names {
+		lnk, err := s.Find(context.Background(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lnk.Name != name {
+			t.Fatalf("expected link named %s, got %s", name, lnk.Name)
+		}
+	}
+
+	_, err := s.Find(context.Background(), "not-there")
+	if err != dag.ErrNotFound {
+		t.Fatal("expected ErrNotFound, got: ", err)
+	}
+
+	assertLinks(t, s, names)
+}
+
+func TestShardRoundtrip(t *testing.T) {
+	ds := mdtest.Mock(t)
+	s, names := makeShard(t, ds, DefaultShardWidth, 2000)
+
+	nd, err := s.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ds.Add(nd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsShard(nd) {
+		t.Fatal("expected serialized node to be a shard")
+	}
+
+	if len(nd.Links) > DefaultShardWidth {
+		t.Fatalf("root shard has %d links, more than its width", len(nd.Links))
+	}
+
+	k, err := nd.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ds.Get(context.Background(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ns, err := NewHamtFromDag(ds, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertLinks(t, ns, names)
+
+	nnd, err := ns.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nk, err := nnd.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if nk != k {
+		t.Fatal("reserialized shard did not match original")
+	}
+}
+
+func TestShardRemove(t *testing.T) {
+	ds := mdtest.Mock(t)
+	ctx := context.Background()
+	s, names := makeShard(t, ds, 8, 300)
+
+	empty, err := NewShard(ds, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names[:150] {
+		lnk, err := s.Find(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = empty.SetLink(ctx, name, lnk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range names[150:] {
+		err := s.Remove(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = s.Remove(ctx, names[200])
+	if err != dag.ErrNotFound {
+		t.Fatal("expected ErrNotFound, got: ", err)
+	}
+
+	assertLinks(t, s, names[:150])
+
+	// removing entries must leave the same structure as never adding them
+	a, err := s.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := empty.Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ak, _ := a.Key()
+	bk, _ := b.Key()
+	if ak != bk {
+		t.Fatal("shard after removal differs from one built without the entries")
+	}
+}
+
+func TestInvalidWidth(t *testing.T) {
+	_, err := NewShard(mdtest.Mock(t), 100)
+	if err == nil {
+		t.Fatal("expected error for width that is not a power of two")
+	}
+}
@@ -12,10 +12,15 @@ import (
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	ft "github.com/ipfs/go-ipfs/unixfs"
 	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
+	u "github.com/ipfs/go-ipfs/util"
 )
 
 var ErrIsDir = errors.New("this dag node is a directory")
 
+// DefaultPrefetchWindow is the number of children a DagReader fetches ahead
+// of the one it is reading from.
+var DefaultPrefetchWindow = 10
+
 // DagReader provides a way to easily read the data contained in a dag.
 type DagReader struct {
 	serv mdag.DAGService
@@ -30,12 +35,19 @@ type DagReader struct {
 	// will either be a bytes.Reader or a child DagReader
 	buf ReadSeekCloser
 
-	// NodeGetters for each of 'nodes' child links
+	// NodeGetters for each of 'nodes' child links, filled in as the read
+	// head moves through the file
 	promises []mdag.NodeGetter
 
 	// the index of the child link currently being read from
 	linkPosition int
 
+	// children before this index have been requested
+	fetched int
+
+	// number of children to request ahead of linkPosition
+	window int
+
 	// current offset for the read head within the 'file'
 	offset int64
 
@@ -44,6 +56,11 @@ type DagReader struct {
 
 	// context cancel for children
 	cancel func()
+
+	// context and cancel for outstanding requests, replaced on seeks. Child
+	// DagReaders are built from it.
+	fetchCtx    context.Context
+	fetchCancel func()
 }
 
 type ReadSeekCloser interface {
@@ -63,13 +80,13 @@ func NewDagReader(ctx context.Context, n *mdag.Node, serv mdag.DAGService) (*Dag
 	}
 
 	switch pb.GetType() {
-	case ftpb.Data_Directory:
+	case ftpb.Data_Directory, ftpb.Data_HAMTShard:
 		// Dont allow reading directories
 		return nil, ErrIsDir
 	case ftpb.Data_Raw:
 		fallthrough
 	case ftpb.Data_File:
-		return newDataFileReader(ctx, n, pb, serv), nil
+		return newDataFileReader(ctx, n, pb, serv, DefaultPrefetchWindow), nil
 	case ftpb.Data_Metadata:
 		if len(n.Links) == 0 {
 			return nil, errors.New("incorrectly formatted metadata object")
@@ -84,18 +101,86 @@ func NewDagReader(ctx context.Context, n *mdag.Node, serv mdag.DAGService) (*Dag
 	}
 }
 
-func newDataFileReader(ctx context.Context, n *mdag.Node, pb *ftpb.Data, serv mdag.DAGService) *DagReader {
+func newDataFileReader(ctx context.Context, n *mdag.Node, pb *ftpb.Data, serv mdag.DAGService, window int) *DagReader {
+	if window < 1 {
+		window = 1
+	}
+
 	fctx, cancel := context.WithCancel(ctx)
-	promises := serv.GetDAG(fctx, n)
-	return &DagReader{
+	dr := &DagReader{
 		node:     n,
 		serv:     serv,
 		buf:      NewRSNCFromBytes(pb.GetData()),
-		promises: promises,
+		promises: make([]mdag.NodeGetter, len(n.Links)),
+		window:   window,
 		ctx:      fctx,
 		cancel:   cancel,
 		pbdata:   pb,
 	}
+	dr.resetFetches(0)
+	return dr
+}
+
+// SetPrefetchWindow sets the number of children fetched ahead of the one
+// being read. It applies to the children read from then on.
+func (dr *DagReader) SetPrefetchWindow(n int) {
+	if n < 1 {
+		n = 1
+	}
+	dr.window = n
+	if child, ok := dr.buf.(*DagReader); ok {
+		child.SetPrefetchWindow(n)
+	}
+	dr.prefetch()
+}
+
+// prefetch requests the children in the window after linkPosition that have
+// not been requested yet, all at once so that they are fetched concurrently
+func (dr *DagReader) prefetch() {
+	end := dr.linkPosition + dr.window
+	if end > len(dr.promises) {
+		end = len(dr.promises)
+	}
+	if dr.fetched >= end {
+		return
+	}
+
+	keys := make([]u.Key, 0, end-dr.fetched)
+	for _, lnk := range dr.node.Links[dr.fetched:end] {
+		keys = append(keys, u.Key(lnk.Hash))
+	}
+	copy(dr.promises[dr.fetched:end], dr.serv.GetNodes(dr.fetchCtx, keys))
+	dr.fetched = end
+}
+
+// resetFetches cancels all outstanding requests and starts fetching again
+// from the child at index i
+func (dr *DagReader) resetFetches(i int) {
+	if dr.fetchCancel != nil {
+		dr.fetchCancel()
+	}
+	dr.fetchCtx, dr.fetchCancel = context.WithCancel(dr.ctx)
+	for j := range dr.promises {
+		dr.promises[j] = nil
+	}
+	dr.linkPosition = i
+	dr.fetched = i
+	dr.prefetch()
+}
+
+// seekLink moves the read head to the start of the child at index i. Requests
+// made for children in the window after i are kept, all others are cancelled.
+func (dr *DagReader) seekLink(i int) {
+	if i < dr.linkPosition || i >= dr.fetched {
+		dr.resetFetches(i)
+		return
+	}
+
+	for j := dr.linkPosition; j < i; j++ {
+		dr.promises[j] = nil
+	}
+	dr.linkPosition = i
+	dr.prefetch()
 }
 
 // precalcNextBuf follows the next link in line and loads it from the DAGService,
@@ -106,11 +191,14 @@ func (dr *DagReader) precalcNextBuf(ctx context.Context) error {
 		return io.EOF
 	}
 
+	dr.prefetch()
 	nxt, err := dr.promises[dr.linkPosition].Get(ctx)
 	if err != nil {
 		return err
 	}
+	dr.promises[dr.linkPosition] = nil
 	dr.linkPosition++
+	dr.prefetch()
 
 	pb := new(ftpb.Data)
 	err = proto.Unmarshal(nxt.Data, pb)
@@ -119,11 +207,13 @@ func (dr *DagReader) precalcNextBuf(ctx context.Context) error {
 	}
 
 	switch pb.GetType() {
-	case ftpb.Data_Directory:
+	case ftpb.Data_Directory, ftpb.Data_HAMTShard:
 		// A directory should not exist within a file
 		return ft.ErrInvalidDirLocation
 	case ftpb.Data_File:
-		dr.buf = newDataFileReader(dr.ctx, nxt, pb, dr.serv)
+		// the child's requests are outstanding requests of ours, cancelled
+		// along with them on seeks
+		dr.buf = newDataFileReader(dr.fetchCtx, nxt, pb, dr.serv, dr.window)
 		return nil
 	case ftpb.Data_Raw:
 		dr.buf = NewRSNCFromBytes(pb.GetData())
@@ -226,7 +316,7 @@ func (dr *DagReader) Seek(offset int64, whence int) (int64, error) {
 			dr.buf = NewRSNCFromBytes(pb.GetData()[offset:])
 
 			// start reading links from the beginning
-			dr.linkPosition = 0
+			dr.seekLink(0)
 			dr.offset = offset
 			return offset, nil
 		} else {
@@ -237,7 +327,7 @@ func (dr *DagReader) Seek(offset int64, whence int) (int64, error) {
 		// iterate through links and find where we need to be
 		for i := 0; i < len(pb.Blocksizes); i++ {
 			if pb.Blocksizes[i] > uint64(left) {
-				dr.linkPosition = i
+				dr.seekLink(i)
 				break
 			} else {
 				left -= int64(pb.Blocksizes[i])
@@ -0,0 +1,243 @@
+package io
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+
+	imp "github.com/ipfs/go-ipfs/importer"
+	"github.com/ipfs/go-ipfs/importer/chunk"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+	ftpb "github.com/ipfs/go-ipfs/unixfs/pb"
+	u "github.com/ipfs/go-ipfs/util"
+)
+
+// countingDAG records the requests made through GetNodes
+type countingDAG struct {
+	mdag.DAGService
+
+	lk        sync.Mutex
+	requested int
+	ctxs      []context.Context
+}
+
+func (c *countingDAG) GetNodes(ctx context.Context, keys []u.Key) []mdag.NodeGetter {
+	c.lk.Lock()
+	c.requested += len(keys)
+	c.ctxs = append(c.ctxs, ctx)
+	c.lk.Unlock()
+	return c.DAGService.GetNodes(ctx, keys)
+}
+
+// getTestFile builds a file with a single level of 100 byte blocks
+func getTestFile(t *testing.T, blocks int) (*countingDAG, *mdag.Node, []byte) {
+	dserv := &countingDAG{DAGService: mdtest.Mock(t)}
+	root, data := buildTestFile(t, dserv, blocks)
+	return dserv, root, data
+}
+
+// buildTestFile adds the blocks of a file with a single level of 100 byte
+// blocks to dserv, and returns its root
+func buildTestFile(t *testing.T, dserv mdag.DAGService, blocks int) (*mdag.Node, []byte) {
+	data := make([]byte, blocks*100)
+	u.NewTimeSeededRand().Read(data)
+
+	root := &mdag.Node{}
+	fsn := &ft.FSNode{Type: ftpb.Data_File}
+	for i := 0; i < blocks; i++ {
+		b := data[i*100 : (i+1)*100]
+		leaf := &mdag.Node{Data: ft.FilePBData(b, uint64(len(b)))}
+		if _, err := dserv.Add(leaf); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLinkClean("", leaf); err != nil {
+			t.Fatal(err)
+		}
+		fsn.AddBlockSize(uint64(len(b)))
+	}
+
+	var err error
+	root.Data, err = fsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, data
+}
+
+func TestDagReaderPrefetchWindow(t *testing.T) {
+	dserv, nd, data := getTestFile(t, 50)
+	dr, err := NewDagReader(context.Background(), nd, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dr.SetPrefetchWindow(4)
+
+	if dserv.requested != DefaultPrefetchWindow {
+		t.Fatalf("expected %d requests up front, got %d", DefaultPrefetchWindow, dserv.requested)
+	}
+
+	buf := make([]byte, 1000)
+	if _, err := io.ReadFull(dr, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// reading 10 blocks with a window of 4 requests no further than 14
+	if dserv.requested != 14 {
+		t.Fatalf("expected 14 blocks requested, got %d", dserv.requested)
+	}
+
+	rest, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(buf, rest...), data) {
+		t.Fatal("read incorrect data")
+	}
+	if dserv.requested != 50 {
+		t.Fatalf("expected every block to be requested once, got %d", dserv.requested)
+	}
+}
+
+func TestDagReaderSeekCancelsFetches(t *testing.T) {
+	dserv, nd, data := getTestFile(t, 50)
+
+	dr, err := NewDagReader(context.Background(), nd, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := dserv.ctxs[0]
+
+	// seeking within the window keeps the outstanding requests
+	if _, err := dr.Seek(250, os.SEEK_SET); err != nil {
+		t.Fatal(err)
+	}
+	if first.Err() != nil {
+		t.Fatal("seek within the window cancelled requests")
+	}
+
+	if _, err := dr.Seek(4000, os.SEEK_SET); err != nil {
+		t.Fatal(err)
+	}
+	if first.Err() == nil {
+		t.Fatal("seek outside the window did not cancel requests")
+	}
+
+	out, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data[4000:]) {
+		t.Fatal("read incorrect data after seek")
+	}
+}
+
+func TestDagReaderSeekCancelsChildFetches(t *testing.T) {
+	dserv := &countingDAG{DAGService: mdtest.Mock(t)}
+
+	// ten children of twenty 100 byte blocks each
+	var data []byte
+	root := &mdag.Node{}
+	rootfsn := &ft.FSNode{Type: ftpb.Data_File}
+	for i := 0; i < 10; i++ {
+		child, b := buildTestFile(t, dserv, 20)
+		if _, err := dserv.Add(child); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.AddNodeLinkClean("", child); err != nil {
+			t.Fatal(err)
+		}
+		rootfsn.AddBlockSize(uint64(len(b)))
+		data = append(data, b...)
+	}
+	var err error
+	root.Data, err = rootfsn.GetBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dr, err := NewDagReader(context.Background(), root, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 150)
+	if _, err := io.ReadFull(dr, buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(dserv.ctxs) < 2 {
+		t.Fatal("expected requests from the root and its first child")
+	}
+	rootCtx, childCtx := dserv.ctxs[0], dserv.ctxs[1]
+
+	// the fifth child is within the window of the root, but the first
+	// child is left behind
+	if _, err := dr.Seek(5*2000+50, os.SEEK_SET); err != nil {
+		t.Fatal(err)
+	}
+	if rootCtx.Err() != nil {
+		t.Fatal("seek within the window cancelled the requests of the root")
+	}
+	if childCtx.Err() == nil {
+		t.Fatal("seek did not cancel the requests of the child left behind")
+	}
+
+	// seeking outside of the window cancels the current child with the rest
+	childCtx = dserv.ctxs[len(dserv.ctxs)-1]
+	if _, err := dr.Seek(50, os.SEEK_SET); err != nil {
+		t.Fatal(err)
+	}
+	if childCtx.Err() == nil {
+		t.Fatal("seek did not cancel the requests of the child left behind")
+	}
+
+	out, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, data[50:]) {
+		t.Fatal("read incorrect data after seeks")
+	}
+}
+
+func TestDagReaderRandomSeeks(t *testing.T) {
+	dserv := mdtest.Mock(t)
+	data := make([]byte, 200000)
+	u.NewTimeSeededRand().Read(data)
+
+	nd, err := imp.BuildDagFromReader(bytes.NewReader(data), dserv, nil, &chunk.SizeSplitter{Size: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, window := range []int{1, 3, 32} {
+		dr, err := NewDagReader(context.Background(), nd, dserv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dr.SetPrefetchWindow(window)
+
+		for i := 0; i < 50; i++ {
+			off := rand.Intn(len(data))
+			if _, err := dr.Seek(int64(off), os.SEEK_SET); err != nil {
+				t.Fatal(err)
+			}
+
+			buf := make([]byte, rand.Intn(5000))
+			n, err := io.ReadFull(dr, buf)
+			if err != nil && err != io.ErrUnexpectedEOF {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf[:n], data[off:off+n]) {
+				t.Fatalf("window %d: read incorrect data at offset %d", window, off)
+			}
+		}
+		dr.Close()
+	}
+}
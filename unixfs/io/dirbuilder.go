@@ -1,29 +1,91 @@
 package io
 
 import (
+	"errors"
 	"time"
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
 
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	format "github.com/ipfs/go-ipfs/unixfs"
+	hamt "github.com/ipfs/go-ipfs/unixfs/hamt"
 	u "github.com/ipfs/go-ipfs/util"
 )
 
-type directoryBuilder struct {
-	dserv   mdag.DAGService
+var ErrNotADir = errors.New("node is not a directory")
+
+// ShardSplitThreshold is the number of entries above which a directory is
+// converted into a sharded (HAMT) directory, so that no single block grows
+// too large to be transferred.
+var ShardSplitThreshold = 1000
+
+// Directory is a unixfs directory, which may be stored either as a single
+// node or as a HAMT of shard nodes. Plain directories are converted to
+// sharded ones automatically once they grow past ShardSplitThreshold.
+type Directory struct {
+	dserv mdag.DAGService
+
+	// exactly one of dirnode and shard is set
 	dirnode *mdag.Node
+	shard   *hamt.Shard
 }
 
-func NewDirectory(dserv mdag.DAGService) *directoryBuilder {
-	db := new(directoryBuilder)
+// NewDirectory returns an empty directory
+func NewDirectory(dserv mdag.DAGService) *Directory {
+	db := new(Directory)
 	db.dserv = dserv
 	db.dirnode = new(mdag.Node)
 	db.dirnode.Data = format.FolderPBData()
 	return db
 }
 
-func (d *directoryBuilder) AddChild(name string, k u.Key) error {
+// NewDirectoryFromNode wraps an existing directory or HAMT shard node. Plain
+// directory nodes are modified in place.
+func NewDirectoryFromNode(dserv mdag.DAGService, nd *mdag.Node) (*Directory, error) {
+	pbd, err := format.FromBytes(nd.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pbd.GetType() {
+	case format.TDirectory:
+		return &Directory{
+			dserv:   dserv,
+			dirnode: nd,
+		}, nil
+	case format.THAMTShard:
+		shard, err := hamt.NewHamtFromDag(dserv, nd)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Directory{
+			dserv: dserv,
+			shard: shard,
+		}, nil
+	default:
+		return nil, ErrNotADir
+	}
+}
+
+// IsDirectory returns whether the given node is a plain or sharded directory
+func IsDirectory(nd *mdag.Node) bool {
+	pbd, err := format.FromBytes(nd.Data)
+	if err != nil {
+		return false
+	}
+
+	switch pbd.GetType() {
+	case format.TDirectory, format.THAMTShard:
+		return true
+	default:
+		return false
+	}
+}
+
+// AddChild fetches the node for the given key and adds it to the directory
+// under the given name
+func (d *Directory) AddChild(name string, k u.Key) error {
 	ctx, cancel := context.WithTimeout(context.TODO(), time.Minute)
 	defer cancel()
 
@@ -32,14 +94,123 @@ func (d *directoryBuilder) AddChild(name string, k u.Key) error {
 		return err
 	}
 
-	err = d.dirnode.AddNodeLinkClean(name, cnode)
+	return d.SetChild(ctx, name, cnode)
+}
+
+// SetChild links the given node into the directory under the given name,
+// replacing any previous entry by that name
+func (d *Directory) SetChild(ctx context.Context, name string, nd *mdag.Node) error {
+	lnk, err := mdag.MakeLink(nd)
 	if err != nil {
 		return err
 	}
 
+	return d.SetLink(ctx, name, lnk)
+}
+
+// SetLink adds a copy of the given link under the given name, replacing any
+// previous entry by that name
+func (d *Directory) SetLink(ctx context.Context, name string, lnk *mdag.Link) error {
+	if d.shard != nil {
+		return d.shard.SetLink(ctx, name, lnk)
+	}
+
+	err := d.dirnode.RemoveNodeLink(name)
+	if err != nil && err != mdag.ErrNotFound {
+		return err
+	}
+
+	if len(d.dirnode.Links) >= ShardSplitThreshold {
+		err := d.switchToSharding(ctx)
+		if err != nil {
+			return err
+		}
+		return d.shard.SetLink(ctx, name, lnk)
+	}
+
+	return d.dirnode.AddRawLink(name, &mdag.Link{
+		Size: lnk.Size,
+		Hash: lnk.Hash,
+	})
+}
+
+// Find returns a copy of the link with the given name, or
+// merkledag.ErrNotFound
+func (d *Directory) Find(ctx context.Context, name string) (*mdag.Link, error) {
+	if d.shard != nil {
+		return d.shard.Find(ctx, name)
+	}
+
+	return d.dirnode.GetNodeLink(name)
+}
+
+// RemoveChild removes the entry with the given name
+func (d *Directory) RemoveChild(ctx context.Context, name string) error {
+	if d.shard != nil {
+		return d.shard.Remove(ctx, name)
+	}
+
+	return d.dirnode.RemoveNodeLink(name)
+}
+
+// ForEachLink calls f with a copy of the link of every entry
+func (d *Directory) ForEachLink(ctx context.Context, f func(*mdag.Link) error) error {
+	if d.shard != nil {
+		return d.shard.ForEachLink(ctx, f)
+	}
+
+	for _, l := range d.dirnode.Links {
+		err := f(&mdag.Link{
+			Name: l.Name,
+			Size: l.Size,
+			Hash: l.Hash,
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (d *directoryBuilder) GetNode() *mdag.Node {
-	return d.dirnode
+// Links returns copies of the links of every entry
+func (d *Directory) Links(ctx context.Context) ([]*mdag.Link, error) {
+	var links []*mdag.Link
+	err := d.ForEachLink(ctx, func(l *mdag.Link) error {
+		links = append(links, l)
+		return nil
+	})
+	return links, err
+}
+
+// IsSharded returns whether the directory is stored as a HAMT
+func (d *Directory) IsSharded() bool {
+	return d.shard != nil
+}
+
+// GetNode returns the root node of the directory. Any sub-shards are added to
+// the DAGService, the root node itself is not.
+func (d *Directory) GetNode() (*mdag.Node, error) {
+	if d.shard != nil {
+		return d.shard.Node()
+	}
+
+	return d.dirnode, nil
+}
+
+func (d *Directory) switchToSharding(ctx context.Context) error {
+	shard, err := hamt.NewShard(d.dserv, hamt.DefaultShardWidth)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range d.dirnode.Links {
+		err := shard.SetLink(ctx, l.Name, l)
+		if err != nil {
+			return err
+		}
+	}
+
+	d.shard = shard
+	d.dirnode = nil
+	return nil
 }
@@ -49,6 +49,8 @@ type DagModifier struct {
 	read *uio.DagReader
 }
 
+// NewDagModifier returns a DagModifier for the file 'from'. If mp is not nil,
+// the modified file is pinned recursively in place of the original one.
 func NewDagModifier(ctx context.Context, from *mdag.Node, serv mdag.DAGService, mp pin.ManualPinner, spl chunk.BlockSplitter) (*DagModifier, error) {
 	return &DagModifier{
 		curNode:  from.Copy(),
@@ -209,11 +211,13 @@ func (dm *DagModifier) Sync() error {
 	}
 
 	// Finalize correct pinning, and flush pinner
-	dm.mp.PinWithMode(thisk, pin.Recursive)
-	dm.mp.RemovePinWithMode(curk, pin.Recursive)
-	err = dm.mp.Flush()
-	if err != nil {
-		return err
+	if dm.mp != nil {
+		dm.mp.PinWithMode(thisk, pin.Recursive)
+		dm.mp.RemovePinWithMode(curk, pin.Recursive)
+		err = dm.mp.Flush()
+		if err != nil {
+			return err
+		}
 	}
 
 	dm.writeStart += uint64(buflen)
@@ -266,7 +270,9 @@ func (dm *DagModifier) modifyDag(node *mdag.Node, offset uint64, data io.Reader)
 		if cur+bs > offset {
 			// Unpin block
 			ckey := u.Key(node.Links[i].Hash)
-			dm.mp.RemovePinWithMode(ckey, pin.Indirect)
+			if dm.mp != nil {
+				dm.mp.RemovePinWithMode(ckey, pin.Indirect)
+			}
 
 			child, err := node.Links[i].GetNode(dm.ctx, dm.dagserv)
 			if err != nil {
@@ -278,7 +284,9 @@ func (dm *DagModifier) modifyDag(node *mdag.Node, offset uint64, data io.Reader)
 			}
 
 			// pin the new node
-			dm.mp.PinWithMode(k, pin.Indirect)
+			if dm.mp != nil {
+				dm.mp.PinWithMode(k, pin.Indirect)
+			}
 
 			offset += bs
 			node.Links[i].Hash = mh.Multihash(k)
@@ -425,6 +433,9 @@ func (dm *DagModifier) Truncate(size int64) error {
 	if size > int64(realSize) {
 		return dm.expandSparse(int64(size) - realSize)
 	}
+	if size == realSize {
+		return nil
+	}
 
 	nnode, err := dagTruncate(dm.curNode, uint64(size), dm.dagserv)
 	if err != nil {
@@ -456,7 +467,7 @@ func dagTruncate(nd *mdag.Node, size uint64, ds mdag.DAGService) (*mdag.Node, er
 	var cur uint64
 	end := 0
 	var modified *mdag.Node
-	ndata := new(ft.FSNode)
+	ndata := &ft.FSNode{Type: ft.TFile}
 	for i, lnk := range nd.Links {
 		ctx, cancel := context.WithTimeout(context.TODO(), time.Minute)
 		defer cancel()
@@ -428,6 +428,32 @@ func TestDagTruncate(t *testing.T) {
 	if err = arrComp(out, b[:12345]); err != nil {
 		t.Fatal(err)
 	}
+
+	nd, err := dagmod.GetNode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbn, err := ft.FromBytes(nd.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pbn.GetType() != ft.TFile || pbn.GetFilesize() != 12345 {
+		t.Fatalf("truncated root is %s of size %d", pbn.GetType(), pbn.GetFilesize())
+	}
+
+	// truncating to the current size changes nothing
+	err = dagmod.Truncate(12345)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := dagmod.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 12345 {
+		t.Fatalf("expected size 12345, got %d", size)
+	}
 }
 
 func TestSparseWrite(t *testing.T) {
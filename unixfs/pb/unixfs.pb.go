@@ -28,6 +28,8 @@ const (
 	Data_Directory Data_DataType = 1
 	Data_File      Data_DataType = 2
 	Data_Metadata  Data_DataType = 3
+	Data_HAMTShard Data_DataType = 4
+	Data_Symlink   Data_DataType = 5
 )
 
 var Data_DataType_name = map[int32]string{
@@ -35,12 +37,16 @@ var Data_DataType_name = map[int32]string{
 	1: "Directory",
 	2: "File",
 	3: "Metadata",
+	4: "HAMTShard",
+	5: "Symlink",
 }
 var Data_DataType_value = map[string]int32{
 	"Raw":       0,
 	"Directory": 1,
 	"File":      2,
 	"Metadata":  3,
+	"HAMTShard": 4,
+	"Symlink":   5,
 }
 
 func (x Data_DataType) Enum() *Data_DataType {
@@ -65,6 +71,8 @@ type Data struct {
 	Data             []byte         `protobuf:"bytes,2,opt" json:"Data,omitempty"`
 	Filesize         *uint64        `protobuf:"varint,3,opt,name=filesize" json:"filesize,omitempty"`
 	Blocksizes       []uint64       `protobuf:"varint,4,rep,name=blocksizes" json:"blocksizes,omitempty"`
+	HashType         *uint64        `protobuf:"varint,5,opt,name=hashType" json:"hashType,omitempty"`
+	Fanout           *uint64        `protobuf:"varint,6,opt,name=fanout" json:"fanout,omitempty"`
 	XXX_unrecognized []byte         `json:"-"`
 }
 
@@ -100,6 +108,20 @@ func (m *Data) GetBlocksizes() []uint64 {
 	return nil
 }
 
+func (m *Data) GetHashType() uint64 {
+	if m != nil && m.HashType != nil {
+		return *m.HashType
+	}
+	return 0
+}
+
+func (m *Data) GetFanout() uint64 {
+	if m != nil && m.Fanout != nil {
+		return *m.Fanout
+	}
+	return 0
+}
+
 type Metadata struct {
 	MimeType         *string `protobuf:"bytes,1,req" json:"MimeType,omitempty"`
 	XXX_unrecognized []byte  `json:"-"`
@@ -4,57 +4,145 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
 	gopath "path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ipfs/go-ipfs/Godeps/_workspace/src/golang.org/x/net/context"
+	blockstore "github.com/ipfs/go-ipfs/blocks/blockstore"
+	blockservice "github.com/ipfs/go-ipfs/blockservice"
+	offline "github.com/ipfs/go-ipfs/exchange/offline"
+	importer "github.com/ipfs/go-ipfs/importer"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
 	mdag "github.com/ipfs/go-ipfs/merkledag"
 	path "github.com/ipfs/go-ipfs/path"
+	ttar "github.com/ipfs/go-ipfs/thirdparty/tar"
 	uio "github.com/ipfs/go-ipfs/unixfs/io"
 	upb "github.com/ipfs/go-ipfs/unixfs/pb"
+	u "github.com/ipfs/go-ipfs/util"
 
 	proto "github.com/ipfs/go-ipfs/Godeps/_workspace/src/code.google.com/p/goprotobuf/proto"
+	ds "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore"
+	dssync "github.com/ipfs/go-ipfs/Godeps/_workspace/src/github.com/jbenet/go-datastore/sync"
 )
 
+// LocalFile describes a copy of a file that the receiver of an archive
+// already has. Hash is computed with HashFile.
+type LocalFile struct {
+	Size uint64
+	Hash string
+}
+
+// HashFile returns the hash 'ipfs add' would give to the contents of r,
+// without keeping any blocks.
+func HashFile(r io.Reader) (string, error) {
+	bstore := blockstore.NewBlockstore(dssync.MutexWrap(ds.NewNullDatastore()))
+	bserv, err := blockservice.New(bstore, offline.Exchange(bstore))
+	if err != nil {
+		return "", err
+	}
+	defer bserv.Close()
+
+	nd, err := importer.BuildDagFromReader(r, mdag.NewDAGService(bserv), nil, chunk.DefaultSplitter)
+	if err != nil {
+		return "", err
+	}
+
+	k, err := nd.Key()
+	if err != nil {
+		return "", err
+	}
+	return k.B58String(), nil
+}
+
+// Format is the container format of the archive a Reader produces
+type Format int
+
+const (
+	// Tar archives are optionally compressed with gzip
+	Tar Format = iota
+
+	// Zip archives compress each file with deflate, if compression is
+	// enabled. They cannot continue files from an offset.
+	Zip
+)
+
+// Options select which parts of a tree a Reader writes to the archive.
+// Paths are relative to the root of the tree, with the root itself being "".
+type Options struct {
+	// Format of the archive, Tar by default
+	Format Format
+
+	// Include holds patterns, in the syntax of path.Match, for the paths
+	// to include. A pattern that matches a directory includes everything
+	// below it. If Include is empty, the whole tree is written.
+	Include []string
+
+	// Have lists the files the receiver already has. Files with the same
+	// hash are left out of the archive. Files holding the beginning of the
+	// file in the tree are interrupted downloads, and are continued from
+	// their size onwards. Other files are written whole.
+	Have map[string]LocalFile
+}
+
 type Reader struct {
+	opts       Options
 	buf        bytes.Buffer
 	closed     bool
 	signalChan chan struct{}
 	dag        mdag.DAGService
 	resolver   *path.Resolver
-	writer     *tar.Writer
-	gzipWriter *gzip.Writer
+	writer     archiveWriter
 	err        error
+
+	// ctx is cancelled by Close, stopping the goroutine writing the
+	// archive. stopped is set by that goroutine once it gave up waiting
+	// for the buffer, which it must not touch any more.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped bool
 }
 
 func NewReader(path path.Path, dag mdag.DAGService, dagnode *mdag.Node, compression int) (*Reader, error) {
+	return NewReaderWithOptions(path, dag, dagnode, compression, Options{})
+}
 
+// NewReaderWithOptions returns a Reader for the parts of the tree selected by
+// opts. Files that are continued from an offset carry a ttar.OffsetRecord
+// PAX record, and only hold the data after that offset.
+func NewReaderWithOptions(path path.Path, dag mdag.DAGService, dagnode *mdag.Node, compression int, opts Options) (*Reader, error) {
 	reader := &Reader{
+		opts:       opts,
 		signalChan: make(chan struct{}),
 		dag:        dag,
 	}
+	reader.ctx, reader.cancel = context.WithCancel(context.Background())
 
 	var err error
-	if compression != gzip.NoCompression {
-		reader.gzipWriter, err = gzip.NewWriterLevel(&reader.buf, compression)
-		if err != nil {
-			return nil, err
-		}
-		reader.writer = tar.NewWriter(reader.gzipWriter)
-	} else {
-		reader.writer = tar.NewWriter(&reader.buf)
+	switch opts.Format {
+	case Tar:
+		reader.writer, err = newTarWriter(&reader.buf, compression)
+	case Zip:
+		reader.writer = newZipWriter(&reader.buf, compression)
+	default:
+		err = fmt.Errorf("unknown archive format %d", opts.Format)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	// writeToBuf will write the data to the buffer, and will signal when there
 	// is new data to read
 	_, filename := gopath.Split(path.String())
-	go reader.writeToBuf(dagnode, filename, 0)
+	go reader.writeToBuf(dagnode, filename, "", 0)
 
 	return reader, nil
 }
 
-func (r *Reader) writeToBuf(dagnode *mdag.Node, path string, depth int) {
+func (r *Reader) writeToBuf(dagnode *mdag.Node, path, rel string, depth int) {
 	pb := new(upb.Data)
 	err := proto.Unmarshal(dagnode.Data, pb)
 	if err != nil {
@@ -66,53 +154,105 @@ func (r *Reader) writeToBuf(dagnode *mdag.Node, path string, depth int) {
 		defer r.close()
 	}
 
-	if pb.GetType() == upb.Data_Directory {
-		err = r.writer.WriteHeader(&tar.Header{
-			Name:     path,
-			Typeflag: tar.TypeDir,
-			Mode:     0777,
-			ModTime:  time.Now(),
-			// TODO: set mode, dates, etc. when added to unixFS
-		})
+	if pb.GetType() == upb.Data_Directory || pb.GetType() == upb.Data_HAMTShard {
+		if !r.mayContain(rel) {
+			return
+		}
+
+		err = r.writer.WriteDir(path)
 		if err != nil {
 			r.emitError(err)
 			return
 		}
-		r.flush()
+		if r.flush() != nil {
+			return
+		}
 
-		ctx, cancel := context.WithTimeout(context.TODO(), time.Second*60)
+		ctx, cancel := context.WithTimeout(r.ctx, time.Second*60)
 		defer cancel()
 
-		for i, ng := range r.dag.GetDAG(ctx, dagnode) {
+		dir, err := uio.NewDirectoryFromNode(r.dag, dagnode)
+		if err != nil {
+			r.emitError(err)
+			return
+		}
+
+		links, err := dir.Links(ctx)
+		if err != nil {
+			r.emitError(err)
+			return
+		}
+
+		keys := make([]u.Key, len(links))
+		for i, lnk := range links {
+			keys[i] = u.Key(lnk.Hash)
+		}
+
+		for i, ng := range r.dag.GetNodes(ctx, keys) {
 			childNode, err := ng.Get(ctx)
 			if err != nil {
 				r.emitError(err)
 				return
 			}
-			r.writeToBuf(childNode, gopath.Join(path, dagnode.Links[i].Name), depth+1)
+			r.writeToBuf(childNode, gopath.Join(path, links[i].Name), gopath.Join(rel, links[i].Name), depth+1)
+			if r.stopped {
+				return
+			}
 		}
 		return
 	}
 
-	err = r.writer.WriteHeader(&tar.Header{
-		Name:     path,
-		Size:     int64(pb.GetFilesize()),
-		Typeflag: tar.TypeReg,
-		Mode:     0644,
-		ModTime:  time.Now(),
-		// TODO: set mode, dates, etc. when added to unixFS
-	})
+	if !Included(r.opts.Include, rel) {
+		return
+	}
+
+	size := pb.GetFilesize()
+	var offset uint64
+	if have, ok := r.opts.Have[rel]; ok {
+		k, err := dagnode.Key()
+		if err != nil {
+			r.emitError(err)
+			return
+		}
+
+		switch {
+		case have.Size == size && have.Hash == k.B58String():
+			return
+		case have.Size < size:
+			partial, err := r.isPrefix(dagnode, have)
+			if err != nil {
+				r.emitError(err)
+				return
+			}
+			if partial {
+				offset = have.Size
+			}
+		}
+	}
+
+	err = r.writer.WriteFile(path, size, offset)
 	if err != nil {
 		r.emitError(err)
 		return
 	}
-	r.flush()
+	if r.flush() != nil {
+		return
+	}
 
-	reader, err := uio.NewDagReader(context.TODO(), dagnode, r.dag)
+	reader, err := uio.NewDagReader(r.ctx, dagnode, r.dag)
 	if err != nil {
 		r.emitError(err)
 		return
 	}
+	defer reader.Close()
+
+	if offset > 0 {
+		_, err = reader.Seek(int64(offset), 0)
+		if err != nil {
+			r.emitError(err)
+			return
+		}
+	}
 
 	err = r.syncCopy(reader)
 	if err != nil {
@@ -121,11 +261,77 @@ func (r *Reader) writeToBuf(dagnode *mdag.Node, path string, depth int) {
 	}
 }
 
+// isPrefix returns whether the receiver's copy of the file at dagnode,
+// described by have, holds the beginning of the file
+func (r *Reader) isPrefix(dagnode *mdag.Node, have LocalFile) (bool, error) {
+	reader, err := uio.NewDagReader(r.ctx, dagnode, r.dag)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	h, err := HashFile(io.LimitReader(reader, int64(have.Size)))
+	if err != nil {
+		return false, err
+	}
+	return h == have.Hash, nil
+}
+
+// Included returns whether the path rel is selected by the given include
+// patterns, either directly or through one of its parent directories. See
+// Options.Include.
+func Included(include []string, rel string) bool {
+	if len(include) == 0 || rel == "" {
+		return true
+	}
+
+	for _, pattern := range include {
+		for p := rel; p != "."; p = gopath.Dir(p) {
+			if ok, _ := gopath.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mayContain returns whether the directory at rel could hold any path
+// selected by the include patterns
+func (r *Reader) mayContain(rel string) bool {
+	if Included(r.opts.Include, rel) {
+		return true
+	}
+
+	dir := strings.Split(rel, "/")
+	for _, pattern := range r.opts.Include {
+		elems := strings.Split(pattern, "/")
+		if len(elems) <= len(dir) {
+			continue
+		}
+
+		matches := true
+		for i := range dir {
+			if ok, _ := gopath.Match(elems[i], dir[i]); !ok {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Reader) Read(p []byte) (int, error) {
 	// wait for the goroutine that is writing data to the buffer to tell us
 	// there is something to read
 	if !r.closed {
-		<-r.signalChan
+		select {
+		case <-r.signalChan:
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
 	}
 
 	if r.err != nil {
@@ -133,7 +339,12 @@ func (r *Reader) Read(p []byte) (int, error) {
 	}
 
 	if !r.closed {
-		defer r.signal()
+		defer func() {
+			select {
+			case r.signalChan <- struct{}{}:
+			case <-r.ctx.Done():
+			}
+		}()
 	}
 
 	if r.buf.Len() == 0 {
@@ -151,21 +362,51 @@ func (r *Reader) Read(p []byte) (int, error) {
 	return n, err
 }
 
-func (r *Reader) signal() {
-	r.signalChan <- struct{}{}
+// Close stops writing the archive and cancels the fetches in progress. It
+// must be called if the archive is not read to its end.
+func (r *Reader) Close() error {
+	r.cancel()
+	return nil
 }
 
-func (r *Reader) flush() {
-	r.signal()
-	<-r.signalChan
+// signal hands the buffer over to the reading side. It returns false if the
+// Reader was closed instead.
+func (r *Reader) signal() bool {
+	select {
+	case r.signalChan <- struct{}{}:
+		return true
+	case <-r.ctx.Done():
+		r.stopped = true
+		return false
+	}
+}
+
+// flush lets the reading side empty the buffer, and waits for it to be done
+func (r *Reader) flush() error {
+	if !r.signal() {
+		return r.ctx.Err()
+	}
+	select {
+	case <-r.signalChan:
+		return nil
+	case <-r.ctx.Done():
+		r.stopped = true
+		return r.ctx.Err()
+	}
 }
 
 func (r *Reader) emitError(err error) {
+	if r.stopped {
+		return
+	}
 	r.err = err
 	r.signal()
 }
 
 func (r *Reader) close() {
+	if r.stopped {
+		return
+	}
 	r.closed = true
 	defer r.signal()
 	err := r.writer.Close()
@@ -173,13 +414,6 @@ func (r *Reader) close() {
 		r.emitError(err)
 		return
 	}
-	if r.gzipWriter != nil {
-		err = r.gzipWriter.Close()
-		if err != nil {
-			r.emitError(err)
-			return
-		}
-	}
 }
 
 func (r *Reader) syncCopy(reader io.Reader) error {
@@ -191,7 +425,9 @@ func (r *Reader) syncCopy(reader io.Reader) error {
 			if err != nil {
 				return err
 			}
-			r.flush()
+			if err := r.flush(); err != nil {
+				return err
+			}
 		}
 		if err == io.EOF {
 			break
@@ -202,3 +438,70 @@ func (r *Reader) syncCopy(reader io.Reader) error {
 	}
 	return nil
 }
+
+// archiveWriter writes the entries of a Reader in a particular format
+type archiveWriter interface {
+	WriteDir(name string) error
+
+	// WriteFile starts a new file entry. Its contents, written with Write,
+	// are the part of the file from offset up to size.
+	WriteFile(name string, size, offset uint64) error
+
+	Write(p []byte) (int, error)
+	Close() error
+}
+
+type tarWriter struct {
+	*tar.Writer
+	gzipWriter *gzip.Writer
+}
+
+func newTarWriter(w io.Writer, compression int) (*tarWriter, error) {
+	if compression == gzip.NoCompression {
+		return &tarWriter{Writer: tar.NewWriter(w)}, nil
+	}
+
+	gzw, err := gzip.NewWriterLevel(w, compression)
+	if err != nil {
+		return nil, err
+	}
+	return &tarWriter{Writer: tar.NewWriter(gzw), gzipWriter: gzw}, nil
+}
+
+func (w *tarWriter) WriteDir(name string) error {
+	return w.WriteHeader(&tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeDir,
+		Mode:     0777,
+		ModTime:  time.Now(),
+		// TODO: set mode, dates, etc. when added to unixFS
+	})
+}
+
+func (w *tarWriter) WriteFile(name string, size, offset uint64) error {
+	hdr := &tar.Header{
+		Name:     name,
+		Size:     int64(size - offset),
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		ModTime:  time.Now(),
+		// TODO: set mode, dates, etc. when added to unixFS
+	}
+	if offset > 0 {
+		hdr.PAXRecords = map[string]string{
+			ttar.OffsetRecord: strconv.FormatUint(offset, 10),
+		}
+	}
+	return w.WriteHeader(hdr)
+}
+
+func (w *tarWriter) Close() error {
+	err := w.Writer.Close()
+	if err != nil {
+		return err
+	}
+	if w.gzipWriter != nil {
+		return w.gzipWriter.Close()
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package tar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"runtime"
+	"testing"
+	"time"
+
+	imp "github.com/ipfs/go-ipfs/importer"
+	chunk "github.com/ipfs/go-ipfs/importer/chunk"
+	mdag "github.com/ipfs/go-ipfs/merkledag"
+	mdtest "github.com/ipfs/go-ipfs/merkledag/test"
+	path "github.com/ipfs/go-ipfs/path"
+	ft "github.com/ipfs/go-ipfs/unixfs"
+)
+
+func TestReaderClose(t *testing.T) {
+	ds := mdtest.Mock(t)
+	data := make([]byte, 1024*1024)
+	file, err := imp.BuildDagFromReader(bytes.NewReader(data), ds, nil, &chunk.SizeSplitter{Size: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := &mdag.Node{Data: ft.FolderPBData()}
+	if err := dir.AddNodeLinkClean("file", file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	before := runtime.NumGoroutine()
+
+	r, err := NewReader(path.Path("dir"), ds, dir, gzip.NoCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// stop reading half way through, as a client going away would
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(buf); err == nil {
+		t.Fatal("expected reads to fail after Close")
+	}
+
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("writing goroutine still running after Close: %d goroutines, %d before", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,78 @@
+package tar
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var ErrZipOffset = errors.New("zip archives cannot continue files from an offset")
+
+// zipWriter writes entries into a zip archive. Files are deflated unless
+// compression is gzip.NoCompression, in which case they are stored as is.
+type zipWriter struct {
+	zw     *zip.Writer
+	method uint16
+	cur    io.Writer
+}
+
+func newZipWriter(w io.Writer, compression int) *zipWriter {
+	zw := zip.NewWriter(w)
+	if compression == gzip.NoCompression {
+		return &zipWriter{zw: zw, method: zip.Store}
+	}
+
+	zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, compression)
+	})
+	return &zipWriter{zw: zw, method: zip.Deflate}
+}
+
+func (w *zipWriter) WriteDir(name string) error {
+	hdr := &zip.FileHeader{
+		Name:   name + "/",
+		Method: zip.Store,
+	}
+	hdr.SetModTime(time.Now())
+	hdr.SetMode(os.ModeDir | 0777)
+
+	_, err := w.zw.CreateHeader(hdr)
+	w.cur = nil
+	return err
+}
+
+func (w *zipWriter) WriteFile(name string, size, offset uint64) error {
+	if offset > 0 {
+		return ErrZipOffset
+	}
+
+	hdr := &zip.FileHeader{
+		Name:   name,
+		Method: w.method,
+	}
+	hdr.SetModTime(time.Now())
+	hdr.SetMode(0644)
+	hdr.UncompressedSize64 = size
+
+	fw, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	w.cur = fw
+	return nil
+}
+
+func (w *zipWriter) Write(p []byte) (int, error) {
+	if w.cur == nil {
+		return 0, errors.New("zip: write outside of a file entry")
+	}
+	return w.cur.Write(p)
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}